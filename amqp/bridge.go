@@ -0,0 +1,182 @@
+package amqp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/dynport/dgtk/pubsub"
+	"github.com/streadway/amqp"
+)
+
+// Publisher is the part of *amqp.Channel used by the Bridge to send messages.
+type Publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// Bridge connects a local PubSub with an AMQP exchange. Values of types registered with Forward are
+// JSON-encoded and published to the exchange using their type name as routing key. Deliveries passed to
+// Consume are decoded into the registered types and published to the local PubSub, so several processes can
+// share one event bus.
+//
+// Values received from the exchange are published to the PubSub without passing them to the subscriptions
+// of the bridge, so they are not forwarded again.
+type Bridge struct {
+	PubSub    *pubsub.PubSub
+	Exchange  string
+	Publisher Publisher
+	ID        string // set as AppId of published messages, used to ignore own messages. Defaults to a random id.
+
+	types         map[string]reflect.Type
+	subscriptions []*pubsub.Subscription
+	lock          sync.Mutex
+}
+
+func NewBridge(ps *pubsub.PubSub, exchange string, publisher Publisher) *Bridge {
+	return &Bridge{PubSub: ps, Exchange: exchange, Publisher: publisher}
+}
+
+// NewBridge creates a Bridge publishing on the channel of the connection.
+func (con *Connection) NewBridge(ps *pubsub.PubSub, exchange string) (*Bridge, error) {
+	c, e := con.Channel()
+	if e != nil {
+		return nil, e
+	}
+	return NewBridge(ps, exchange, c), nil
+}
+
+// TypeName returns the name used as routing key and message type for values like i. Pointers are
+// dereferenced, so *User and User share the name "main.User".
+func TypeName(i interface{}) string {
+	return typeName(reflect.TypeOf(i))
+}
+
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.String()
+}
+
+func (bridge *Bridge) id() string {
+	bridge.lock.Lock()
+	defer bridge.lock.Unlock()
+	if bridge.ID == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		bridge.ID = hex.EncodeToString(b)
+	}
+	return bridge.ID
+}
+
+// Register makes the bridge accept messages for the type of sample without forwarding local values.
+func (bridge *Bridge) Register(sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	if t == nil {
+		return fmt.Errorf("unable to register nil")
+	}
+	bridge.lock.Lock()
+	defer bridge.lock.Unlock()
+	if bridge.types == nil {
+		bridge.types = map[string]reflect.Type{}
+	}
+	name := typeName(t)
+	if existing, ok := bridge.types[name]; ok && existing != t {
+		return fmt.Errorf("type name %q already registered for %v", name, existing)
+	}
+	bridge.types[name] = t
+	return nil
+}
+
+// Forward registers the type of sample and publishes all local values of that type to the exchange.
+func (bridge *Bridge) Forward(sample interface{}) error {
+	if e := bridge.Register(sample); e != nil {
+		return e
+	}
+	t := reflect.TypeOf(sample)
+	callback := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{t}, nil, false), func(args []reflect.Value) []reflect.Value {
+		if e := bridge.forward(args[0].Interface()); e != nil {
+			log.Printf("ERROR: unable to forward %v: %v", typeName(t), e)
+		}
+		return nil
+	})
+	s := bridge.PubSub.Subscribe(callback.Interface())
+	bridge.lock.Lock()
+	bridge.subscriptions = append(bridge.subscriptions, s)
+	bridge.lock.Unlock()
+	return nil
+}
+
+func (bridge *Bridge) forward(i interface{}) error {
+	b, e := json.Marshal(i)
+	if e != nil {
+		return e
+	}
+	name := TypeName(i)
+	return bridge.Publisher.Publish(bridge.Exchange, name, false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Type:        name,
+		AppId:       bridge.id(),
+		Body:        b,
+	})
+}
+
+// Decode returns the value encoded in the delivery.
+func (bridge *Bridge) Decode(del amqp.Delivery) (interface{}, error) {
+	bridge.lock.Lock()
+	t, ok := bridge.types[del.Type]
+	bridge.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no type registered for %q", del.Type)
+	}
+	v := reflect.New(t)
+	if e := json.Unmarshal(del.Body, v.Interface()); e != nil {
+		return nil, e
+	}
+	return v.Elem().Interface(), nil
+}
+
+// Consume publishes all deliveries to the local PubSub and blocks until the channel is closed. Messages
+// published by this bridge are skipped, messages which can not be decoded are rejected.
+func (bridge *Bridge) Consume(deliveries <-chan amqp.Delivery) error {
+	id := bridge.id()
+	for del := range deliveries {
+		if del.AppId == id {
+			del.Ack(false)
+			continue
+		}
+		i, e := bridge.Decode(del)
+		if e != nil {
+			log.Printf("ERROR: unable to decode message %q: %v", del.Type, e)
+			del.Reject(false)
+			continue
+		}
+		bridge.lock.Lock()
+		subscriptions := bridge.subscriptions
+		bridge.lock.Unlock()
+		if e := bridge.PubSub.PublishExcept(i, subscriptions...); e != nil {
+			log.Printf("ERROR: unable to publish %q: %v", del.Type, e)
+		}
+		del.Ack(false)
+	}
+	return nil
+}
+
+// Close stops forwarding local values.
+func (bridge *Bridge) Close() error {
+	bridge.lock.Lock()
+	subscriptions := bridge.subscriptions
+	bridge.subscriptions = nil
+	bridge.lock.Unlock()
+	var err error
+	for _, s := range subscriptions {
+		if e := s.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+package amqp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/pubsub"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/streadway/amqp"
+)
+
+type testEvent struct {
+	Name  string
+	Count int
+}
+
+// memoryExchange is a fanout exchange delivering every message to all bound queues.
+type memoryExchange struct {
+	queues []chan amqp.Delivery
+	acks   *memoryAcknowledger
+}
+
+func newMemoryExchange() *memoryExchange {
+	return &memoryExchange{acks: &memoryAcknowledger{}}
+}
+
+func (ex *memoryExchange) bind() chan amqp.Delivery {
+	c := make(chan amqp.Delivery, 100)
+	ex.queues = append(ex.queues, c)
+	return c
+}
+
+func (ex *memoryExchange) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	for _, q := range ex.queues {
+		q <- amqp.Delivery{
+			Acknowledger: ex.acks,
+			Exchange:     exchange,
+			RoutingKey:   key,
+			ContentType:  msg.ContentType,
+			Type:         msg.Type,
+			AppId:        msg.AppId,
+			Body:         msg.Body,
+		}
+	}
+	return nil
+}
+
+type memoryAcknowledger struct {
+	acked    int
+	rejected int
+	lock     sync.Mutex
+}
+
+func (a *memoryAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.acked++
+	return nil
+}
+
+func (a *memoryAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *memoryAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.rejected++
+	return nil
+}
+
+func TestBridge(t *testing.T) {
+	Convey("Bridge", t, func() {
+		So(TypeName(&testEvent{}), ShouldEqual, "amqp.testEvent")
+
+		exchange := newMemoryExchange()
+		qa, qb := exchange.bind(), exchange.bind()
+		psa, psb := pubsub.New(), pubsub.New()
+		a := NewBridge(psa, "events", exchange)
+		b := NewBridge(psb, "events", exchange)
+		So(a.Forward(&testEvent{}), ShouldBeNil)
+		So(b.Forward(&testEvent{}), ShouldBeNil)
+
+		receivedA := make(chan *testEvent, 10)
+		receivedB := make(chan *testEvent, 10)
+		psa.Subscribe(func(e *testEvent) { receivedA <- e })
+		psb.Subscribe(func(e *testEvent) { receivedB <- e })
+
+		doneA, doneB := make(chan error), make(chan error)
+		go func() { doneA <- a.Consume(qa) }()
+		go func() { doneB <- b.Consume(qb) }()
+
+		So(psa.Publish(&testEvent{Name: "deploy", Count: 2}), ShouldBeNil)
+
+		select {
+		case e := <-receivedB:
+			So(e.Name, ShouldEqual, "deploy")
+			So(e.Count, ShouldEqual, 2)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		So((<-receivedA).Name, ShouldEqual, "deploy")
+
+		So(a.Close(), ShouldBeNil)
+		So(b.Close(), ShouldBeNil)
+		close(qa)
+		close(qb)
+		So(<-doneA, ShouldBeNil)
+		So(<-doneB, ShouldBeNil)
+
+		// the event must not bounce back from b to the exchange
+		So(len(receivedA), ShouldEqual, 0)
+		So(len(receivedB), ShouldEqual, 0)
+		So(exchange.acks.acked, ShouldEqual, 2)
+
+		Convey("rejects unknown types", func() {
+			q := make(chan amqp.Delivery, 1)
+			q <- amqp.Delivery{Acknowledger: exchange.acks, Type: "unknown", Body: []byte("{}")}
+			close(q)
+			So(NewBridge(pubsub.New(), "events", exchange).Consume(q), ShouldBeNil)
+			So(exchange.acks.rejected, ShouldEqual, 1)
+		})
+
+	})
+
+	Convey("Bridge with non comparable values", t, func() {
+		exchange := newMemoryExchange()
+		qa, qb := exchange.bind(), exchange.bind()
+		psa, psb := pubsub.New(), pubsub.New()
+		a := NewBridge(psa, "events", exchange)
+		b := NewBridge(psb, "events", exchange)
+		So(a.Forward(map[string]int{}), ShouldBeNil)
+		So(b.Forward(map[string]int{}), ShouldBeNil)
+
+		receivedA := make(chan map[string]int, 10)
+		receivedB := make(chan map[string]int, 10)
+		psa.Subscribe(func(m map[string]int) { receivedA <- m })
+		psb.Subscribe(func(m map[string]int) { receivedB <- m })
+		go a.Consume(qa)
+		go b.Consume(qb)
+
+		next := func(c chan map[string]int) map[string]int {
+			select {
+			case m := <-c:
+				return m
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for event")
+			}
+			return nil
+		}
+
+		So(psa.Publish(map[string]int{"deploys": 1}), ShouldBeNil)
+		So(next(receivedB), ShouldResemble, map[string]int{"deploys": 1})
+		So(next(receivedA), ShouldResemble, map[string]int{"deploys": 1})
+
+		// an equal value published locally by b is forwarded again
+		So(psb.Publish(map[string]int{"deploys": 1}), ShouldBeNil)
+		So(next(receivedA), ShouldResemble, map[string]int{"deploys": 1})
+		So(next(receivedB), ShouldResemble, map[string]int{"deploys": 1})
+
+		So(a.Close(), ShouldBeNil)
+		So(b.Close(), ShouldBeNil)
+		close(qa)
+		close(qb)
+		time.Sleep(10 * time.Millisecond)
+		So(len(receivedA), ShouldEqual, 0)
+		So(len(receivedB), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,123 @@
+package es
+
+type Aggregations map[string]*Aggregation
+
+// Aggregation defines one aggregation of a search request. Exactly one of the type fields must be set,
+// Aggregations can be used to define sub aggregations for bucket aggregations.
+type Aggregation struct {
+	Terms         *TermsAggregation         `json:"terms,omitempty"`
+	DateHistogram *DateHistogramAggregation `json:"date_histogram,omitempty"`
+	Histogram     *HistogramAggregation     `json:"histogram,omitempty"`
+	Range         *RangeAggregation         `json:"range,omitempty"`
+	Stats         *FieldAggregation         `json:"stats,omitempty"`
+	ExtendedStats *FieldAggregation         `json:"extended_stats,omitempty"`
+	Percentiles   *PercentilesAggregation   `json:"percentiles,omitempty"`
+	Cardinality   *CardinalityAggregation   `json:"cardinality,omitempty"`
+	Nested        *NestedAggregation        `json:"nested,omitempty"`
+	Aggregations  Aggregations              `json:"aggs,omitempty"`
+}
+
+// Add adds a sub aggregation and returns the aggregation.
+func (agg *Aggregation) Add(name string, sub *Aggregation) *Aggregation {
+	if agg.Aggregations == nil {
+		agg.Aggregations = Aggregations{}
+	}
+	agg.Aggregations[name] = sub
+	return agg
+}
+
+type TermsAggregation struct {
+	Field       string            `json:"field,omitempty"`
+	Size        int               `json:"size,omitempty"`
+	MinDocCount *int              `json:"min_doc_count,omitempty"`
+	Missing     interface{}       `json:"missing,omitempty"`
+	Order       map[string]string `json:"order,omitempty"`
+	Include     interface{}       `json:"include,omitempty"`
+	Exclude     interface{}       `json:"exclude,omitempty"`
+}
+
+type DateHistogramAggregation struct {
+	Field            string          `json:"field,omitempty"`
+	CalendarInterval string          `json:"calendar_interval,omitempty"` // e.g. "1d", "month"
+	FixedInterval    string          `json:"fixed_interval,omitempty"`    // e.g. "10m", "90s"
+	Format           string          `json:"format,omitempty"`
+	TimeZone         string          `json:"time_zone,omitempty"`
+	MinDocCount      *int            `json:"min_doc_count,omitempty"`
+	ExtendedBounds   *ExtendedBounds `json:"extended_bounds,omitempty"`
+}
+
+type HistogramAggregation struct {
+	Field          string          `json:"field,omitempty"`
+	Interval       float64         `json:"interval"`
+	MinDocCount    *int            `json:"min_doc_count,omitempty"`
+	ExtendedBounds *ExtendedBounds `json:"extended_bounds,omitempty"`
+}
+
+type ExtendedBounds struct {
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+}
+
+type RangeAggregation struct {
+	Field  string              `json:"field,omitempty"`
+	Ranges []*AggregationRange `json:"ranges"`
+	Keyed  bool                `json:"keyed,omitempty"`
+}
+
+// AggregationRange is one range of a RangeAggregation. From is inclusive, To is exclusive.
+type AggregationRange struct {
+	Key  string      `json:"key,omitempty"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+type FieldAggregation struct {
+	Field   string      `json:"field,omitempty"`
+	Missing interface{} `json:"missing,omitempty"`
+}
+
+type PercentilesAggregation struct {
+	Field    string    `json:"field,omitempty"`
+	Percents []float64 `json:"percents,omitempty"`
+}
+
+type CardinalityAggregation struct {
+	Field              string `json:"field,omitempty"`
+	PrecisionThreshold int    `json:"precision_threshold,omitempty"`
+}
+
+type NestedAggregation struct {
+	Path string `json:"path"`
+}
+
+func TermsAgg(field string, size int) *Aggregation {
+	return &Aggregation{Terms: &TermsAggregation{Field: field, Size: size}}
+}
+
+func DateHistogramAgg(field, calendarInterval string) *Aggregation {
+	return &Aggregation{DateHistogram: &DateHistogramAggregation{Field: field, CalendarInterval: calendarInterval}}
+}
+
+func HistogramAgg(field string, interval float64) *Aggregation {
+	return &Aggregation{Histogram: &HistogramAggregation{Field: field, Interval: interval}}
+}
+
+func RangeAgg(field string, ranges ...*AggregationRange) *Aggregation {
+	return &Aggregation{Range: &RangeAggregation{Field: field, Ranges: ranges}}
+}
+
+func StatsAgg(field string) *Aggregation {
+	return &Aggregation{Stats: &FieldAggregation{Field: field}}
+}
+
+func PercentilesAgg(field string, percents ...float64) *Aggregation {
+	return &Aggregation{Percentiles: &PercentilesAggregation{Field: field, Percents: percents}}
+}
+
+func CardinalityAgg(field string) *Aggregation {
+	return &Aggregation{Cardinality: &CardinalityAggregation{Field: field}}
+}
+
+func NestedAgg(path string) *Aggregation {
+	return &Aggregation{Nested: &NestedAggregation{Path: path}}
+}
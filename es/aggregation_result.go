@@ -0,0 +1,201 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AggregationResults holds the raw results of all aggregations by name. Use the typed accessors to decode
+// single results.
+type AggregationResults map[string]json.RawMessage
+
+func (results AggregationResults) decode(name string, i interface{}) error {
+	raw, ok := results[name]
+	if !ok {
+		return fmt.Errorf("aggregation %q not found", name)
+	}
+	return json.Unmarshal(raw, i)
+}
+
+// Buckets decodes the result of any bucket aggregation (terms, date_histogram, histogram, range).
+func (results AggregationResults) Buckets(name string) (*BucketsResult, error) {
+	r := &BucketsResult{}
+	return r, results.decode(name, r)
+}
+
+func (results AggregationResults) Terms(name string) (*BucketsResult, error) {
+	return results.Buckets(name)
+}
+
+func (results AggregationResults) DateHistogram(name string) (*BucketsResult, error) {
+	return results.Buckets(name)
+}
+
+func (results AggregationResults) Histogram(name string) (*BucketsResult, error) {
+	return results.Buckets(name)
+}
+
+func (results AggregationResults) Range(name string) (*BucketsResult, error) {
+	return results.Buckets(name)
+}
+
+func (results AggregationResults) Stats(name string) (*StatsResult, error) {
+	r := &StatsResult{}
+	return r, results.decode(name, r)
+}
+
+func (results AggregationResults) Percentiles(name string) (*PercentilesResult, error) {
+	r := &PercentilesResult{}
+	return r, results.decode(name, r)
+}
+
+func (results AggregationResults) Cardinality(name string) (*CardinalityResult, error) {
+	r := &CardinalityResult{}
+	return r, results.decode(name, r)
+}
+
+// Nested decodes the result of a nested aggregation (doc count and sub aggregations).
+func (results AggregationResults) Nested(name string) (*Bucket, error) {
+	r := &Bucket{}
+	return r, results.decode(name, r)
+}
+
+type BucketsResult struct {
+	DocCountErrorUpperBound int64     `json:"doc_count_error_upper_bound"`
+	SumOtherDocCount        int64     `json:"sum_other_doc_count"`
+	Buckets                 []*Bucket `json:"-"`
+}
+
+func (result *BucketsResult) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		DocCountErrorUpperBound int64           `json:"doc_count_error_upper_bound"`
+		SumOtherDocCount        int64           `json:"sum_other_doc_count"`
+		Buckets                 json.RawMessage `json:"buckets"`
+	}
+	if e := json.Unmarshal(b, &raw); e != nil {
+		return e
+	}
+	result.DocCountErrorUpperBound = raw.DocCountErrorUpperBound
+	result.SumOtherDocCount = raw.SumOtherDocCount
+	result.Buckets = nil
+	trimmed := bytes.TrimSpace(raw.Buckets)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return json.Unmarshal(raw.Buckets, &result.Buckets)
+	}
+	// keyed buckets
+	keyed := map[string]*Bucket{}
+	if e := json.Unmarshal(raw.Buckets, &keyed); e != nil {
+		return e
+	}
+	keys := make([]string, 0, len(keyed))
+	for k := range keyed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		bucket := keyed[k]
+		if bucket.Key == nil {
+			bucket.Key = k
+		}
+		result.Buckets = append(result.Buckets, bucket)
+	}
+	return nil
+}
+
+// Bucket returns the bucket with the given key or nil.
+func (result *BucketsResult) Bucket(key string) *Bucket {
+	for _, b := range result.Buckets {
+		if b.KeyString() == key {
+			return b
+		}
+	}
+	return nil
+}
+
+type Bucket struct {
+	Key          interface{}        `json:"key"`
+	KeyAsString  string             `json:"key_as_string,omitempty"`
+	DocCount     int64              `json:"doc_count"`
+	From         *float64           `json:"from,omitempty"`
+	To           *float64           `json:"to,omitempty"`
+	Aggregations AggregationResults `json:"-"` // sub aggregations
+}
+
+var bucketKeys = map[string]bool{
+	"key": true, "key_as_string": true, "doc_count": true, "from": true, "to": true,
+	"from_as_string": true, "to_as_string": true,
+}
+
+func (bucket *Bucket) UnmarshalJSON(b []byte) error {
+	raw := map[string]json.RawMessage{}
+	if e := json.Unmarshal(b, &raw); e != nil {
+		return e
+	}
+	type plain Bucket
+	p := (*plain)(bucket)
+	if e := json.Unmarshal(b, p); e != nil {
+		return e
+	}
+	for k, v := range raw {
+		if bucketKeys[k] {
+			continue
+		}
+		if bucket.Aggregations == nil {
+			bucket.Aggregations = AggregationResults{}
+		}
+		bucket.Aggregations[k] = v
+	}
+	return nil
+}
+
+// KeyString returns the key as string, e.g. for keys of histograms which are numbers.
+func (bucket *Bucket) KeyString() string {
+	if bucket.KeyAsString != "" {
+		return bucket.KeyAsString
+	}
+	switch k := bucket.Key.(type) {
+	case string:
+		return k
+	case float64:
+		return strconv.FormatFloat(k, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(k)
+	}
+}
+
+type StatsResult struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Sum   float64 `json:"sum"`
+
+	// only set for extended_stats
+	SumOfSquares float64 `json:"sum_of_squares"`
+	Variance     float64 `json:"variance"`
+	StdDeviation float64 `json:"std_deviation"`
+}
+
+type PercentilesResult struct {
+	Values map[string]float64 `json:"values"`
+}
+
+// Percentile returns the value for percentile p (e.g. 95 or 99.9).
+func (result *PercentilesResult) Percentile(p float64) (float64, bool) {
+	key := strconv.FormatFloat(p, 'f', -1, 64)
+	if !strings.Contains(key, ".") {
+		key += ".0"
+	}
+	v, ok := result.Values[key]
+	return v, ok
+}
+
+type CardinalityResult struct {
+	Value int64 `json:"value"`
+}
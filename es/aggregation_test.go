@@ -0,0 +1,121 @@
+package es
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const aggregationsResponse = `{
+  "took": 3,
+  "timed_out": false,
+  "hits": {"total": 120, "max_score": 0, "hits": []},
+  "aggregations": {
+    "hosts": {
+      "doc_count_error_upper_bound": 0,
+      "sum_other_doc_count": 20,
+      "buckets": [
+        {
+          "key": "he-host1",
+          "doc_count": 80,
+          "per_day": {
+            "buckets": [
+              {"key_as_string": "2026-10-15", "key": 1760486400000, "doc_count": 30},
+              {"key_as_string": "2026-10-16", "key": 1760572800000, "doc_count": 50}
+            ]
+          },
+          "time": {"count": 80, "min": 0.01, "max": 2.5, "avg": 0.3, "sum": 24}
+        },
+        {"key": "he-host2", "doc_count": 20}
+      ]
+    },
+    "lengths": {"buckets": [{"key": 0, "doc_count": 10}, {"key": 1000, "doc_count": 2}]},
+    "slow": {
+      "buckets": {
+        "fast": {"to": 1, "doc_count": 100},
+        "slow": {"from": 1, "doc_count": 20}
+      }
+    },
+    "time_percentiles": {"values": {"50.0": 0.2, "95.0": 1.1, "99.9": 2.4}},
+    "users": {"value": 42},
+    "requests": {"doc_count": 7, "methods": {"buckets": [{"key": "GET", "doc_count": 7}]}}
+  }
+}`
+
+func TestAggregationRequest(t *testing.T) {
+	Convey("Aggregation request", t, func() {
+		req := &Request{}
+		req.AddAggregation("hosts", TermsAgg("Host", 10).
+			Add("per_day", DateHistogramAgg("Time", "1d")).
+			Add("time", StatsAgg("TotalTime")),
+		)
+		req.AddAggregation("slow", RangeAgg("TotalTime", &AggregationRange{Key: "fast", To: 1}, &AggregationRange{Key: "slow", From: 1}))
+		req.AddAggregation("time_percentiles", PercentilesAgg("TotalTime", 50, 95, 99.9))
+		req.AddAggregation("users", CardinalityAgg("UserId"))
+		b, e := json.Marshal(req)
+		So(e, ShouldBeNil)
+		So(string(b), ShouldEqual, `{"size":0,"aggs":{`+
+			`"hosts":{"terms":{"field":"Host","size":10},"aggs":{"per_day":{"date_histogram":{"field":"Time","calendar_interval":"1d"}},"time":{"stats":{"field":"TotalTime"}}}},`+
+			`"slow":{"range":{"field":"TotalTime","ranges":[{"key":"fast","to":1},{"key":"slow","from":1}]}},`+
+			`"time_percentiles":{"percentiles":{"field":"TotalTime","percents":[50,95,99.9]}},`+
+			`"users":{"cardinality":{"field":"UserId"}}}}`)
+	})
+}
+
+func TestAggregationResults(t *testing.T) {
+	Convey("Aggregation results", t, func() {
+		rsp := &Response{}
+		So(json.Unmarshal([]byte(aggregationsResponse), rsp), ShouldBeNil)
+
+		hosts, e := rsp.Aggregations.Terms("hosts")
+		So(e, ShouldBeNil)
+		So(hosts.SumOtherDocCount, ShouldEqual, 20)
+		So(len(hosts.Buckets), ShouldEqual, 2)
+		host := hosts.Bucket("he-host1")
+		So(host, ShouldNotBeNil)
+		So(host.DocCount, ShouldEqual, 80)
+
+		perDay, e := host.Aggregations.DateHistogram("per_day")
+		So(e, ShouldBeNil)
+		So(len(perDay.Buckets), ShouldEqual, 2)
+		So(perDay.Buckets[1].KeyString(), ShouldEqual, "2026-10-16")
+		So(perDay.Buckets[1].DocCount, ShouldEqual, 50)
+
+		stats, e := host.Aggregations.Stats("time")
+		So(e, ShouldBeNil)
+		So(stats.Count, ShouldEqual, 80)
+		So(stats.Max, ShouldEqual, 2.5)
+
+		lengths, e := rsp.Aggregations.Histogram("lengths")
+		So(e, ShouldBeNil)
+		So(lengths.Bucket("1000").DocCount, ShouldEqual, 2)
+
+		slow, e := rsp.Aggregations.Range("slow")
+		So(e, ShouldBeNil)
+		So(slow.Bucket("slow").DocCount, ShouldEqual, 20)
+		So(*slow.Bucket("fast").To, ShouldEqual, 1)
+
+		perc, e := rsp.Aggregations.Percentiles("time_percentiles")
+		So(e, ShouldBeNil)
+		v, ok := perc.Percentile(95)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, 1.1)
+		v, ok = perc.Percentile(99.9)
+		So(v, ShouldEqual, 2.4)
+
+		users, e := rsp.Aggregations.Cardinality("users")
+		So(e, ShouldBeNil)
+		So(users.Value, ShouldEqual, 42)
+
+		requests, e := rsp.Aggregations.Nested("requests")
+		So(e, ShouldBeNil)
+		So(requests.DocCount, ShouldEqual, 7)
+		methods, e := requests.Aggregations.Terms("methods")
+		So(e, ShouldBeNil)
+		So(methods.Buckets[0].KeyString(), ShouldEqual, "GET")
+
+		_, e = rsp.Aggregations.Terms("missing")
+		So(e, ShouldNotBeNil)
+	})
+}
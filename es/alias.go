@@ -0,0 +1,120 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+type AliasOptions struct {
+	Filter       *Query `json:"filter,omitempty"`
+	Routing      string `json:"routing,omitempty"`
+	IsWriteIndex *bool  `json:"is_write_index,omitempty"`
+	IsHidden     *bool  `json:"is_hidden,omitempty"`
+}
+
+// AliasAction is a single action of an atomic alias update. Exactly one of the fields must be set.
+type AliasAction struct {
+	Add         *AliasActionOptions `json:"add,omitempty"`
+	Remove      *AliasActionOptions `json:"remove,omitempty"`
+	RemoveIndex *AliasActionOptions `json:"remove_index,omitempty"`
+}
+
+type AliasActionOptions struct {
+	Index string `json:"index"`
+	Alias string `json:"alias,omitempty"`
+	AliasOptions
+}
+
+func AddAlias(index, alias string) *AliasAction {
+	return &AliasAction{Add: &AliasActionOptions{Index: index, Alias: alias}}
+}
+
+// AddWriteAlias adds the alias with is_write_index set to write.
+func AddWriteAlias(index, alias string, write bool) *AliasAction {
+	a := AddAlias(index, alias)
+	a.Add.IsWriteIndex = &write
+	return a
+}
+
+func RemoveAlias(index, alias string) *AliasAction {
+	return &AliasAction{Remove: &AliasActionOptions{Index: index, Alias: alias}}
+}
+
+// UpdateAliases executes all actions atomically.
+func (client *Client) UpdateAliases(ctx context.Context, actions ...*AliasAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	_, e := client.Do(ctx, "POST", "/_aliases", map[string][]*AliasAction{"actions": actions})
+	if e != nil {
+		return fmt.Errorf("Error updating aliases: %w", e)
+	}
+	return nil
+}
+
+// Aliases returns the options of the alias by index name. The map is empty when the alias does not exist.
+func (client *Client) Aliases(ctx context.Context, alias string) (map[string]*AliasOptions, error) {
+	rsp, e := client.Do(ctx, "GET", "/_alias/"+url.PathEscape(alias), nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return map[string]*AliasOptions{}, nil
+	} else if e != nil {
+		return nil, e
+	}
+	raw := map[string]struct {
+		Aliases map[string]*AliasOptions `json:"aliases"`
+	}{}
+	if e := json.Unmarshal(rsp.Body, &raw); e != nil {
+		return nil, e
+	}
+	aliases := map[string]*AliasOptions{}
+	for index, a := range raw {
+		if options, ok := a.Aliases[alias]; ok {
+			if options == nil {
+				options = &AliasOptions{}
+			}
+			aliases[index] = options
+		}
+	}
+	return aliases, nil
+}
+
+// AliasIndices returns the sorted names of all indices the alias points to.
+func (client *Client) AliasIndices(ctx context.Context, alias string) ([]string, error) {
+	aliases, e := client.Aliases(ctx, alias)
+	if e != nil {
+		return nil, e
+	}
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SwapAlias atomically moves the alias from all indices it currently points to to index.
+func (client *Client) SwapAlias(ctx context.Context, alias, index string) error {
+	current, e := client.AliasIndices(ctx, alias)
+	if e != nil {
+		return e
+	}
+	actions := []*AliasAction{}
+	for _, name := range current {
+		if name != index {
+			actions = append(actions, RemoveAlias(name, alias))
+		}
+	}
+	actions = append(actions, AddAlias(index, alias))
+	return client.UpdateAliases(ctx, actions...)
+}
+
+func (index *Index) AddAlias(ctx context.Context, alias string) error {
+	return index.client().UpdateAliases(ctx, AddAlias(index.Index, alias))
+}
+
+func (index *Index) RemoveAlias(ctx context.Context, alias string) error {
+	return index.client().UpdateAliases(ctx, RemoveAlias(index.Index, alias))
+}
@@ -0,0 +1,125 @@
+package es
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func createIndices(client *Client, names ...string) {
+	for _, name := range names {
+		if _, e := client.Do(context.Background(), "PUT", "/"+name, nil); e != nil {
+			panic(e)
+		}
+	}
+}
+
+// requestBody returns the body of the first request req, e.g. "PUT /logs".
+func requestBody(s *estest.Server, req string) string {
+	bodies := s.Bodies()
+	for i, r := range s.Requests() {
+		if r == req {
+			return strings.TrimSpace(bodies[i])
+		}
+	}
+	return ""
+}
+
+func TestAliases(t *testing.T) {
+	Convey("Aliases", t, func() {
+		ctx := context.Background()
+		s := estest.NewServer()
+		defer s.Close()
+		client := NewClient(s.URL)
+		createIndices(client, "logs-a", "logs-b")
+
+		Convey("index templates", func() {
+			t, e := client.IndexTemplate(ctx, "logs")
+			So(e, ShouldBeNil)
+			So(t, ShouldBeNil)
+			So(client.PutIndexTemplate(ctx, "logs", &IndexTemplate{
+				IndexPatterns: []string{"logs-*"},
+				Template:      &IndexTemplateBody{Settings: map[string]int{"number_of_shards": 1}},
+			}), ShouldBeNil)
+			So(requestBody(s, "PUT /_index_template/logs"), ShouldEqual, `{"index_patterns":["logs-*"],"template":{"settings":{"number_of_shards":1}}}`)
+			t, e = client.IndexTemplate(ctx, "logs")
+			So(e, ShouldBeNil)
+			So(t.IndexPatterns, ShouldResemble, []string{"logs-*"})
+		})
+
+		Convey("add, remove and swap", func() {
+			names, e := client.AliasIndices(ctx, "logs")
+			So(e, ShouldBeNil)
+			So(names, ShouldBeEmpty)
+
+			So(client.UpdateAliases(ctx, AddAlias("logs-a", "logs"), AddAlias("logs-b", "logs")), ShouldBeNil)
+			names, e = client.AliasIndices(ctx, "logs")
+			So(e, ShouldBeNil)
+			So(names, ShouldResemble, []string{"logs-a", "logs-b"})
+
+			So(client.SwapAlias(ctx, "logs", "logs-b"), ShouldBeNil)
+			names, _ = client.AliasIndices(ctx, "logs")
+			So(names, ShouldResemble, []string{"logs-b"})
+
+			index := &Index{Client: client, Index: "logs-a"}
+			So(index.AddAlias(ctx, "logs"), ShouldBeNil)
+			So(index.RemoveAlias(ctx, "logs"), ShouldBeNil)
+			names, _ = client.AliasIndices(ctx, "logs")
+			So(names, ShouldResemble, []string{"logs-b"})
+		})
+	})
+}
+
+func TestDailyIndex(t *testing.T) {
+	Convey("DailyIndex", t, func() {
+		ctx := context.Background()
+		s := estest.NewServer()
+		defer s.Close()
+		client := NewClient(s.URL)
+		createIndices(client, "logs-2026.10.10", "logs-2026.10.13", "logs-2026.10.14", "logs-other", "metrics-2026.10.01")
+		now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+		daily := &DailyIndex{
+			Client:    client,
+			Prefix:    "logs",
+			Retention: 3 * 24 * time.Hour,
+			Config:    map[string]interface{}{"settings": map[string]int{"number_of_shards": 1}},
+			now:       func() time.Time { return now },
+		}
+		So(daily.Client.UpdateAliases(ctx, AddWriteAlias("logs-2026.10.14", "logs", true)), ShouldBeNil)
+
+		So(daily.Name(now), ShouldEqual, "logs-2026.10.16")
+		day, ok := daily.Day("logs-2026.10.13")
+		So(ok, ShouldBeTrue)
+		So(day, ShouldResemble, time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC))
+		_, ok = daily.Day("logs-other")
+		So(ok, ShouldBeFalse)
+
+		expired, e := daily.Expired(ctx)
+		So(e, ShouldBeNil)
+		So(expired, ShouldResemble, []string{"logs-2026.10.10"})
+
+		name, e := daily.Rollover(ctx)
+		So(e, ShouldBeNil)
+		So(name, ShouldEqual, "logs-2026.10.16")
+		So(requestBody(s, "PUT /"+name), ShouldEqual, `{"settings":{"number_of_shards":1}}`)
+		So(s.Indices(), ShouldResemble, []string{"logs-2026.10.13", "logs-2026.10.14", "logs-2026.10.16", "logs-other", "metrics-2026.10.01"})
+
+		aliases, e := daily.Client.Aliases(ctx, "logs")
+		So(e, ShouldBeNil)
+		So(len(aliases), ShouldEqual, 2)
+		So(*aliases["logs-2026.10.16"].IsWriteIndex, ShouldBeTrue)
+		So(*aliases["logs-2026.10.14"].IsWriteIndex, ShouldBeFalse)
+		So(daily.WriteIndex().Index, ShouldEqual, "logs")
+
+		Convey("is idempotent", func() {
+			name, e := daily.Rollover(ctx)
+			So(e, ShouldBeNil)
+			So(name, ShouldEqual, "logs-2026.10.16")
+			So(len(s.Indices()), ShouldEqual, 5)
+		})
+	})
+}
@@ -0,0 +1,268 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBulkRetries = 3
+	DefaultBulkBackoff = 500 * time.Millisecond
+)
+
+type BulkResponse struct {
+	Took   int                          `json:"took"`
+	Errors bool                         `json:"errors"`
+	Items  []map[string]*BulkItemResult `json:"items"`
+}
+
+// Results returns the result of every item in the order of the request. Malformed items (e.g. `{}` or
+// `{"index":null}`) are returned as failed results with an invalid_bulk_item error.
+func (rsp *BulkResponse) Results() []*BulkItemResult {
+	results := make([]*BulkItemResult, 0, len(rsp.Items))
+	for _, item := range rsp.Items {
+		var result *BulkItemResult
+		for action, r := range item {
+			if r == nil {
+				result = invalidBulkItem(action, "item has no result")
+				continue
+			}
+			r.Action = action
+			result = r
+		}
+		if result == nil {
+			result = invalidBulkItem("", "item has no action")
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func invalidBulkItem(action, reason string) *BulkItemResult {
+	return &BulkItemResult{Action: action, Error: &BulkItemError{Type: "invalid_bulk_item", Reason: reason}}
+}
+
+type BulkItemResult struct {
+	Action      string         `json:"-"`
+	Index       string         `json:"_index"`
+	Type        string         `json:"_type,omitempty"`
+	Id          string         `json:"_id"`
+	Version     int64          `json:"_version,omitempty"`
+	Result      string         `json:"result,omitempty"` // created, updated, deleted, noop or not_found
+	Status      int            `json:"status"`
+	SeqNo       int64          `json:"_seq_no,omitempty"`
+	PrimaryTerm int64          `json:"_primary_term,omitempty"`
+	Error       *BulkItemError `json:"error,omitempty"`
+}
+
+func (result *BulkItemResult) Failed() bool {
+	return result.Status/100 != 2
+}
+
+// Retryable returns true for items rejected because of load (e.g. es_rejected_execution_exception).
+func (result *BulkItemResult) Retryable() bool {
+	return result.Failed() && retryable(result.Status)
+}
+
+type BulkItemError struct {
+	Type     string         `json:"type"`
+	Reason   string         `json:"reason"`
+	CausedBy *BulkItemError `json:"caused_by,omitempty"`
+}
+
+func (e *BulkItemError) Error() string {
+	if e.CausedBy != nil {
+		return e.Type + ": " + e.Reason + " (" + e.CausedBy.Error() + ")"
+	}
+	return e.Type + ": " + e.Reason
+}
+
+// Is reports whether the type of the error or of its causes matches ErrNotFound, ErrConflict,
+// ErrIndexAlreadyExists or ErrRejectedExecution.
+func (e *BulkItemError) Is(target error) bool {
+	for c := e; c != nil; c = c.CausedBy {
+		if typeMatches(c.Type, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type BulkFailure struct {
+	Doc    *Doc
+	Result *BulkItemResult
+}
+
+// BulkError is returned by IndexDocs when items failed permanently and no FailureHandler is set. Err is set
+// when a later bulk request (e.g. retrying rejected items) failed as a whole.
+type BulkError struct {
+	Failures []*BulkFailure
+	Err      error
+}
+
+func (e *BulkError) Error() string {
+	if len(e.Failures) == 0 {
+		if e.Err != nil {
+			return e.Err.Error()
+		}
+		return "bulk request failed"
+	}
+	first := e.Failures[0].Result
+	reason := fmt.Sprintf("status %d", first.Status)
+	if first.Error != nil {
+		reason = first.Error.Error()
+	}
+	msg := fmt.Sprintf("%d of the bulk items failed, first: %s %s: %s", len(e.Failures), first.Action, first.Id, reason)
+	if e.Err != nil {
+		msg += ", then: " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+// FailureHandler receives docs which could not be indexed.
+type FailureHandler func(doc *Doc, result *BulkItemResult)
+
+// DeadLetterWriter returns a FailureHandler writing every failed doc as a JSON line to w.
+func DeadLetterWriter(w io.Writer) FailureHandler {
+	lock := &sync.Mutex{}
+	enc := json.NewEncoder(w)
+	return func(doc *Doc, result *BulkItemResult) {
+		lock.Lock()
+		defer lock.Unlock()
+		enc.Encode(map[string]interface{}{
+			"action": doc.action(),
+			"_index": doc.Index,
+			"_id":    doc.Id,
+			"source": doc.Source,
+			"status": result.Status,
+			"error":  result.Error,
+		})
+	}
+}
+
+// encodeBulk returns the bulk request body of docs. Docs found in encoded are not encoded again, their bytes
+// are used as they are.
+func encodeBulk(docs []*Doc, encoded map[*Doc][]byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, doc := range docs {
+		if b, ok := encoded[doc]; ok {
+			buf.Write(b)
+			continue
+		}
+		if doc.Id == "" && (doc.action() == BulkUpdate || doc.action() == BulkDelete) {
+			return nil, fmt.Errorf("%s requires an id", doc.action())
+		}
+		if e := enc.Encode(map[string]map[string]string{doc.action(): doc.IndexAttributes()}); e != nil {
+			return nil, e
+		}
+		if source := doc.bulkSource(); source != nil {
+			if e := enc.Encode(source); e != nil {
+				return nil, e
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Bulk sends all docs in one bulk request and returns the parsed response. Failed items are not retried.
+func (index *Index) Bulk(ctx context.Context, docs []*Doc) (*BulkResponse, error) {
+	return index.bulk(ctx, docs, nil)
+}
+
+func (index *Index) bulk(ctx context.Context, docs []*Doc, encoded map[*Doc][]byte) (*BulkResponse, error) {
+	for _, doc := range docs {
+		if doc.Index == "" {
+			doc.Index = index.Index
+		}
+		if doc.Type == "" {
+			doc.Type = index.Type
+		}
+	}
+	body, e := encodeBulk(docs, encoded)
+	if e != nil {
+		return nil, e
+	}
+	httpResponse, e := index.client().DoWithContentType(ctx, "POST", "/_bulk", "application/x-ndjson", body)
+	if e != nil {
+		return nil, fmt.Errorf("Error sending bulk request: %w", e)
+	}
+	rsp := &BulkResponse{}
+	if e := json.Unmarshal(httpResponse.Body, rsp); e != nil {
+		return nil, e
+	}
+	if len(rsp.Items) != len(docs) {
+		return rsp, fmt.Errorf("expected %d bulk items, got %d", len(docs), len(rsp.Items))
+	}
+	return rsp, nil
+}
+
+// BulkWithRetries sends the docs and retries rejected items with exponential backoff. It returns all items
+// which failed permanently, also together with an error when a retry could not be sent.
+func (index *Index) BulkWithRetries(ctx context.Context, docs []*Doc) ([]*BulkFailure, error) {
+	return index.bulkWithRetries(ctx, docs, nil)
+}
+
+func (index *Index) bulkWithRetries(ctx context.Context, docs []*Doc, encoded map[*Doc][]byte) ([]*BulkFailure, error) {
+	retries := index.BulkRetries
+	if retries == 0 {
+		retries = DefaultBulkRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+	backoff := index.BulkBackoff
+	if backoff == 0 {
+		backoff = DefaultBulkBackoff
+	}
+	failures := []*BulkFailure{}
+	pending := docs
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			index.LogDebug("retrying %d rejected bulk items", len(pending))
+			if e := sleepContext(ctx, backoff); e != nil {
+				return failures, e
+			}
+			backoff *= 2
+		}
+		rsp, e := index.bulk(ctx, pending, encoded)
+		if e != nil {
+			return failures, e
+		}
+		retry := []*Doc{}
+		for i, result := range rsp.Results() {
+			switch {
+			case !result.Failed():
+			case result.Retryable() && attempt < retries:
+				retry = append(retry, pending[i])
+			default:
+				failures = append(failures, &BulkFailure{Doc: pending[i], Result: result})
+			}
+		}
+		pending = retry
+	}
+	return failures, nil
+}
+
+// handleFailures passes the failures to OnBulkFailure or returns them in a *BulkError. e is the error which
+// stopped BulkWithRetries, failures collected before are delivered anyway.
+func (index *Index) handleFailures(failures []*BulkFailure, e error) error {
+	if len(failures) == 0 {
+		return e
+	}
+	if index.OnBulkFailure == nil {
+		return &BulkError{Failures: failures, Err: e}
+	}
+	for _, f := range failures {
+		index.OnBulkFailure(f.Doc, f.Result)
+	}
+	return e
+}
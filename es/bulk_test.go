@@ -0,0 +1,197 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func bulkIndex(s *estest.Server) *Index {
+	return &Index{Index: "test", Client: NewClient(s.URL), BulkBackoff: time.Millisecond}
+}
+
+// bulkLines returns the lines of all bulk requests.
+func bulkLines(s *estest.Server) [][]string {
+	requests := [][]string{}
+	for _, body := range s.Bodies() {
+		requests = append(requests, strings.Split(strings.TrimSpace(body), "\n"))
+	}
+	return requests
+}
+
+func TestBulk(t *testing.T) {
+	Convey("Bulk", t, func() {
+		Convey("encodes create, update and delete actions", func() {
+			body, e := encodeBulk([]*Doc{
+				{Index: "test", Id: "1", Action: BulkCreate, Source: map[string]int{"a": 1}},
+				{Index: "test", Id: "2", Action: BulkUpdate, Source: map[string]int{"a": 2}},
+				{Index: "test", Id: "3", Action: BulkUpdate, Source: &UpdateBody{Doc: map[string]int{"a": 3}, DocAsUpsert: true}},
+				{Index: "test", Id: "4", Action: BulkDelete},
+				{Index: "test", Source: map[string]int{"a": 5}},
+			}, nil)
+			So(e, ShouldBeNil)
+			So(strings.Split(strings.TrimSpace(string(body)), "\n"), ShouldResemble, []string{
+				`{"create":{"_id":"1","_index":"test"}}`,
+				`{"a":1}`,
+				`{"update":{"_id":"2","_index":"test"}}`,
+				`{"doc":{"a":2}}`,
+				`{"update":{"_id":"3","_index":"test"}}`,
+				`{"doc":{"a":3},"doc_as_upsert":true}`,
+				`{"delete":{"_id":"4","_index":"test"}}`,
+				`{"index":{"_index":"test"}}`,
+				`{"a":5}`,
+			})
+
+			_, e = encodeBulk([]*Doc{{Action: BulkDelete}}, nil)
+			So(e, ShouldNotBeNil)
+		})
+
+		Convey("returns per item results", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			rsp, e := bulkIndex(s).Bulk(context.Background(), []*Doc{
+				{Id: "1", Source: map[string]int{"a": 1}},
+				{Id: "2", Action: BulkDelete},
+			})
+			So(e, ShouldBeNil)
+			// like Elasticsearch, deleting a missing doc fails the item without an error
+			So(rsp.Errors, ShouldBeFalse)
+			results := rsp.Results()
+			So(len(results), ShouldEqual, 2)
+			So(results[0].Action, ShouldEqual, BulkIndex)
+			So(results[0].Index, ShouldEqual, "test")
+			So(results[0].Failed(), ShouldBeFalse)
+			So(results[1].Action, ShouldEqual, BulkDelete)
+			So(results[1].Failed(), ShouldBeTrue)
+			So(results[1].Result, ShouldEqual, "not_found")
+			So(results[1].Retryable(), ShouldBeFalse)
+		})
+
+		Convey("returns failed results for malformed items", func() {
+			rsp := &BulkResponse{}
+			So(json.Unmarshal([]byte(`{"errors":true,"items":[{"index":null},{},{"delete":{"_id":"3","status":200}}]}`), rsp), ShouldBeNil)
+			results := rsp.Results()
+			So(len(results), ShouldEqual, 3)
+			So(results[0].Action, ShouldEqual, BulkIndex)
+			So(results[0].Failed(), ShouldBeTrue)
+			So(results[0].Retryable(), ShouldBeFalse)
+			So(results[0].Error.Type, ShouldEqual, "invalid_bulk_item")
+			So(results[1].Failed(), ShouldBeTrue)
+			So(results[1].Error.Reason, ShouldEqual, "item has no action")
+			So(results[2].Failed(), ShouldBeFalse)
+
+			s := estest.NewServer()
+			defer s.Close()
+			s.Respond("POST", "/_bulk", 200, `{"errors":true,"items":[{"index":null},{}]}`)
+			failures, e := (&Index{Index: "test", Client: NewClient(s.URL)}).BulkWithRetries(context.Background(), []*Doc{
+				{Id: "1", Source: map[string]int{"a": 1}},
+				{Id: "2", Source: map[string]int{"a": 2}},
+			})
+			So(e, ShouldBeNil)
+			So(len(failures), ShouldEqual, 2)
+			So(failures[0].Doc.Id, ShouldEqual, "1")
+		})
+
+		Convey("retries only rejected items", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			rejected := map[string]bool{}
+			s.FailBulkItems(func(action, index, id string) int {
+				switch {
+				case id == "2" && !rejected[id]:
+					rejected[id] = true
+					return 429
+				case id == "3":
+					return 400
+				}
+				return 0
+			})
+			docs := []*Doc{
+				{Id: "1", Source: map[string]int{"age": 1}},
+				{Id: "2", Source: map[string]int{"age": 2}},
+				{Id: "3", Source: map[string]string{"age": "three"}},
+			}
+
+			Convey("and returns a BulkError for permanent failures", func() {
+				e := bulkIndex(s).IndexDocs(docs)
+				So(e, ShouldNotBeNil)
+				bulkErr, ok := e.(*BulkError)
+				So(ok, ShouldBeTrue)
+				So(len(bulkErr.Failures), ShouldEqual, 1)
+				So(bulkErr.Failures[0].Doc.Id, ShouldEqual, "3")
+				So(bulkErr.Error(), ShouldContainSubstring, "mapper_parsing_exception")
+				requests := bulkLines(s)
+				So(len(requests), ShouldEqual, 2)
+				So(len(requests[0]), ShouldEqual, 6)
+				So(requests[1], ShouldResemble, []string{`{"index":{"_id":"2","_index":"test"}}`, `{"age":2}`})
+			})
+
+			Convey("and passes permanent failures to OnBulkFailure", func() {
+				buf := &bytes.Buffer{}
+				index := bulkIndex(s)
+				index.OnBulkFailure = DeadLetterWriter(buf)
+				So(index.IndexDocs(docs), ShouldBeNil)
+				var dead map[string]interface{}
+				So(json.Unmarshal(buf.Bytes(), &dead), ShouldBeNil)
+				So(dead["_id"], ShouldEqual, "3")
+				So(dead["status"], ShouldEqual, 400)
+				So(dead["source"], ShouldResemble, map[string]interface{}{"age": "three"})
+			})
+		})
+
+		Convey("delivers permanent failures when a retry fails", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			// the retry of the rejected item fails as a whole
+			failRetry := func() {
+				s.Reset()
+				s.Respond("POST", "/_bulk", 200, `{"errors":true,"items":[`+
+					`{"index":{"_id":"1","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}},`+
+					`{"index":{"_id":"2","status":429,"error":{"type":"es_rejected_execution_exception","reason":"rejected"}}}]}`)
+				s.Respond("POST", "/_bulk", 500, "")
+			}
+			client := NewClient(s.URL)
+			client.MaxRetries = -1
+			index := &Index{Index: "test", Client: client, BulkBackoff: time.Millisecond}
+			docs := []*Doc{{Id: "1", Source: map[string]string{"age": "one"}}, {Id: "2", Source: map[string]int{"age": 2}}}
+
+			failRetry()
+			e := index.IndexDocs(docs)
+			So(len(s.Requests()), ShouldEqual, 2)
+			bulkErr, ok := e.(*BulkError)
+			So(ok, ShouldBeTrue)
+			So(len(bulkErr.Failures), ShouldEqual, 1)
+			So(bulkErr.Failures[0].Doc.Id, ShouldEqual, "1")
+			So(bulkErr.Err, ShouldNotBeNil)
+			So(e.Error(), ShouldContainSubstring, "then: Error sending bulk request")
+
+			failRetry()
+			failed := []string{}
+			index.OnBulkFailure = func(doc *Doc, result *BulkItemResult) { failed = append(failed, doc.Id) }
+			e = index.IndexDocs(docs)
+			So(e, ShouldNotBeNil)
+			_, ok = e.(*BulkError)
+			So(ok, ShouldBeFalse)
+			So(failed, ShouldResemble, []string{"1"})
+		})
+
+		Convey("gives up retrying after BulkRetries", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			s.FailBulkItems(func(action, index, id string) int { return 429 })
+			index := bulkIndex(s)
+			index.BulkRetries = 2
+			failures, e := index.BulkWithRetries(context.Background(), []*Doc{{Id: "1", Source: map[string]int{}}})
+			So(e, ShouldBeNil)
+			So(len(failures), ShouldEqual, 1)
+			So(failures[0].Result.Status, ShouldEqual, 429)
+			So(len(s.Requests()), ShouldEqual, 3)
+		})
+	})
+}
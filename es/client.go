@@ -0,0 +1,315 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMaxRetries  = 3
+	DefaultBackoff     = 100 * time.Millisecond
+	DefaultMaxBackoff  = 5 * time.Second
+	DefaultDeadTimeout = 30 * time.Second
+)
+
+// Client sends requests to one or more Elasticsearch nodes. Nodes are used round-robin, nodes which could not
+// be reached are skipped for DeadTimeout. Requests failing with a network error, 429 or 5xx are retried on the
+// next node with exponential backoff.
+//
+// Only idempotent requests (GET, HEAD, PUT and DELETE) are retried after they were sent. Other requests (e.g.
+// POST to _update, _bulk or _doc without id) might have been applied before a timeout or a 502 and are only
+// retried when the connection could not be established, unless RetryAll is set.
+type Client struct {
+	Nodes      []string     // e.g. https://es1.example.com:9200
+	HTTPClient *http.Client // defaults to a client using TLSConfig
+	TLSConfig  *tls.Config  // only used when HTTPClient is nil
+
+	Username string // basic auth
+	Password string
+	APIKey   string // base64 encoded id:api_key, takes precedence over basic auth
+
+	MaxRetries  int           // defaults to DefaultMaxRetries, -1 disables retries
+	Backoff     time.Duration // initial backoff, defaults to DefaultBackoff
+	MaxBackoff  time.Duration // also caps waits requested by Retry-After, defaults to DefaultMaxBackoff
+	DeadTimeout time.Duration // defaults to DefaultDeadTimeout
+	RetryAll    bool          // also retry non idempotent requests which were sent, e.g. when all POSTs are searches
+
+	Logger Logger
+
+	next       uint32
+	dead       map[string]time.Time
+	lock       sync.Mutex
+	httpClient *http.Client
+}
+
+func NewClient(nodes ...string) *Client {
+	return &Client{Nodes: nodes}
+}
+
+func (client *Client) client() *http.Client {
+	if client.HTTPClient != nil {
+		return client.HTTPClient
+	}
+	if client.TLSConfig == nil {
+		return http.DefaultClient
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = client.TLSConfig
+		client.httpClient = &http.Client{Transport: transport}
+	}
+	return client.httpClient
+}
+
+func (client *Client) maxRetries() int {
+	switch {
+	case client.MaxRetries < 0:
+		return 0
+	case client.MaxRetries == 0:
+		return DefaultMaxRetries
+	}
+	return client.MaxRetries
+}
+
+func (client *Client) maxBackoff() time.Duration {
+	if client.MaxBackoff > 0 {
+		return client.MaxBackoff
+	}
+	return DefaultMaxBackoff
+}
+
+func (client *Client) backoff(attempt int) time.Duration {
+	d := client.Backoff
+	if d == 0 {
+		d = DefaultBackoff
+	}
+	max := client.maxBackoff()
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// node returns the next node which is not marked as dead. When all nodes are dead the next one is used anyway.
+func (client *Client) node() (string, error) {
+	if len(client.Nodes) == 0 {
+		return "", fmt.Errorf("no nodes configured")
+	}
+	start := int(atomic.AddUint32(&client.next, 1) - 1)
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	now := time.Now()
+	for i := 0; i < len(client.Nodes); i++ {
+		node := client.Nodes[(start+i)%len(client.Nodes)]
+		if until, ok := client.dead[node]; !ok || now.After(until) {
+			return node, nil
+		}
+	}
+	return client.Nodes[start%len(client.Nodes)], nil
+}
+
+func (client *Client) markDead(node string) {
+	timeout := client.DeadTimeout
+	if timeout == 0 {
+		timeout = DefaultDeadTimeout
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.dead == nil {
+		client.dead = map[string]time.Time{}
+	}
+	client.dead[node] = time.Now().Add(timeout)
+}
+
+func (client *Client) markAlive(node string) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	delete(client.dead, node)
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// notSent returns true for errors establishing the connection, the request did not reach the node then.
+func notSent(e error) bool {
+	var opErr *net.OpError
+	return errors.As(e, &opErr) && opErr.Op == "dial"
+}
+
+// Do sends the request to path (e.g. "/logs/_search") and returns the response. i is encoded as JSON unless
+// it is a []byte which is sent as is (e.g. bulk requests). Responses with a status other than 2xx are
+// returned together with an *Error.
+func (client *Client) Do(ctx context.Context, method, path string, i interface{}) (*HttpResponse, error) {
+	return client.DoWithContentType(ctx, method, path, "application/json", i)
+}
+
+func (client *Client) DoWithContentType(ctx context.Context, method, path, contentType string, i interface{}) (*HttpResponse, error) {
+	var body []byte
+	switch b := i.(type) {
+	case nil:
+	case []byte:
+		body = b
+	default:
+		buf := &bytes.Buffer{}
+		if e := json.NewEncoder(buf).Encode(i); e != nil {
+			return nil, e
+		}
+		body = buf.Bytes()
+	}
+	retrySent := client.RetryAll || idempotent(method)
+	var lastErr error
+	for attempt := 0; attempt <= client.maxRetries(); attempt++ {
+		if attempt > 0 {
+			wait := client.backoff(attempt)
+			if rsp, ok := lastErr.(*retryError); ok && rsp.retryAfter > wait {
+				wait = rsp.retryAfter
+				if max := client.maxBackoff(); wait > max {
+					wait = max
+				}
+			}
+			if e := sleepContext(ctx, wait); e != nil {
+				return nil, e
+			}
+		}
+		node, e := client.node()
+		if e != nil {
+			return nil, e
+		}
+		rsp, e := client.do(ctx, node, method, path, contentType, body)
+		if e != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			client.markDead(node)
+			client.logDebug("request %s %s%s failed: %s", method, node, path, e)
+			lastErr = e
+			if !retrySent && !notSent(e) {
+				break
+			}
+			continue
+		}
+		client.markAlive(node)
+		if rsp.StatusCode/100 == 2 {
+			return rsp, nil
+		}
+		lastErr = &retryError{rsp: rsp, retryAfter: parseRetryAfter(rsp.Header.Get("Retry-After"))}
+		if !retryable(rsp.StatusCode) || !retrySent {
+			break
+		}
+		client.logDebug("request %s %s%s returned %s", method, node, path, rsp.Status)
+	}
+	if r, ok := lastErr.(*retryError); ok {
+		return r.rsp, newError(r.rsp)
+	}
+	return nil, lastErr
+}
+
+func (client *Client) do(ctx context.Context, node, method, path, contentType string, body []byte) (*HttpResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, e := http.NewRequest(method, strings.TrimSuffix(node, "/")+path, reader)
+	if e != nil {
+		return nil, e
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if client.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+client.APIKey)
+	} else if client.Username != "" {
+		req.SetBasicAuth(client.Username, client.Password)
+	}
+	rsp, e := client.client().Do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer rsp.Body.Close()
+	b, e := ioutil.ReadAll(rsp.Body)
+	if e != nil {
+		return nil, e
+	}
+	return &HttpResponse{Response: rsp, Body: b}, nil
+}
+
+func (client *Client) logDebug(format string, i ...interface{}) {
+	if client.Logger != nil {
+		client.Logger.Debug(format, i...)
+	}
+}
+
+type retryError struct {
+	rsp        *HttpResponse
+	retryAfter time.Duration
+}
+
+func (e *retryError) Error() string {
+	return e.rsp.Status
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if secs, e := strconv.Atoi(value); e == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// requestPath returns the path and query of absolute urls pointing to one of the nodes, other values are
+// returned unchanged. Urls of other hosts result in an error as the request would be sent to a different host.
+func (client *Client) requestPath(u string) (string, error) {
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return u, nil
+	}
+	parsed, e := url.Parse(u)
+	if e != nil {
+		return "", fmt.Errorf("Error parsing url %q: %w", u, e)
+	}
+	for _, node := range client.Nodes {
+		n, e := url.Parse(node)
+		if e == nil && n.Scheme == parsed.Scheme && strings.EqualFold(n.Host, parsed.Host) {
+			return parsed.RequestURI(), nil
+		}
+	}
+	return "", fmt.Errorf("url %s does not point to one of the nodes %v", u, client.Nodes)
+}
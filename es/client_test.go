@@ -0,0 +1,189 @@
+package es
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClient(t *testing.T) {
+	Convey("Client", t, func() {
+		Convey("round-robin", func() {
+			var a, b int32
+			sa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&a, 1) }))
+			defer sa.Close()
+			sb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&b, 1) }))
+			defer sb.Close()
+			c := NewClient(sa.URL, sb.URL)
+			for i := 0; i < 4; i++ {
+				_, e := c.Do(context.Background(), "GET", "/", nil)
+				So(e, ShouldBeNil)
+			}
+			So(a, ShouldEqual, 2)
+			So(b, ShouldEqual, 2)
+		})
+
+		Convey("failover to the next node", func() {
+			dead := httptest.NewServer(http.NotFoundHandler())
+			dead.Close()
+			alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) }))
+			defer alive.Close()
+			c := &Client{Nodes: []string{dead.URL, alive.URL}, Backoff: time.Millisecond}
+			for i := 0; i < 3; i++ {
+				rsp, e := c.Do(context.Background(), "GET", "/", nil)
+				So(e, ShouldBeNil)
+				So(string(rsp.Body), ShouldEqual, "{}")
+			}
+		})
+
+		Convey("retry on 429 and 5xx", func() {
+			var calls int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch atomic.AddInt32(&calls, 1) {
+				case 1:
+					w.WriteHeader(429)
+				case 2:
+					w.WriteHeader(503)
+				default:
+					w.Write([]byte(`{"ok":true}`))
+				}
+			}))
+			defer s.Close()
+			c := &Client{Nodes: []string{s.URL}, Backoff: time.Millisecond}
+			rsp, e := c.Do(context.Background(), "PUT", "/logs/_doc/1", map[string]int{"a": 1})
+			So(e, ShouldBeNil)
+			So(rsp.StatusCode, ShouldEqual, 200)
+			So(calls, ShouldEqual, 3)
+
+			Convey("but not non idempotent requests which were sent", func() {
+				calls = 0
+				rsp, e := c.Do(context.Background(), "POST", "/_bulk", []byte("{}\n"))
+				So(e, ShouldNotBeNil)
+				So(rsp.StatusCode, ShouldEqual, 429)
+				So(calls, ShouldEqual, 1)
+
+				calls = 0
+				c.RetryAll = true
+				rsp, e = c.Do(context.Background(), "POST", "/_bulk", []byte("{}\n"))
+				So(e, ShouldBeNil)
+				So(calls, ShouldEqual, 3)
+			})
+
+			Convey("but not on 4xx", func() {
+				calls = 0
+				s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddInt32(&calls, 1)
+					w.WriteHeader(400)
+				})
+				rsp, e := c.Do(context.Background(), "GET", "/", nil)
+				So(e, ShouldNotBeNil)
+				So(rsp.StatusCode, ShouldEqual, 400)
+				So(calls, ShouldEqual, 1)
+			})
+
+			Convey("gives up after MaxRetries", func() {
+				calls = 0
+				s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddInt32(&calls, 1)
+					w.WriteHeader(502)
+				})
+				c.MaxRetries = 2
+				_, e := c.Do(context.Background(), "GET", "/", nil)
+				So(e, ShouldNotBeNil)
+				So(calls, ShouldEqual, 3)
+			})
+
+			Convey("waits at most MaxBackoff for Retry-After", func() {
+				calls = 0
+				s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if atomic.AddInt32(&calls, 1) == 1 {
+						w.Header().Set("Retry-After", "3600")
+						w.WriteHeader(429)
+					}
+				})
+				c.MaxBackoff = 10 * time.Millisecond
+				started := time.Now()
+				_, e := c.Do(context.Background(), "GET", "/", nil)
+				So(e, ShouldBeNil)
+				So(calls, ShouldEqual, 2)
+				So(time.Since(started), ShouldBeLessThan, time.Second)
+			})
+
+			Convey("stops when the context is done", func() {
+				s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(503) })
+				c.Backoff = time.Hour
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				_, e := c.Do(ctx, "GET", "/", nil)
+				So(e == context.DeadlineExceeded, ShouldBeTrue)
+			})
+		})
+
+		Convey("TLS and authentication", func() {
+			var auth string
+			s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+			}))
+			defer s.Close()
+			c := &Client{Nodes: []string{s.URL}, HTTPClient: s.Client(), Username: "elastic", Password: "secret"}
+			_, e := c.Do(context.Background(), "GET", "/", nil)
+			So(e, ShouldBeNil)
+			So(auth, ShouldEqual, "Basic ZWxhc3RpYzpzZWNyZXQ=")
+
+			c.APIKey = "a2V5OnNlY3JldA=="
+			_, e = c.Do(context.Background(), "GET", "/", nil)
+			So(e, ShouldBeNil)
+			So(auth, ShouldEqual, "ApiKey a2V5OnNlY3JldA==")
+
+			index := &Index{Client: c, Index: "logs"}
+			So(index.IndexUrl(), ShouldEqual, s.URL+"/logs")
+			exists, e := index.IndexExists()
+			So(e, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+		})
+
+		Convey("default client of an index", func() {
+			var paths []string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.Method+" "+r.URL.Path)
+			}))
+			defer s.Close()
+			addr := s.Listener.Addr().(*net.TCPAddr)
+			index := &Index{Host: addr.IP.String(), Port: addr.Port, Index: "logs", Type: "line"}
+			So(index.client(), ShouldEqual, index.client())
+
+			ctx := context.Background()
+			_, e := index.PostContext(ctx, "/logs/_refresh", nil)
+			So(e, ShouldBeNil)
+			_, e = index.PostObjectContext(ctx, map[string]string{"a": "b"})
+			So(e, ShouldBeNil)
+			_, e = index.PutObjectContext(ctx, "1", map[string]string{"a": "b"})
+			So(e, ShouldBeNil)
+			_, e = index.PutContext(ctx, "/logs/_settings", map[string]string{})
+			So(e, ShouldBeNil)
+			So(paths, ShouldResemble, []string{"POST /logs/_refresh", "POST /logs/line", "PUT /logs/line/1", "PUT /logs/_settings"})
+
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			_, e = index.PutContext(cancelled, "/logs/_settings", nil)
+			So(e, ShouldNotBeNil)
+
+			_, e = index.Post(s.URL+"/logs/_refresh", nil)
+			So(e, ShouldBeNil)
+			So(paths[len(paths)-1], ShouldEqual, "POST /logs/_refresh")
+			_, e = index.Post("http://example.com:9200/logs/_refresh", nil)
+			So(e, ShouldNotBeNil)
+			So(e.Error(), ShouldContainSubstring, "does not point to one of the nodes")
+
+			client := index.client()
+			index.Port++
+			So(index.client(), ShouldNotEqual, client)
+		})
+	})
+}
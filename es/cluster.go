@@ -0,0 +1,376 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HealthGreen  = "green"
+	HealthYellow = "yellow"
+	HealthRed    = "red"
+)
+
+type ClusterHealth struct {
+	ClusterName                 string  `json:"cluster_name"`
+	Status                      string  `json:"status"`
+	TimedOut                    bool    `json:"timed_out"`
+	NumberOfNodes               int     `json:"number_of_nodes"`
+	NumberOfDataNodes           int     `json:"number_of_data_nodes"`
+	ActivePrimaryShards         int     `json:"active_primary_shards"`
+	ActiveShards                int     `json:"active_shards"`
+	RelocatingShards            int     `json:"relocating_shards"`
+	InitializingShards          int     `json:"initializing_shards"`
+	UnassignedShards            int     `json:"unassigned_shards"`
+	DelayedUnassignedShards     int     `json:"delayed_unassigned_shards"`
+	NumberOfPendingTasks        int     `json:"number_of_pending_tasks"`
+	ActiveShardsPercentAsNumber float64 `json:"active_shards_percent_as_number"`
+}
+
+// HealthOptions are used to wait for a cluster state. The request returns as soon as the state is reached or
+// after Timeout (30s by default).
+type HealthOptions struct {
+	WaitForStatus             string // green, yellow or red
+	WaitForNodes              string // e.g. ">=3"
+	WaitForNoRelocatingShards bool
+	Timeout                   time.Duration
+}
+
+func (opts *HealthOptions) query() string {
+	if opts == nil {
+		return ""
+	}
+	values := url.Values{}
+	if opts.WaitForStatus != "" {
+		values.Set("wait_for_status", opts.WaitForStatus)
+	}
+	if opts.WaitForNodes != "" {
+		values.Set("wait_for_nodes", opts.WaitForNodes)
+	}
+	if opts.WaitForNoRelocatingShards {
+		values.Set("wait_for_no_relocating_shards", "true")
+	}
+	if opts.Timeout > 0 {
+		values.Set("timeout", formatDuration(opts.Timeout))
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+// indexList returns the escaped, comma separated names.
+func indexList(names ...string) string {
+	escaped := make([]string, len(names))
+	for i, name := range names {
+		escaped[i] = url.PathEscape(name)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// formatDuration formats d as Elasticsearch time unit (e.g. 1500ms or 30s).
+func formatDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+	return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+}
+
+// ClusterHealth returns the health of the cluster or of the given indices. When the state of opts was not
+// reached before the timeout the health is returned together with an error.
+func (client *Client) ClusterHealth(ctx context.Context, opts *HealthOptions, indices ...string) (*ClusterHealth, error) {
+	p := "/_cluster/health"
+	if len(indices) > 0 {
+		p += "/" + indexList(indices...)
+	}
+	rsp, e := client.Do(ctx, "GET", p+opts.query(), nil)
+	if e != nil && (rsp == nil || rsp.StatusCode != 408) {
+		return nil, fmt.Errorf("Error getting cluster health: %w", e)
+	}
+	health := &ClusterHealth{}
+	if e := json.Unmarshal(rsp.Body, health); e != nil {
+		return nil, e
+	}
+	if health.TimedOut {
+		return health, fmt.Errorf("Error waiting for cluster health: timed out with status %s", health.Status)
+	}
+	return health, nil
+}
+
+type NodesStats struct {
+	ClusterName string                `json:"cluster_name"`
+	Nodes       map[string]*NodeStats `json:"nodes"`
+}
+
+type NodeStats struct {
+	Name       string                      `json:"name"`
+	Host       string                      `json:"host"`
+	IP         string                      `json:"ip"`
+	Roles      []string                    `json:"roles"`
+	Indices    *NodeIndicesStats           `json:"indices,omitempty"`
+	OS         *NodeOSStats                `json:"os,omitempty"`
+	JVM        *NodeJVMStats               `json:"jvm,omitempty"`
+	FS         *NodeFSStats                `json:"fs,omitempty"`
+	ThreadPool map[string]*ThreadPoolStats `json:"thread_pool,omitempty"`
+}
+
+type NodeIndicesStats struct {
+	Docs  *DocsStats  `json:"docs"`
+	Store *StoreStats `json:"store"`
+}
+
+type DocsStats struct {
+	Count   int64 `json:"count"`
+	Deleted int64 `json:"deleted"`
+}
+
+type StoreStats struct {
+	SizeInBytes int64 `json:"size_in_bytes"`
+}
+
+type NodeOSStats struct {
+	CPU struct {
+		Percent int `json:"percent"`
+	} `json:"cpu"`
+}
+
+type NodeJVMStats struct {
+	UptimeInMillis int64 `json:"uptime_in_millis"`
+	Mem            struct {
+		HeapUsedInBytes int64 `json:"heap_used_in_bytes"`
+		HeapUsedPercent int   `json:"heap_used_percent"`
+		HeapMaxInBytes  int64 `json:"heap_max_in_bytes"`
+	} `json:"mem"`
+}
+
+type NodeFSStats struct {
+	Total struct {
+		TotalInBytes     int64 `json:"total_in_bytes"`
+		FreeInBytes      int64 `json:"free_in_bytes"`
+		AvailableInBytes int64 `json:"available_in_bytes"`
+	} `json:"total"`
+}
+
+type ThreadPoolStats struct {
+	Threads   int   `json:"threads"`
+	Queue     int   `json:"queue"`
+	Active    int   `json:"active"`
+	Rejected  int64 `json:"rejected"`
+	Completed int64 `json:"completed"`
+}
+
+// NodeStats returns the stats of all nodes. metrics limits the returned stats (e.g. jvm, os, fs, indices or
+// thread_pool), all stats are returned when empty.
+func (client *Client) NodeStats(ctx context.Context, metrics ...string) (*NodesStats, error) {
+	p := "/_nodes/stats"
+	if len(metrics) > 0 {
+		p += "/" + strings.Join(metrics, ",")
+	}
+	rsp, e := client.Do(ctx, "GET", p, nil)
+	if e != nil {
+		return nil, fmt.Errorf("Error getting node stats: %w", e)
+	}
+	stats := &NodesStats{}
+	if e := json.Unmarshal(rsp.Body, stats); e != nil {
+		return nil, e
+	}
+	return stats, nil
+}
+
+// CatIndex is a row of _cat/indices. Counts and sizes are 0 for closed indices.
+type CatIndex struct {
+	Health       string
+	Status       string
+	Index        string
+	UUID         string
+	Primaries    int
+	Replicas     int
+	DocsCount    int64
+	DocsDeleted  int64
+	StoreSize    int64 // in bytes
+	PriStoreSize int64 // in bytes
+}
+
+// UnmarshalJSON parses the row as returned with format=json where all values are strings.
+func (index *CatIndex) UnmarshalJSON(b []byte) error {
+	row := map[string]*string{}
+	if e := json.Unmarshal(b, &row); e != nil {
+		return e
+	}
+	value := func(key string) string {
+		if v := row[key]; v != nil {
+			return *v
+		}
+		return ""
+	}
+	integer := func(key string) (int64, error) {
+		if value(key) == "" {
+			return 0, nil
+		}
+		i, e := strconv.ParseInt(value(key), 10, 64)
+		if e != nil {
+			return 0, fmt.Errorf("Error parsing %s of index %s: %s", key, value("index"), e)
+		}
+		return i, nil
+	}
+	*index = CatIndex{Health: value("health"), Status: value("status"), Index: value("index"), UUID: value("uuid")}
+	for key, dst := range map[string]*int64{
+		"docs.count":     &index.DocsCount,
+		"docs.deleted":   &index.DocsDeleted,
+		"store.size":     &index.StoreSize,
+		"pri.store.size": &index.PriStoreSize,
+	} {
+		i, e := integer(key)
+		if e != nil {
+			return e
+		}
+		*dst = i
+	}
+	pri, e := integer("pri")
+	if e != nil {
+		return e
+	}
+	rep, e := integer("rep")
+	if e != nil {
+		return e
+	}
+	index.Primaries, index.Replicas = int(pri), int(rep)
+	return nil
+}
+
+// CatIndices returns all indices matching pattern (e.g. "logs-*"), all indices when pattern is empty.
+func (client *Client) CatIndices(ctx context.Context, pattern string) ([]*CatIndex, error) {
+	p := "/_cat/indices"
+	if pattern != "" {
+		p += "/" + indexList(strings.Split(pattern, ",")...)
+	}
+	rsp, e := client.Do(ctx, "GET", p+"?format=json&bytes=b", nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return []*CatIndex{}, nil
+	} else if e != nil {
+		return nil, fmt.Errorf("Error listing indices: %w", e)
+	}
+	indices := []*CatIndex{}
+	if e := json.Unmarshal(rsp.Body, &indices); e != nil {
+		return nil, e
+	}
+	return indices, nil
+}
+
+type ReindexRequest struct {
+	Source    *ReindexSource `json:"source"`
+	Dest      *ReindexDest   `json:"dest"`
+	Conflicts string         `json:"conflicts,omitempty"` // abort (default) or proceed
+	MaxDocs   int            `json:"max_docs,omitempty"`
+}
+
+type ReindexSource struct {
+	Index []string `json:"index"`
+	Query *Query   `json:"query,omitempty"`
+	Size  int      `json:"size,omitempty"` // batch size
+}
+
+type ReindexDest struct {
+	Index   string `json:"index"`
+	OpType  string `json:"op_type,omitempty"` // e.g. create to only copy missing documents
+	Routing string `json:"routing,omitempty"`
+}
+
+type ReindexResponse struct {
+	Took             int               `json:"took"`
+	TimedOut         bool              `json:"timed_out"`
+	Total            int64             `json:"total"`
+	Created          int64             `json:"created"`
+	Updated          int64             `json:"updated"`
+	Deleted          int64             `json:"deleted"`
+	Batches          int               `json:"batches"`
+	VersionConflicts int64             `json:"version_conflicts"`
+	Noops            int64             `json:"noops"`
+	Failures         []json.RawMessage `json:"failures"`
+}
+
+// Reindex copies documents from the source to the destination indices and waits for completion.
+func (client *Client) Reindex(ctx context.Context, req *ReindexRequest) (*ReindexResponse, error) {
+	rsp, e := client.Do(ctx, "POST", "/_reindex?wait_for_completion=true", req)
+	if e != nil {
+		return nil, fmt.Errorf("Error reindexing: %w", e)
+	}
+	res := &ReindexResponse{}
+	if e := json.Unmarshal(rsp.Body, res); e != nil {
+		return nil, e
+	}
+	if len(res.Failures) > 0 {
+		return res, fmt.Errorf("Error reindexing: %d failures, first: %s", len(res.Failures), res.Failures[0])
+	}
+	return res, nil
+}
+
+type SnapshotOptions struct {
+	Indices            []string `json:"indices,omitempty"`
+	IgnoreUnavailable  bool     `json:"ignore_unavailable,omitempty"`
+	IncludeGlobalState *bool    `json:"include_global_state,omitempty"`
+}
+
+type RestoreOptions struct {
+	Indices            []string `json:"indices,omitempty"`
+	IgnoreUnavailable  bool     `json:"ignore_unavailable,omitempty"`
+	IncludeGlobalState bool     `json:"include_global_state,omitempty"`
+	RenamePattern      string   `json:"rename_pattern,omitempty"`     // e.g. "(.+)"
+	RenameReplacement  string   `json:"rename_replacement,omitempty"` // e.g. "restored-$1"
+}
+
+type SnapshotInfo struct {
+	Snapshot  string   `json:"snapshot"`
+	UUID      string   `json:"uuid"`
+	Indices   []string `json:"indices"`
+	State     string   `json:"state"` // e.g. SUCCESS, PARTIAL or FAILED
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time"`
+	Shards    *Shards  `json:"shards"`
+}
+
+func snapshotPath(repository, name string) string {
+	return "/_snapshot/" + url.PathEscape(repository) + "/" + url.PathEscape(name)
+}
+
+// CreateSnapshot creates the snapshot in the (already registered) repository and waits for completion.
+func (client *Client) CreateSnapshot(ctx context.Context, repository, name string, opts *SnapshotOptions) (*SnapshotInfo, error) {
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+	rsp, e := client.Do(ctx, "PUT", snapshotPath(repository, name)+"?wait_for_completion=true", opts)
+	if e != nil {
+		return nil, fmt.Errorf("Error creating snapshot %s: %w", name, e)
+	}
+	var res struct {
+		Snapshot *SnapshotInfo `json:"snapshot"`
+	}
+	if e := json.Unmarshal(rsp.Body, &res); e != nil {
+		return nil, e
+	}
+	if res.Snapshot == nil {
+		return nil, fmt.Errorf("Error creating snapshot %s: no snapshot in response", name)
+	}
+	if res.Snapshot.State != "SUCCESS" {
+		return res.Snapshot, fmt.Errorf("Error creating snapshot %s: state is %s", name, res.Snapshot.State)
+	}
+	return res.Snapshot, nil
+}
+
+// RestoreSnapshot restores the snapshot and waits for completion. Restored indices must not exist or be
+// closed, use RenamePattern and RenameReplacement to restore into new indices.
+func (client *Client) RestoreSnapshot(ctx context.Context, repository, name string, opts *RestoreOptions) error {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	_, e := client.Do(ctx, "POST", snapshotPath(repository, name)+"/_restore?wait_for_completion=true", opts)
+	if e != nil {
+		return fmt.Errorf("Error restoring snapshot %s: %w", name, e)
+	}
+	return nil
+}
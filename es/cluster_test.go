@@ -0,0 +1,189 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// last returns the last request and its body.
+func last(s *estest.Server) (string, string) {
+	requests, bodies := s.Requests(), s.Bodies()
+	return requests[len(requests)-1], strings.TrimSpace(bodies[len(bodies)-1])
+}
+
+const (
+	healthTimeout = `{"cluster_name":"logs","status":"yellow","timed_out":true,"number_of_nodes":2,"unassigned_shards":5}`
+	nodeStats     = `{"cluster_name":"logs","nodes":{"n1":{"name":"es-1","host":"10.0.0.1","ip":"10.0.0.1:9300","roles":["data","master"],"jvm":{"mem":{"heap_used_in_bytes":1024,"heap_used_percent":42,"heap_max_in_bytes":4096}},"thread_pool":{"write":{"threads":4,"queue":10,"active":2,"rejected":7,"completed":100}}}}}`
+)
+
+func TestCluster(t *testing.T) {
+	Convey("Cluster admin", t, func() {
+		ctx := context.Background()
+		s := estest.NewServer()
+		defer s.Close()
+		s.Respond("GET", "/_cluster/health/logs-a,logs-b", 408, healthTimeout)
+		s.Respond("GET", "/_nodes/stats/jvm,thread_pool", 200, nodeStats)
+		s.Respond("POST", "/_reindex", 200, `{"took":12,"total":2,"created":2,"batches":1,"failures":[]}`)
+		s.Respond("PUT", "/_snapshot/backups/nightly", 200, `{"snapshot":{"snapshot":"nightly","uuid":"s1","indices":["logs"],"state":"SUCCESS","shards":{"total":1,"failed":0,"successful":1}}}`)
+		s.Respond("PUT", "/_snapshot/backups/broken", 200, `{"snapshot":{"snapshot":"broken","state":"PARTIAL"}}`)
+		s.Respond("POST", "/_snapshot/backups/nightly/_restore", 200, `{"snapshot":{"snapshot":"nightly"}}`)
+		client := NewClient(s.URL)
+
+		Convey("health", func() {
+			_, e := (&Index{Client: client, Index: "logs"}).CreateIndex(IndexConfig{})
+			So(e, ShouldBeNil)
+			health, e := client.ClusterHealth(ctx, nil)
+			So(e, ShouldBeNil)
+			So(health.Status, ShouldEqual, HealthGreen)
+			So(health.NumberOfNodes, ShouldEqual, 1)
+			So(health.ActiveShards, ShouldEqual, 1)
+
+			health, e = client.ClusterHealth(ctx, &HealthOptions{WaitForStatus: HealthGreen, Timeout: 1500 * time.Millisecond}, "logs-a", "logs-b")
+			So(e, ShouldNotBeNil)
+			So(e.Error(), ShouldContainSubstring, "timed out with status yellow")
+			So(health.UnassignedShards, ShouldEqual, 5)
+			req, _ := last(s)
+			So(req, ShouldEqual, "GET /_cluster/health/logs-a,logs-b?timeout=1500ms&wait_for_status=green")
+		})
+
+		Convey("node stats", func() {
+			stats, e := client.NodeStats(ctx, "jvm", "thread_pool")
+			So(e, ShouldBeNil)
+			node := stats.Nodes["n1"]
+			So(node.Name, ShouldEqual, "es-1")
+			So(node.JVM.Mem.HeapUsedPercent, ShouldEqual, 42)
+			So(node.ThreadPool["write"].Rejected, ShouldEqual, 7)
+			So(node.OS, ShouldBeNil)
+		})
+
+		Convey("cat indices", func() {
+			current := &Index{Client: client, Index: "logs-2026.10.16"}
+			So(current.IndexDocs([]*Doc{{Id: "1", Source: Source{"Raw": "line 1"}}, {Id: "2", Source: Source{"Raw": "line 2"}}}), ShouldBeNil)
+			So(current.Refresh(), ShouldBeNil)
+			old := &Index{Client: client, Index: "logs-2026.10.01"}
+			So(old.IndexDocs([]*Doc{{Id: "1", Source: Source{"Raw": "line 1"}}}), ShouldBeNil)
+			So(old.Close(ctx), ShouldBeNil)
+
+			indices, e := client.CatIndices(ctx, "logs-*")
+			So(e, ShouldBeNil)
+			So(len(indices), ShouldEqual, 2)
+			So(indices[0].Index, ShouldEqual, "logs-2026.10.01")
+			So(indices[0].Status, ShouldEqual, "close")
+			So(indices[0].Health, ShouldEqual, "")
+			So(indices[0].DocsCount, ShouldEqual, 0)
+			So(indices[1].Index, ShouldEqual, "logs-2026.10.16")
+			So(indices[1].Health, ShouldEqual, "green")
+			So(indices[1].Status, ShouldEqual, "open")
+			So(indices[1].Primaries, ShouldEqual, 1)
+			So(indices[1].DocsCount, ShouldEqual, 2)
+			So(indices[1].StoreSize, ShouldBeGreaterThan, 0)
+			req, _ := last(s)
+			So(req, ShouldEqual, "GET /_cat/indices/logs-%2A?format=json&bytes=b")
+
+			indices, e = client.CatIndices(ctx, "missing-*")
+			So(e, ShouldBeNil)
+			So(indices, ShouldBeEmpty)
+			indices, e = client.CatIndices(ctx, "missing")
+			So(e, ShouldBeNil)
+			So(indices, ShouldBeEmpty)
+		})
+
+		Convey("reindex", func() {
+			rsp, e := client.Reindex(ctx, &ReindexRequest{
+				Source:    &ReindexSource{Index: []string{"logs-old"}, Query: NewTermQuery("Tag", "nginx")},
+				Dest:      &ReindexDest{Index: "logs-new", OpType: "create"},
+				Conflicts: "proceed",
+			})
+			So(e, ShouldBeNil)
+			So(rsp.Created, ShouldEqual, 2)
+			req, body := last(s)
+			So(req, ShouldEqual, "POST /_reindex?wait_for_completion=true")
+			So(body, ShouldEqual, `{"source":{"index":["logs-old"],"query":{"term":{"Tag":{"value":"nginx"}}}},"dest":{"index":"logs-new","op_type":"create"},"conflicts":"proceed"}`)
+		})
+
+		Convey("snapshots", func() {
+			info, e := client.CreateSnapshot(ctx, "backups", "nightly", &SnapshotOptions{Indices: []string{"logs"}})
+			So(e, ShouldBeNil)
+			So(info.State, ShouldEqual, "SUCCESS")
+			So(info.Shards.Successful, ShouldEqual, 1)
+			_, body := last(s)
+			So(body, ShouldEqual, `{"indices":["logs"]}`)
+
+			info, e = client.CreateSnapshot(ctx, "backups", "broken", nil)
+			So(e, ShouldNotBeNil)
+			So(info.State, ShouldEqual, "PARTIAL")
+
+			So(client.RestoreSnapshot(ctx, "backups", "nightly", &RestoreOptions{RenamePattern: "(.+)", RenameReplacement: "restored-$1"}), ShouldBeNil)
+			req, body := last(s)
+			So(req, ShouldEqual, "POST /_snapshot/backups/nightly/_restore?wait_for_completion=true")
+			So(body, ShouldEqual, `{"rename_pattern":"(.+)","rename_replacement":"restored-$1"}`)
+		})
+
+		Convey("open, close and force merge", func() {
+			index := &Index{Client: client, Index: "logs"}
+			_, e := index.CreateIndex(IndexConfig{})
+			So(e, ShouldBeNil)
+			So(index.Close(ctx), ShouldBeNil)
+			_, e = index.Get(ctx, "1", nil)
+			So(errors.Is(e, ErrNotFound), ShouldBeFalse)
+			So(e.(*Error).Type, ShouldEqual, "index_closed_exception")
+			So(index.Open(ctx), ShouldBeNil)
+			So(index.ForceMerge(ctx, 1), ShouldBeNil)
+			req, _ := last(s)
+			So(req, ShouldEqual, "POST /logs/_forcemerge?max_num_segments=1")
+			So((&Index{Client: client, Index: "other"}).Open(ctx), ShouldNotBeNil)
+
+			requests := len(s.Requests())
+			empty := &Index{Client: client}
+			So(empty.Open(ctx), ShouldNotBeNil)
+			So(empty.Close(ctx), ShouldNotBeNil)
+			So(empty.ForceMerge(ctx, 1), ShouldNotBeNil)
+			So(empty.DeleteIndex(), ShouldNotBeNil)
+			So(len(s.Requests()), ShouldEqual, requests)
+		})
+	})
+}
+
+func TestIndexStats(t *testing.T) {
+	Convey("Index stats and count", t, func() {
+		ctx := context.Background()
+		s := estest.NewServer()
+		defer s.Close()
+		index := &Index{Host: s.Host(), Port: s.Port(), Index: "stats"}
+		_, e := index.Status()
+		So(e, ShouldNotBeNil)
+
+		So(index.IndexDocs([]*Doc{
+			{Id: "1", Source: Source{"Tag": "nginx"}},
+			{Id: "2", Source: Source{"Tag": "unicorn"}},
+			{Id: "3", Source: Source{"Tag": "nginx"}},
+		}), ShouldBeNil)
+		So(index.Refresh(), ShouldBeNil)
+
+		status, e := index.Status()
+		So(e, ShouldBeNil)
+		So(status.Ok, ShouldBeTrue)
+		So(status.Shards.Failed, ShouldEqual, 0)
+
+		stats, e := index.Stats(ctx)
+		So(e, ShouldBeNil)
+		So(stats.Primaries.Docs.Count, ShouldEqual, 3)
+
+		count, e := index.Count(ctx, nil)
+		So(e, ShouldBeNil)
+		So(count, ShouldEqual, 3)
+		count, e = index.Count(ctx, NewTermQuery("Tag", "nginx"))
+		So(e, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+
+		health, e := NewClient(s.URL).ClusterHealth(ctx, &HealthOptions{WaitForStatus: HealthGreen})
+		So(e, ShouldBeNil)
+		So(health.Status, ShouldEqual, HealthGreen)
+	})
+}
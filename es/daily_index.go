@@ -0,0 +1,166 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DailyIndexLayout = "2006.01.02"
+
+// DailyIndex writes to one index per day (e.g. logs-2026.10.16) behind an alias. The alias points to all
+// daily indices, the index of the current day is its write index. Indices of days before now - Retention
+// are deleted by Rollover.
+type DailyIndex struct {
+	Client    *Client
+	Prefix    string        // e.g. "logs"
+	Alias     string        // defaults to Prefix
+	Type      string        // type of indices returned by Index
+	Retention time.Duration // 0 keeps all indices
+	Config    interface{}   // body used to create new indices (e.g. IndexConfig or settings and mappings)
+
+	now func() time.Time
+}
+
+func (daily *DailyIndex) alias() string {
+	if daily.Alias == "" {
+		return daily.Prefix
+	}
+	return daily.Alias
+}
+
+func (daily *DailyIndex) currentTime() time.Time {
+	if daily.now != nil {
+		return daily.now()
+	}
+	return time.Now()
+}
+
+// Name returns the name of the index for the day of t (in UTC).
+func (daily *DailyIndex) Name(t time.Time) string {
+	return daily.Prefix + "-" + t.UTC().Format(DailyIndexLayout)
+}
+
+// Day returns the day of an index name created by Name.
+func (daily *DailyIndex) Day(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, daily.Prefix+"-") {
+		return time.Time{}, false
+	}
+	t, e := time.Parse(DailyIndexLayout, strings.TrimPrefix(name, daily.Prefix+"-"))
+	return t, e == nil
+}
+
+// Index returns the index for the day of t.
+func (daily *DailyIndex) Index(t time.Time) *Index {
+	return &Index{Client: daily.Client, Index: daily.Name(t), Type: daily.Type}
+}
+
+// WriteIndex returns an index using the alias, docs are written to the index of the last rollover.
+func (daily *DailyIndex) WriteIndex() *Index {
+	return &Index{Client: daily.Client, Index: daily.alias(), Type: daily.Type}
+}
+
+// Rollover creates the index of the current day when it does not exist yet, makes it the write index of the
+// alias and deletes expired indices. It returns the name of the current index.
+func (daily *DailyIndex) Rollover(ctx context.Context) (string, error) {
+	name := daily.Name(daily.currentTime())
+	if e := daily.create(ctx, name); e != nil {
+		return "", e
+	}
+	aliases, e := daily.Client.Aliases(ctx, daily.alias())
+	if e != nil {
+		return "", e
+	}
+	actions := []*AliasAction{}
+	for index, options := range aliases {
+		if index != name && options.IsWriteIndex != nil && *options.IsWriteIndex {
+			actions = append(actions, AddWriteAlias(index, daily.alias(), false))
+		}
+	}
+	if options, ok := aliases[name]; !ok || options.IsWriteIndex == nil || !*options.IsWriteIndex {
+		actions = append(actions, AddWriteAlias(name, daily.alias(), true))
+	}
+	if e := daily.Client.UpdateAliases(ctx, actions...); e != nil {
+		return "", e
+	}
+	if _, e := daily.DeleteExpired(ctx); e != nil {
+		return "", e
+	}
+	return name, nil
+}
+
+func (daily *DailyIndex) create(ctx context.Context, name string) error {
+	rsp, e := daily.Client.Do(ctx, "HEAD", "/"+url.PathEscape(name), nil)
+	if e == nil {
+		return nil
+	} else if rsp == nil || rsp.StatusCode != 404 {
+		return e
+	}
+	_, e = daily.Client.Do(ctx, "PUT", "/"+url.PathEscape(name), daily.Config)
+	if e != nil && !errors.Is(e, ErrIndexAlreadyExists) {
+		return fmt.Errorf("Error creating index %s: %w", name, e)
+	}
+	return nil
+}
+
+// Indices returns the names of all daily indices sorted by day.
+func (daily *DailyIndex) Indices(ctx context.Context) ([]string, error) {
+	rsp, e := daily.Client.Do(ctx, "GET", "/_cat/indices/"+url.PathEscape(daily.Prefix+"-*")+"?format=json&h=index", nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return nil, nil
+	} else if e != nil {
+		return nil, e
+	}
+	rows := []struct {
+		Index string `json:"index"`
+	}{}
+	if e := json.Unmarshal(rsp.Body, &rows); e != nil {
+		return nil, e
+	}
+	names := []string{}
+	for _, row := range rows {
+		if _, ok := daily.Day(row.Index); ok {
+			names = append(names, row.Index)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Expired returns the names of all indices of days before now - Retention.
+func (daily *DailyIndex) Expired(ctx context.Context) ([]string, error) {
+	if daily.Retention <= 0 {
+		return nil, nil
+	}
+	names, e := daily.Indices(ctx)
+	if e != nil {
+		return nil, e
+	}
+	cutoff := daily.currentTime().UTC().Add(-daily.Retention).Truncate(24 * time.Hour)
+	expired := []string{}
+	for _, name := range names {
+		if day, _ := daily.Day(name); day.Before(cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	return expired, nil
+}
+
+// DeleteExpired deletes all expired indices and returns their names.
+func (daily *DailyIndex) DeleteExpired(ctx context.Context) ([]string, error) {
+	expired, e := daily.Expired(ctx)
+	if e != nil {
+		return nil, e
+	}
+	for i, name := range expired {
+		if _, e := daily.Client.Do(ctx, "DELETE", "/"+url.PathEscape(name), nil); e != nil {
+			return expired[:i], fmt.Errorf("Error deleting index %s: %w", name, e)
+		}
+	}
+	return expired, nil
+}
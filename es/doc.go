@@ -1,10 +1,27 @@
 package es
 
+const (
+	BulkIndex  = "index"
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
 type Doc struct {
 	Index  string
 	Type   string
 	Id     string
 	Source interface{}
+	Action string // bulk action, defaults to BulkIndex
+}
+
+// UpdateBody is the source of docs with the BulkUpdate action. Sources of other types are sent as partial
+// document ({"doc": <source>}).
+type UpdateBody struct {
+	Doc         interface{} `json:"doc,omitempty"`
+	DocAsUpsert bool        `json:"doc_as_upsert,omitempty"`
+	Upsert      interface{} `json:"upsert,omitempty"`
+	Script      interface{} `json:"script,omitempty"`
 }
 
 func (doc *Doc) IndexAttributes() map[string]string {
@@ -20,3 +37,26 @@ func (doc *Doc) IndexAttributes() map[string]string {
 	}
 	return atts
 }
+
+func (doc *Doc) action() string {
+	if doc.Action == "" {
+		return BulkIndex
+	}
+	return doc.Action
+}
+
+// bulkSource returns the source line of the doc or nil when the action has none.
+func (doc *Doc) bulkSource() interface{} {
+	switch doc.action() {
+	case BulkDelete:
+		return nil
+	case BulkUpdate:
+		switch s := doc.Source.(type) {
+		case *UpdateBody, UpdateBody:
+			return s
+		default:
+			return &UpdateBody{Doc: s}
+		}
+	}
+	return doc.Source
+}
@@ -0,0 +1,207 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ErrNotFound is returned when a document does not exist. Errors of other requests failing with 404 (e.g. a
+// missing index) match it with errors.Is.
+var ErrNotFound = errors.New("document not found")
+
+// ConflictError is returned when a write failed because of a version or sequence number mismatch or because
+// a document to create already exists.
+type ConflictError struct {
+	Index  string
+	Id     string
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict writing %s/%s: %s", e.Index, e.Id, e.Reason)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// DocMeta holds the metadata of a document returned by single document requests.
+type DocMeta struct {
+	Index       string `json:"_index"`
+	Type        string `json:"_type,omitempty"`
+	Id          string `json:"_id"`
+	Version     int64  `json:"_version"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+	Result      string `json:"result,omitempty"` // created, updated, deleted, noop or not_found
+	Found       bool   `json:"found,omitempty"`
+}
+
+// WriteOptions are used for conditional writes. Use IfMatch to only write when the document was not changed
+// since it was read.
+type WriteOptions struct {
+	IfSeqNo         int64 // only used when IfPrimaryTerm is set
+	IfPrimaryTerm   int64
+	Version         int64  // external version, not supported by Update and Upsert
+	VersionType     string // e.g. external or external_gte
+	Routing         string
+	Refresh         string // true, false or wait_for
+	RetryOnConflict int    // only used by Update and Upsert
+}
+
+// IfMatch returns options to write only when the document still has the sequence number of meta.
+func IfMatch(meta *DocMeta) *WriteOptions {
+	return &WriteOptions{IfSeqNo: meta.SeqNo, IfPrimaryTerm: meta.PrimaryTerm}
+}
+
+func (opts *WriteOptions) query() string {
+	if opts == nil {
+		return ""
+	}
+	values := url.Values{}
+	if opts.IfPrimaryTerm > 0 {
+		values.Set("if_seq_no", strconv.FormatInt(opts.IfSeqNo, 10))
+		values.Set("if_primary_term", strconv.FormatInt(opts.IfPrimaryTerm, 10))
+	}
+	if opts.Version > 0 {
+		values.Set("version", strconv.FormatInt(opts.Version, 10))
+	}
+	if opts.VersionType != "" {
+		values.Set("version_type", opts.VersionType)
+	}
+	if opts.Routing != "" {
+		values.Set("routing", opts.Routing)
+	}
+	if opts.Refresh != "" {
+		values.Set("refresh", opts.Refresh)
+	}
+	if opts.RetryOnConflict > 0 {
+		values.Set("retry_on_conflict", strconv.Itoa(opts.RetryOnConflict))
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+// docPath returns /<index>/_doc/<id> or /<index>/<type>/<id> when a type is set.
+func (index *Index) docPath(id string) string {
+	if index.Type != "" {
+		return index.TypePath() + "/" + url.PathEscape(id)
+	}
+	return index.Path() + "/_doc/" + url.PathEscape(id)
+}
+
+func (index *Index) updatePath(id string) string {
+	if index.Type != "" {
+		return index.TypePath() + "/" + url.PathEscape(id) + "/_update"
+	}
+	return index.Path() + "/_update/" + url.PathEscape(id)
+}
+
+// Get decodes the source of the document into v (when not nil). It returns ErrNotFound when the document
+// does not exist and an *Error matching ErrNotFound when the index does not exist.
+func (index *Index) Get(ctx context.Context, id string, v interface{}) (*DocMeta, error) {
+	rsp, e := index.requestWithContext(ctx, "GET", index.docPath(id), nil)
+	if docMissing(rsp) {
+		return nil, ErrNotFound
+	} else if e != nil {
+		return nil, e
+	}
+	var doc struct {
+		DocMeta
+		Source json.RawMessage `json:"_source"`
+	}
+	if e := json.Unmarshal(rsp.Body, &doc); e != nil {
+		return nil, e
+	}
+	if !doc.Found {
+		return nil, ErrNotFound
+	}
+	if v != nil && len(doc.Source) > 0 {
+		if e := json.Unmarshal(doc.Source, v); e != nil {
+			return nil, e
+		}
+	}
+	return &doc.DocMeta, nil
+}
+
+func (index *Index) Exists(ctx context.Context, id string) (bool, error) {
+	rsp, e := index.requestWithContext(ctx, "HEAD", index.docPath(id), nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return false, nil
+	} else if e != nil {
+		return false, e
+	}
+	return true, nil
+}
+
+// Replace creates or replaces the document.
+func (index *Index) Replace(ctx context.Context, id string, doc interface{}, opts *WriteOptions) (*DocMeta, error) {
+	return index.write(ctx, id, "PUT", index.docPath(id)+opts.query(), doc)
+}
+
+// Create creates the document and returns a *ConflictError when it already exists.
+func (index *Index) Create(ctx context.Context, id string, doc interface{}) (*DocMeta, error) {
+	return index.write(ctx, id, "PUT", index.docPath(id)+"?op_type=create", doc)
+}
+
+// Update merges partial into the document. It returns an error matching ErrNotFound when the document does
+// not exist.
+func (index *Index) Update(ctx context.Context, id string, partial interface{}, opts *WriteOptions) (*DocMeta, error) {
+	return index.write(ctx, id, "POST", index.updatePath(id)+opts.query(), &UpdateBody{Doc: partial})
+}
+
+// Upsert merges doc into the document or creates it when it does not exist.
+func (index *Index) Upsert(ctx context.Context, id string, doc interface{}, opts *WriteOptions) (*DocMeta, error) {
+	return index.write(ctx, id, "POST", index.updatePath(id)+opts.query(), &UpdateBody{Doc: doc, DocAsUpsert: true})
+}
+
+// Delete deletes the document. It returns ErrNotFound when the document does not exist.
+func (index *Index) Delete(ctx context.Context, id string, opts *WriteOptions) (*DocMeta, error) {
+	return index.write(ctx, id, "DELETE", index.docPath(id)+opts.query(), nil)
+}
+
+func (index *Index) write(ctx context.Context, id, method, u string, body interface{}) (*DocMeta, error) {
+	rsp, e := index.requestWithContext(ctx, method, u, body)
+	if rsp != nil && rsp.StatusCode == 409 {
+		return nil, &ConflictError{Index: index.Index, Id: id, Reason: errorReason(rsp)}
+	} else if docMissing(rsp) {
+		return nil, ErrNotFound
+	} else if e != nil {
+		return nil, e
+	}
+	meta := &DocMeta{}
+	if e := json.Unmarshal(rsp.Body, meta); e != nil {
+		return nil, e
+	}
+	return meta, nil
+}
+
+// docMissing returns true for 404 responses reporting a missing document (found false or result not_found).
+// Other 404 responses, e.g. for a missing index, are returned as *Error.
+func docMissing(rsp *HttpResponse) bool {
+	if rsp == nil || rsp.StatusCode != 404 {
+		return false
+	}
+	var doc struct {
+		Found  *bool  `json:"found"`
+		Result string `json:"result"`
+	}
+	if json.Unmarshal(rsp.Body, &doc) != nil {
+		return false
+	}
+	return doc.Found != nil && !*doc.Found || doc.Result == "not_found"
+}
+
+// errorReason returns the reason of an error response or the body when it can not be parsed.
+func errorReason(rsp *HttpResponse) string {
+	if reason := newError(rsp).Reason; reason != "" {
+		return reason
+	}
+	return string(rsp.Body)
+}
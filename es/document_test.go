@@ -0,0 +1,116 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type buildState struct {
+	Status  string `json:"status"`
+	Commits int    `json:"commits"`
+}
+
+func TestDocuments(t *testing.T) {
+	Convey("Single document API", t, func() {
+		ctx := context.Background()
+		s := estest.NewServer()
+		defer s.Close()
+		index := &Index{Client: NewClient(s.URL), Index: "builds"}
+		_, e := index.CreateIndex(IndexConfig{})
+		So(e, ShouldBeNil)
+
+		exists, e := index.Exists(ctx, "b1")
+		So(e, ShouldBeNil)
+		So(exists, ShouldBeFalse)
+		_, e = index.Get(ctx, "b1", nil)
+		So(e == ErrNotFound, ShouldBeTrue)
+
+		meta, e := index.Create(ctx, "b1", &buildState{Status: "pending", Commits: 1})
+		So(e, ShouldBeNil)
+		So(meta.Result, ShouldEqual, "created")
+		req, _ := last(s)
+		So(req, ShouldEqual, "PUT /builds/_doc/b1?op_type=create")
+
+		_, e = index.Create(ctx, "b1", &buildState{})
+		_, ok := e.(*ConflictError)
+		So(ok, ShouldBeTrue)
+
+		state := &buildState{}
+		meta, e = index.Get(ctx, "b1", state)
+		So(e, ShouldBeNil)
+		So(state.Status, ShouldEqual, "pending")
+		So(meta.SeqNo, ShouldEqual, 0)
+		So(meta.PrimaryTerm, ShouldEqual, 1)
+		So(meta.Version, ShouldEqual, 1)
+
+		Convey("conditional updates", func() {
+			updated, e := index.Update(ctx, "b1", map[string]string{"status": "running"}, IfMatch(meta))
+			So(e, ShouldBeNil)
+			So(updated.SeqNo, ShouldEqual, 1)
+			req, _ := last(s)
+			So(req, ShouldEqual, "POST /builds/_update/b1?if_primary_term=1&if_seq_no=0")
+
+			_, e = index.Update(ctx, "b1", map[string]string{"status": "failed"}, IfMatch(meta))
+			conflict, ok := e.(*ConflictError)
+			So(ok, ShouldBeTrue)
+			So(conflict.Id, ShouldEqual, "b1")
+			So(conflict.Reason, ShouldContainSubstring, "version conflict")
+
+			_, e = index.Get(ctx, "b1", state)
+			So(e, ShouldBeNil)
+			So(state.Status, ShouldEqual, "running")
+			So(state.Commits, ShouldEqual, 1)
+
+			_, e = index.Replace(ctx, "b1", &buildState{Status: "done"}, IfMatch(updated))
+			So(e, ShouldBeNil)
+		})
+
+		Convey("update and upsert", func() {
+			_, e := index.Update(ctx, "b2", map[string]string{"status": "running"}, nil)
+			So(errors.Is(e, ErrNotFound), ShouldBeTrue)
+			meta, e := index.Upsert(ctx, "b2", map[string]string{"status": "running"}, nil)
+			So(e, ShouldBeNil)
+			So(meta.Result, ShouldEqual, "created")
+			exists, e := index.Exists(ctx, "b2")
+			So(e, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+		})
+
+		Convey("delete", func() {
+			meta, e := index.Delete(ctx, "b1", &WriteOptions{Refresh: "wait_for"})
+			So(e, ShouldBeNil)
+			So(meta.Result, ShouldEqual, "deleted")
+			req, _ := last(s)
+			So(req, ShouldEqual, "DELETE /builds/_doc/b1?refresh=wait_for")
+			_, e = index.Delete(ctx, "b1", nil)
+			So(e == ErrNotFound, ShouldBeTrue)
+		})
+
+		Convey("paths with type", func() {
+			index.Type = "build"
+			So(index.docPath("a b"), ShouldEqual, "/builds/build/a%20b")
+			So(index.updatePath("a"), ShouldEqual, "/builds/build/a/_update")
+			So((&WriteOptions{Version: 3, VersionType: "external"}).query(), ShouldEqual, "?version=3&version_type=external")
+		})
+	})
+
+	Convey("missing indices", t, func() {
+		ctx := context.Background()
+		s := estest.NewServer()
+		defer s.Close()
+		index := &Index{Host: s.Host(), Port: s.Port(), Index: "missing"}
+		for _, e := range []error{
+			func() error { _, e := index.Get(ctx, "1", nil); return e }(),
+			func() error { _, e := index.Delete(ctx, "1", nil); return e }(),
+		} {
+			esErr, ok := e.(*Error)
+			So(ok, ShouldBeTrue)
+			So(esErr.Type, ShouldEqual, "index_not_found_exception")
+			So(errors.Is(e, ErrNotFound), ShouldBeTrue)
+		}
+	})
+}
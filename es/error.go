@@ -0,0 +1,126 @@
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors to be used with errors.Is, e.g. errors.Is(e, es.ErrIndexAlreadyExists). Besides the Errors of failed
+// requests, *ConflictError and *BulkItemError match them too.
+var (
+	ErrConflict           = errors.New("conflict")
+	ErrIndexAlreadyExists = errors.New("index already exists")
+	ErrRejectedExecution  = errors.New("rejected execution")
+)
+
+// Error is returned for responses with a status other than 2xx.
+type Error struct {
+	Status     int           // http status code
+	Type       string        // e.g. index_not_found_exception
+	Reason     string        // e.g. no such index [logs]
+	Index      string        // set for errors related to an index
+	RootCauses []*ErrorCause // the root causes reported by Elasticsearch
+	Body       []byte        // the raw response body
+}
+
+type ErrorCause struct {
+	Type     string      `json:"type"`
+	Reason   string      `json:"reason"`
+	Index    string      `json:"index,omitempty"`
+	CausedBy *ErrorCause `json:"caused_by,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("elasticsearch returned %d", e.Status)
+	switch {
+	case e.Type != "" && e.Reason != "":
+		msg += ": " + e.Type + ": " + e.Reason
+	case e.Reason != "":
+		msg += ": " + e.Reason
+	case len(e.Body) > 0:
+		msg += ": " + string(e.Body)
+	}
+	return msg
+}
+
+// Is reports whether the error matches ErrNotFound (any 404), ErrConflict, ErrIndexAlreadyExists or
+// ErrRejectedExecution (429 or a rejected execution in one of the root causes).
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == 404
+	case ErrConflict:
+		return e.Status == 409 || e.hasType(target)
+	case ErrRejectedExecution:
+		return e.Status == 429 || e.hasType(target)
+	case ErrIndexAlreadyExists:
+		return e.hasType(target)
+	}
+	return false
+}
+
+// hasType returns true when the type of the error or of one of its root causes matches target.
+func (e *Error) hasType(target error) bool {
+	if typeMatches(e.Type, target) {
+		return true
+	}
+	for _, cause := range e.RootCauses {
+		for c := cause; c != nil; c = c.CausedBy {
+			if typeMatches(c.Type, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorTypes maps the normalized error types of Elasticsearch (including the ones of versions before 5.0, e.g.
+// IndexAlreadyExistsException) to errors.
+var errorTypes = map[string]error{
+	"indexnotfoundexception":         ErrNotFound,
+	"indexmissingexception":          ErrNotFound,
+	"documentmissingexception":       ErrNotFound,
+	"resourcenotfoundexception":      ErrNotFound,
+	"versionconflictengineexception": ErrConflict,
+	"resourcealreadyexistsexception": ErrIndexAlreadyExists,
+	"indexalreadyexistsexception":    ErrIndexAlreadyExists,
+	"esrejectedexecutionexception":   ErrRejectedExecution,
+}
+
+func typeMatches(errType string, target error) bool {
+	return errType != "" && errorTypes[strings.ToLower(strings.Replace(errType, "_", "", -1))] == target
+}
+
+// newError parses the error of the response. Besides the current format ({"error":{"type":...}}) the string
+// errors of Elasticsearch before 1.0 ({"error":"IndexMissingException[[logs] missing]"}) are parsed.
+func newError(rsp *HttpResponse) *Error {
+	e := &Error{Status: rsp.StatusCode, Body: rsp.Body}
+	var raw struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(rsp.Body, &raw) != nil || len(raw.Error) == 0 {
+		return e
+	}
+	var legacy string
+	if json.Unmarshal(raw.Error, &legacy) == nil {
+		e.Reason = legacy
+		if i := strings.Index(legacy, "["); i > 0 {
+			e.Type = legacy[:i]
+		}
+		return e
+	}
+	var cause struct {
+		ErrorCause
+		RootCause []*ErrorCause `json:"root_cause"`
+	}
+	if json.Unmarshal(raw.Error, &cause) != nil {
+		return e
+	}
+	e.Type, e.Reason, e.Index, e.RootCauses = cause.Type, cause.Reason, cause.Index, cause.RootCause
+	if cause.CausedBy != nil && len(e.RootCauses) == 0 {
+		e.RootCauses = []*ErrorCause{cause.CausedBy}
+	}
+	return e
+}
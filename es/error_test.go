@@ -0,0 +1,132 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestError(t *testing.T) {
+	Convey("Error", t, func() {
+		for _, tc := range []struct {
+			Name   string
+			Status int
+			Body   string
+			Type   string
+			Reason string
+			Index  string
+			Is     []error
+		}{
+			{
+				Name:   "index not found",
+				Status: 404,
+				Body:   `{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index [logs]","index":"logs"}],"type":"index_not_found_exception","reason":"no such index [logs]","index":"logs"},"status":404}`,
+				Type:   "index_not_found_exception", Reason: "no such index [logs]", Index: "logs",
+				Is: []error{ErrNotFound},
+			},
+			{
+				Name:   "document not found",
+				Status: 404,
+				Body:   `{"_index":"logs","_id":"1","found":false}`,
+				Is:     []error{ErrNotFound},
+			},
+			{
+				Name:   "version conflict",
+				Status: 409,
+				Body:   `{"error":{"type":"version_conflict_engine_exception","reason":"[1]: version conflict"},"status":409}`,
+				Type:   "version_conflict_engine_exception", Reason: "[1]: version conflict",
+				Is: []error{ErrConflict},
+			},
+			{
+				Name:   "index exists",
+				Status: 400,
+				Body:   `{"error":{"type":"resource_already_exists_exception","reason":"index [logs/abc] already exists","index":"logs"},"status":400}`,
+				Type:   "resource_already_exists_exception", Reason: "index [logs/abc] already exists", Index: "logs",
+				Is: []error{ErrIndexAlreadyExists},
+			},
+			{
+				Name:   "legacy index exists",
+				Status: 400,
+				Body:   `{"error":"IndexAlreadyExistsException[[logs] already exists]","status":400}`,
+				Type:   "IndexAlreadyExistsException", Reason: "IndexAlreadyExistsException[[logs] already exists]",
+				Is: []error{ErrIndexAlreadyExists},
+			},
+			{
+				Name:   "rejected in root cause",
+				Status: 500,
+				Body:   `{"error":{"type":"search_phase_execution_exception","reason":"all shards failed","root_cause":[{"type":"transport_exception","reason":"failed","caused_by":{"type":"es_rejected_execution_exception","reason":"rejected execution"}}]},"status":500}`,
+				Type:   "search_phase_execution_exception", Reason: "all shards failed",
+				Is: []error{ErrRejectedExecution},
+			},
+			{
+				Name:   "too many requests",
+				Status: 429,
+				Body:   `{"error":{"type":"circuit_breaking_exception","reason":"data too large"},"status":429}`,
+				Type:   "circuit_breaking_exception", Reason: "data too large",
+				Is: []error{ErrRejectedExecution},
+			},
+			{
+				Name:   "invalid body",
+				Status: 502,
+				Body:   `bad gateway`,
+			},
+		} {
+			Convey(tc.Name, func() {
+				e := newError(&HttpResponse{Response: &http.Response{StatusCode: tc.Status}, Body: []byte(tc.Body)})
+				So(e.Status, ShouldEqual, tc.Status)
+				So(e.Type, ShouldEqual, tc.Type)
+				So(e.Reason, ShouldEqual, tc.Reason)
+				So(e.Index, ShouldEqual, tc.Index)
+				wrapped := fmt.Errorf("Error doing something: %w", e)
+				for _, target := range []error{ErrNotFound, ErrConflict, ErrIndexAlreadyExists, ErrRejectedExecution} {
+					expected := false
+					for _, is := range tc.Is {
+						expected = expected || is == target
+					}
+					So(errors.Is(wrapped, target), ShouldEqual, expected)
+				}
+			})
+		}
+
+		Convey("message", func() {
+			e := &Error{Status: 404, Type: "index_not_found_exception", Reason: "no such index [logs]"}
+			So(e.Error(), ShouldEqual, "elasticsearch returned 404: index_not_found_exception: no such index [logs]")
+			So((&Error{Status: 502, Body: []byte("bad gateway")}).Error(), ShouldEqual, "elasticsearch returned 502: bad gateway")
+		})
+
+		Convey("bulk items and conflicts", func() {
+			item := &BulkItemError{Type: "mapper_parsing_exception", CausedBy: &BulkItemError{Type: "es_rejected_execution_exception"}}
+			So(errors.Is(item, ErrRejectedExecution), ShouldBeTrue)
+			So(errors.Is(item, ErrConflict), ShouldBeFalse)
+			So(errors.Is(&ConflictError{Id: "1"}, ErrConflict), ShouldBeTrue)
+		})
+
+		Convey("returned by requests", func() {
+			ctx := context.Background()
+			s := estest.NewServer()
+			defer s.Close()
+			index := &Index{Host: s.Host(), Port: s.Port(), Index: "errors"}
+
+			_, e := index.Search(nil)
+			var esErr *Error
+			So(errors.As(e, &esErr), ShouldBeTrue)
+			So(esErr.Type, ShouldEqual, "index_not_found_exception")
+			So(esErr.Index, ShouldEqual, "errors")
+			So(errors.Is(index.RefreshContext(ctx), ErrNotFound), ShouldBeTrue)
+
+			_, e = index.CreateIndex(IndexConfig{})
+			So(e, ShouldBeNil)
+			_, e = index.CreateIndex(IndexConfig{})
+			So(errors.Is(e, ErrIndexAlreadyExists), ShouldBeTrue)
+
+			b, e := index.DeleteByQuery("Tag:[a")
+			So(e, ShouldNotBeNil)
+			So(string(b), ShouldContainSubstring, "parsing_exception")
+		})
+	})
+}
@@ -1,9 +1,12 @@
 package es
 
 import (
-	. "github.com/smartystreets/goconvey/convey"
+	"os"
 	"testing"
 	"time"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 type TestLog struct {
@@ -17,17 +20,17 @@ type TestLog struct {
 var sleepFor = 2 * time.Second
 
 var index = &Index{
-	Host:  "127.0.0.1",
-	Port:  9200,
 	Index: "test",
 	Type:  "logs",
 }
 
-func validateEsRunning(t *testing.T) {
-	_, e := index.Status()
-	if e != nil {
-		t.Fatalf("ElasticSearch is not running on %s. %s", index.BaseUrl(), e.Error())
-	}
+// TestMain points index to an in-memory fake so the tests do not need a running Elasticsearch.
+func TestMain(m *testing.M) {
+	server := estest.NewServer()
+	index.Host, index.Port = server.Host(), server.Port()
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
 }
 
 func setupIndex() error {
@@ -44,7 +47,6 @@ func setupIndex() error {
 }
 
 func TestDeleteFromImage(t *testing.T) {
-	validateEsRunning(t)
 	Convey("Delete from Index", t, func() {
 		So(setupIndex(), ShouldBeNil)
 		So(index.Refresh(), ShouldBeNil)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 )
 
+// Deprecated: facets were removed in Elasticsearch 2.0, use Aggregation
 type Facet struct {
 	Type           string      `json:"_type,omitempty"`
 	Missing        int         `json:"missing,omitempty"`
@@ -109,13 +110,13 @@ func (tf *TermsFacet) Load(m map[string]interface{}) error {
 }
 
 type DateHistogramFacet struct {
-	Type    string                     `json:"term,omitempty"`
+	Type    string                     `json:"_type,omitempty"`
 	Entries []*DateHistogramFacetEntry `json:"entries,omitempty"`
 }
 
 type DateHistogramFacetEntry struct {
 	Time       int64 `json:"time,omitempty"`
-	Count      int64 `json:"time,omitempty"`
+	Count      int64 `json:"count,omitempty"`
 	Min        float64
 	Max        float64
 	Total      int64
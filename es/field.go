@@ -1,24 +1,16 @@
 package es
 
 import (
-	"reflect"
+	"encoding/json"
 )
 
 type Source map[string]interface{}
 
+// Unmarshal decodes the source into i with the semantics of encoding/json.
 func (source *Source) Unmarshal(i interface{}) error {
-	value := reflect.ValueOf(i)
-	if value.Kind() == reflect.Ptr {
-		value = value.Elem()
+	b, e := json.Marshal(source)
+	if e != nil {
+		return e
 	}
-	for k, v := range *source {
-		field := value.FieldByName(k)
-		if field != reflect.Zero(value.Type()) {
-			newValue := reflect.ValueOf(v)
-			if field.CanSet() {
-				field.Set(newValue)
-			}
-		}
-	}
-	return nil
+	return json.Unmarshal(b, i)
 }
@@ -1,9 +1,60 @@
 package es
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type Hit struct {
-	Index  string  `json:"_index"`
-	Type   string  `json:"_type"`
-	Id     string  `json:"_id"`
-	Score  float64 `json:"_score"`
-	Source Source  `json:"_source"`
+	Index       string                   `json:"_index"`
+	Type        string                   `json:"_type"`
+	Id          string                   `json:"_id"`
+	Score       float64                  `json:"_score"`
+	Version     int64                    `json:"_version,omitempty"`
+	SeqNo       int64                    `json:"_seq_no,omitempty"`
+	PrimaryTerm int64                    `json:"_primary_term,omitempty"`
+	Routing     string                   `json:"_routing,omitempty"`
+	Source      Source                   `json:"_source"`
+	RawSource   json.RawMessage          `json:"-"` // _source as returned by Elasticsearch, used by Decode
+	Highlight   map[string][]string      `json:"highlight,omitempty"`
+	Fields      map[string][]interface{} `json:"fields,omitempty"`
+
+	Sort []interface{} `json:"sort,omitempty"` // sort values, used for search_after
+}
+
+func (hit *Hit) UnmarshalJSON(b []byte) error {
+	type plain Hit
+	raw := struct {
+		*plain
+		RawSource json.RawMessage `json:"_source"`
+	}{plain: (*plain)(hit)}
+	if e := json.Unmarshal(b, &raw); e != nil {
+		return e
+	}
+	hit.RawSource = raw.RawSource
+	hit.Source = nil
+	if len(raw.RawSource) > 0 {
+		return json.Unmarshal(raw.RawSource, &hit.Source)
+	}
+	return nil
+}
+
+// Decode decodes the _source of the hit into v using encoding/json.
+func (hit *Hit) Decode(v interface{}) error {
+	if len(hit.RawSource) == 0 {
+		if hit.Source == nil {
+			return fmt.Errorf("hit %s has no _source", hit.Id)
+		}
+		return hit.Source.Unmarshal(v)
+	}
+	return json.Unmarshal(hit.RawSource, v)
+}
+
+// Field returns the first value of a field requested with fields or docvalue_fields.
+func (hit *Hit) Field(name string) (interface{}, bool) {
+	values := hit.Fields[name]
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
 }
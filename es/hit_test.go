@@ -0,0 +1,86 @@
+package es
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type decodeLine struct {
+	Host     string    `json:"host"`
+	Status   int       `json:"status"`
+	Duration float64   `json:"duration"`
+	Tags     []string  `json:"tags"`
+	Time     time.Time `json:"time"`
+}
+
+func loadResponse(t *testing.T, name string) *Response {
+	b, e := ioutil.ReadFile("testdata/" + name)
+	if e != nil {
+		t.Fatal(e)
+	}
+	rsp := &Response{}
+	if e := json.Unmarshal(b, rsp); e != nil {
+		t.Fatal(e)
+	}
+	return rsp
+}
+
+func TestHitDecode(t *testing.T) {
+	Convey("Decode hits", t, func() {
+		rsp := loadResponse(t, "search_decode.json")
+		So(len(rsp.Hits.Hits), ShouldEqual, 2)
+
+		Convey("a single hit", func() {
+			hit := rsp.Hits.Hits[0]
+			line := &decodeLine{}
+			So(hit.Decode(line), ShouldBeNil)
+			So(line.Host, ShouldEqual, "host1")
+			So(line.Status, ShouldEqual, 200)
+			So(line.Duration, ShouldEqual, 0.25)
+			So(line.Tags, ShouldResemble, []string{"nginx"})
+			So(line.Time.Equal(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)), ShouldBeTrue)
+			So(hit.Source["host"], ShouldEqual, "host1")
+		})
+
+		Convey("metadata", func() {
+			hit := rsp.Hits.Hits[0]
+			So(hit.Version, ShouldEqual, 3)
+			So(hit.SeqNo, ShouldEqual, 7)
+			So(hit.PrimaryTerm, ShouldEqual, 1)
+			So(hit.Routing, ShouldEqual, "host1")
+			So(hit.Highlight["message"], ShouldResemble, []string{"GET <em>/index.html</em>"})
+			v, ok := hit.Field("status_class")
+			So(ok, ShouldBeTrue)
+			So(v, ShouldEqual, "2xx")
+			_, ok = rsp.Hits.Hits[1].Field("status_class")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("all hits into a slice", func() {
+			lines := []decodeLine{}
+			So(rsp.Decode(&lines), ShouldBeNil)
+			So(len(lines), ShouldEqual, 2)
+			So(lines[1].Host, ShouldEqual, "host2")
+			So(lines[1].Status, ShouldEqual, 502)
+
+			pointers := []*decodeLine{}
+			So(rsp.Decode(&pointers), ShouldBeNil)
+			So(len(pointers), ShouldEqual, 2)
+			So(pointers[0].Host, ShouldEqual, "host1")
+
+			So(rsp.Decode(lines), ShouldNotBeNil)
+		})
+
+		Convey("type mismatches return an error", func() {
+			var wrong struct {
+				Host int `json:"host"`
+			}
+			So(rsp.Hits.Hits[0].Decode(&wrong), ShouldNotBeNil)
+			So((&Source{"Host": "x"}).Unmarshal(&wrong), ShouldNotBeNil)
+		})
+	})
+}
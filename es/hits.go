@@ -1,7 +1,41 @@
 package es
 
+import (
+	"encoding/json"
+)
+
 type Hits struct {
 	Total    int     `json:"total"`
 	MaxScore float64 `json:"max_score"`
 	Hits     []*Hit  `json:"hits"`
 }
+
+// UnmarshalJSON accepts the total as number and as object ({"value": 10, "relation": "eq"}) as returned
+// since Elasticsearch 7.0.
+func (hits *Hits) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Total    json.RawMessage `json:"total"`
+		MaxScore float64         `json:"max_score"`
+		Hits     []*Hit          `json:"hits"`
+	}
+	if e := json.Unmarshal(b, &raw); e != nil {
+		return e
+	}
+	hits.MaxScore = raw.MaxScore
+	hits.Hits = raw.Hits
+	hits.Total = 0
+	if len(raw.Total) > 0 && raw.Total[0] == '{' {
+		var total struct {
+			Value int `json:"value"`
+		}
+		if e := json.Unmarshal(raw.Total, &total); e != nil {
+			return e
+		}
+		hits.Total = total.Value
+	} else if len(raw.Total) > 0 && string(raw.Total) != "null" {
+		if e := json.Unmarshal(raw.Total, &hits.Total); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -1,14 +1,14 @@
 package es
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,100 +24,29 @@ type Logger interface {
 	Error(format string, i ...interface{})
 }
 
-type Analysis struct {
-	Analyzer Analyzer `json:"analyzer"`
-}
-
-type Analyzer struct {
-	Default AnalyzerType `json:"default"`
-}
-
-type AnalyzerType struct {
-	Type      string `json:"type,omitempty"`
-	Tokenizer string `json:"tokenizer,omitempty"`
-}
-
-type IndexConfig struct {
-	Index IndexIndexConfig `json:"index"`
-}
-
-type IndexIndexConfig struct {
-	Analysis Analysis `json:"analysis"`
-}
-
-func KeywordIndex() IndexConfig {
-	return IndexConfig{
-		Index: IndexIndexConfig{
-			Analysis: Analysis{
-				Analyzer: Analyzer{
-					Default: AnalyzerType{
-						Tokenizer: "whitespace",
-					},
-				},
-			},
-		},
-	}
-}
-
 func (index *Index) CreateIndex(config IndexConfig) (rsp *HttpResponse, e error) {
-	return index.request("PUT", index.IndexUrl(), config)
+	return index.request("PUT", index.Path(), config)
 }
 
 type Term map[string]interface{}
 
 type Terms map[string][]interface{}
 
+// Filter is used by the filtered query. Deprecated: filtered was removed in Elasticsearch 5.0, use BoolQuery.
 type Filter struct {
-	And   []*Filter         `json:"and,omitempty`
+	And   []*Filter         `json:"and,omitempty"`
 	Term  *Term             `json:"term,omitempty"`
-	Terms *Terms            `json:"term,omitempty"`
+	Terms *Terms            `json:"terms,omitempty"`
 	Range map[string]*Range `json:"range,omitempty"`
 }
 
 type Range struct {
-	From interface{}
-	To   interface{}
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
 }
 
 type Filtered struct {
-	Filter *Filter
-}
-
-type QueryString struct {
-	Query           string `json:"query,omitempty"`
-	DefaultOperator string `json:"default_operator,omitempty"`
-}
-
-type Query struct {
-	Filtered    *Filtered    `json:"filtered,omitempty"`
-	QueryString *QueryString `json:"query_string,omitempty"`
-}
-
-var query = Query{
-	Filtered: &Filtered{
-		Filter: &Filter{
-			And: []*Filter{
-				{
-					Term: &Term{
-						"Device": "Anrdoi",
-					},
-				},
-				{
-					Terms: &Terms{
-						"Action": []interface{}{
-							"api/v1/my/photos#create",
-							"api/v1/photos#create",
-						},
-					},
-					Range: map[string]*Range{
-						"Time": {
-							From: "",
-						},
-					},
-				},
-			},
-		},
-	},
+	Filter *Filter `json:"filter,omitempty"`
 }
 
 type BulkIndexJob struct {
@@ -136,17 +65,61 @@ type Index struct {
 	BatchSize int
 	Debug     bool
 	Logger    Logger
+	Client    *Client // used for all requests, defaults to a client for http://<Host>:<Port>
+
+	BulkRetries   int            // retries of rejected bulk items, defaults to DefaultBulkRetries, -1 disables retries
+	BulkBackoff   time.Duration  // initial wait before retrying rejected items, defaults to DefaultBulkBackoff
+	OnBulkFailure FailureHandler // receives items which failed permanently, IndexDocs returns a *BulkError when nil
+
+	defaultClient *Client
+	lock          sync.Mutex
+}
+
+// client returns Client or the default client, which is created once and replaced when Host or Port change.
+func (index *Index) client() *Client {
+	if index.Client != nil {
+		return index.Client
+	}
+	base := index.BaseUrl()
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if index.defaultClient == nil || index.defaultClient.Nodes[0] != base {
+		index.defaultClient = &Client{Nodes: []string{base}, MaxRetries: -1, Logger: index.Logger}
+	}
+	return index.defaultClient
+}
+
+// Path returns the path of the index (e.g. "/logs").
+func (index *Index) Path() string {
+	if index.Index == "" {
+		return ""
+	}
+	return "/" + index.Index
+}
+
+// TypePath returns the path of the index and type (e.g. "/logs/line").
+func (index *Index) TypePath() string {
+	if index.Type == "" {
+		return index.Path()
+	}
+	return index.Path() + "/" + index.Type
 }
 
 func (index *Index) IndexExists() (exists bool, e error) {
+	return index.IndexExistsContext(context.Background())
+}
+
+func (index *Index) IndexExistsContext(ctx context.Context) (exists bool, e error) {
 	if index.Index == "" {
 		return false, fmt.Errorf("no index set")
 	}
-	rsp, e := http.Get(index.IndexUrl() + "/_status")
-	if e != nil {
+	rsp, e := index.requestWithContext(ctx, "HEAD", index.Path(), nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return false, nil
+	} else if e != nil {
 		return false, e
 	}
-	return rsp.Status[0] == '2', nil
+	return true, nil
 }
 
 func (index *Index) EnqueueDoc(doc *Doc) (indexed bool, e error) {
@@ -174,57 +147,40 @@ func (index *Index) EnqueueBulkIndex(key string, record interface{}) (bool, erro
 }
 
 func (index *Index) DeleteIndex() error {
-	req, e := http.NewRequest("DELETE", index.IndexUrl(), nil)
-	if e != nil {
-		return e
+	return index.DeleteIndexContext(context.Background())
+}
+
+func (index *Index) DeleteIndexContext(ctx context.Context) error {
+	if index.Index == "" {
+		return fmt.Errorf("no index set")
 	}
-	rsp, e := http.DefaultClient.Do(req)
+	_, e := index.requestWithContext(ctx, "DELETE", index.Path(), nil)
 	if e != nil {
-		return e
-	}
-	defer rsp.Body.Close()
-	if rsp.Status[0] != '2' {
-		return fmt.Errorf("Error delting index at %s: %s", index.TypeUrl(), rsp.Status)
+		return fmt.Errorf("Error delting index at %s: %w", index.TypeUrl(), e)
 	}
 	return nil
 }
 
 func (index *Index) Refresh() error {
-	rsp, e := index.request("POST", index.IndexUrl()+"/_refresh", nil)
+	return index.RefreshContext(context.Background())
+}
+
+func (index *Index) RefreshContext(ctx context.Context) error {
+	_, e := index.requestWithContext(ctx, "POST", index.Path()+"/_refresh", nil)
 	if e != nil {
-		return e
-	}
-	if rsp.Status[0] != '2' {
-		return fmt.Errorf("Error refreshing index: %s", rsp.Status)
+		return fmt.Errorf("Error refreshing index: %w", e)
 	}
 	return nil
 }
 
 func (index *Index) IndexDocs(docs []*Doc) error {
-	buf := &bytes.Buffer{}
-	enc := json.NewEncoder(buf)
-	for _, doc := range docs {
-		if doc.Index == "" {
-			doc.Index = index.Index
-		}
-		if doc.Type == "" {
-			doc.Type = index.Type
-		}
-		enc.Encode(map[string]map[string]string{
-			"index": doc.IndexAttributes(),
-		})
-		enc.Encode(doc.Source)
-	}
-	rsp, e := http.Post(index.BaseUrl()+"/_bulk", "application/json", buf)
-	if e != nil {
-		return e
-	}
-	defer rsp.Body.Close()
-	b, _ := ioutil.ReadAll(rsp.Body)
-	if rsp.Status[0] != OK {
-		return fmt.Errorf("Error sending bulk request: %s %s", rsp.Status, string(b))
-	}
-	return nil
+	return index.IndexDocsContext(context.Background(), docs)
+}
+
+// IndexDocsContext indexes the docs with bulk requests. Items rejected by Elasticsearch (429) are retried,
+// items failing permanently are passed to OnBulkFailure.
+func (index *Index) IndexDocsContext(ctx context.Context, docs []*Doc) error {
+	return index.handleFailures(index.BulkWithRetries(ctx, docs))
 }
 
 func (index *Index) RunBatchIndex() error {
@@ -254,21 +210,20 @@ type Shards struct {
 }
 
 func (index *Index) Status() (status *Status, e error) {
-	rsp, e := http.Get(index.BaseUrl() + "/_status")
-	if e != nil {
-		return nil, e
-	}
-	defer rsp.Body.Close()
-	b, e := ioutil.ReadAll(rsp.Body)
+	return index.StatusContext(context.Background())
+}
+
+// StatusContext returns the shard status of the index (of all indices when no index is set). Ok is true
+// when no shard failed. Use Stats or Client.ClusterHealth for details.
+func (index *Index) StatusContext(ctx context.Context) (status *Status, e error) {
+	stats, e := index.stats(ctx)
 	if e != nil {
 		return nil, e
 	}
-	if rsp.Status[0] != '2' {
-		return nil, fmt.Errorf("Status: %d, Response: %s", rsp.StatusCode, string(b))
+	if stats.Shards == nil {
+		return nil, fmt.Errorf("Error getting status: no _shards in response")
 	}
-	status = &Status{}
-	e = json.Unmarshal(b, status)
-	return status, e
+	return &Status{Ok: stats.Shards.Failed == 0, Shards: stats.Shards}, nil
 }
 
 func init() {
@@ -288,10 +243,13 @@ func (index *Index) LogInfo(format string, i ...interface{}) {
 }
 
 func (index *Index) GlobalMapping() (m *Mapping, e error) {
+	return index.GlobalMappingContext(context.Background())
+}
+
+func (index *Index) GlobalMappingContext(ctx context.Context) (m *Mapping, e error) {
 	m = &Mapping{}
-	u := index.BaseUrl() + "/_mapping"
-	index.LogInfo("checking for url %s", u)
-	rsp, e := index.request("GET", u, m)
+	index.LogInfo("checking for url %s", index.BaseUrl()+"/_mapping")
+	rsp, e := index.requestWithContext(ctx, "GET", "/_mapping", nil)
 	if rsp != nil && rsp.StatusCode == 404 {
 		return nil, nil
 	} else if e != nil {
@@ -305,9 +263,12 @@ func (index *Index) GlobalMapping() (m *Mapping, e error) {
 }
 
 func (index *Index) Mapping() (i interface{}, e error) {
-	u := index.IndexUrl() + "/_mapping"
-	index.LogInfo("checking for url %s", u)
-	rsp, e := index.request("GET", u, i)
+	return index.MappingContext(context.Background())
+}
+
+func (index *Index) MappingContext(ctx context.Context) (i interface{}, e error) {
+	index.LogInfo("checking for url %s", index.IndexUrl()+"/_mapping")
+	rsp, e := index.requestWithContext(ctx, "GET", index.Path()+"/_mapping", nil)
 	if rsp != nil && rsp.StatusCode == 404 {
 		return nil, nil
 	} else if e != nil {
@@ -321,10 +282,18 @@ func (index *Index) Mapping() (i interface{}, e error) {
 }
 
 func (index *Index) PutMapping(mapping interface{}) (rsp *HttpResponse, e error) {
-	return index.request("PUT", index.IndexUrl()+"/", mapping)
+	return index.PutMappingContext(context.Background(), mapping)
+}
+
+func (index *Index) PutMappingContext(ctx context.Context, mapping interface{}) (rsp *HttpResponse, e error) {
+	return index.requestWithContext(ctx, "PUT", index.Path()+"/", mapping)
 }
 
+// BaseUrl returns the url of the first node of the Client or http://<Host>:<Port>.
 func (index *Index) BaseUrl() string {
+	if index.Client != nil && index.Host == "" && len(index.Client.Nodes) > 0 {
+		return strings.TrimSuffix(index.Client.Nodes[0], "/")
+	}
 	if index.Port == 0 {
 		index.Port = 9200
 	}
@@ -332,63 +301,53 @@ func (index *Index) BaseUrl() string {
 }
 
 func (index *Index) IndexUrl() string {
-	if index.Index == "" {
-		return index.BaseUrl()
-	} else {
-		return index.BaseUrl() + "/" + index.Index
-	}
+	return index.BaseUrl() + index.Path()
 }
 
 func (index *Index) TypeUrl() string {
-	if index.Type == "" {
-		return index.IndexUrl()
-	} else if idx := index.IndexUrl(); idx != "" {
-		return idx + "/" + index.Type
-	} else {
-		return ""
-	}
+	return index.BaseUrl() + index.TypePath()
+}
+
+func (index *Index) SearchUrl() string {
+	return index.BaseUrl() + index.searchPath()
+}
+
+func (index *Index) searchPath() string {
+	return index.TypePath() + "/_search"
 }
 
 func (index *Index) DeleteByQuery(query string) (b []byte, e error) {
+	return index.DeleteByQueryContext(context.Background(), query)
+}
+
+func (index *Index) DeleteByQueryContext(ctx context.Context, query string) (b []byte, e error) {
 	q := &url.Values{}
 	q.Add("q", query)
-	rsp, e := index.request("DELETE", index.TypeUrl()+"/_query?"+q.Encode(), nil)
-	if e != nil {
-		return b, e
+	rsp, e := index.requestWithContext(ctx, "DELETE", index.TypePath()+"/_query?"+q.Encode(), nil)
+	if rsp != nil {
+		b = rsp.Body
 	}
-	if rsp.Status[0] != '2' {
-		return b, fmt.Errorf("error deleting with query: %s", rsp.Status)
+	if e != nil {
+		return b, fmt.Errorf("error deleting with query: %w", e)
 	}
 	return rsp.Body, nil
 }
 
 func (index *Index) Search(req *Request) (rsp *Response, e error) {
-	u := index.TypeUrl()
-	if !strings.HasSuffix(u, "/") {
-		u += "/"
-	}
-	u += "/_search"
-	httpRequest, e := http.NewRequest("POST", u, nil)
-	if e != nil {
-		return nil, e
-	}
+	return index.SearchContext(context.Background(), req)
+}
+
+func (index *Index) SearchContext(ctx context.Context, req *Request) (rsp *Response, e error) {
+	var body interface{}
 	if req != nil {
-		writer := &bytes.Buffer{}
-		js := json.NewEncoder(writer)
-		e = js.Encode(req)
-		if e != nil {
-			return nil, e
-		}
-		httpRequest.Body = ioutil.NopCloser(writer)
+		body = req
 	}
-	httpResponse, e := http.DefaultClient.Do(httpRequest)
+	httpResponse, e := index.requestWithContext(ctx, "POST", index.searchPath(), body)
 	if e != nil {
 		return nil, e
 	}
-	defer httpResponse.Body.Close()
-	dec := json.NewDecoder(httpResponse.Body)
 	rsp = &Response{}
-	e = dec.Decode(rsp)
+	e = json.Unmarshal(httpResponse.Body, rsp)
 	if e != nil {
 		return nil, e
 	}
@@ -396,19 +355,35 @@ func (index *Index) Search(req *Request) (rsp *Response, e error) {
 }
 
 func (index *Index) Post(u string, i interface{}) (*HttpResponse, error) {
-	return index.request("POST", u, i)
+	return index.PostContext(context.Background(), u, i)
+}
+
+func (index *Index) PostContext(ctx context.Context, u string, i interface{}) (*HttpResponse, error) {
+	return index.requestWithContext(ctx, "POST", u, i)
 }
 
 func (index *Index) PostObject(i interface{}) (*HttpResponse, error) {
-	return index.request("POST", index.TypeUrl(), i)
+	return index.PostObjectContext(context.Background(), i)
+}
+
+func (index *Index) PostObjectContext(ctx context.Context, i interface{}) (*HttpResponse, error) {
+	return index.requestWithContext(ctx, "POST", index.TypePath(), i)
 }
 
 func (index *Index) PutObject(id string, i interface{}) (*HttpResponse, error) {
-	return index.request("PUT", index.TypeUrl()+"/"+id, i)
+	return index.PutObjectContext(context.Background(), id, i)
+}
+
+func (index *Index) PutObjectContext(ctx context.Context, id string, i interface{}) (*HttpResponse, error) {
+	return index.requestWithContext(ctx, "PUT", index.TypePath()+"/"+id, i)
 }
 
 func (index *Index) Put(u string, i interface{}) (*HttpResponse, error) {
-	return index.request("PUT", u, i)
+	return index.PutContext(context.Background(), u, i)
+}
+
+func (index *Index) PutContext(ctx context.Context, u string, i interface{}) (*HttpResponse, error) {
+	return index.requestWithContext(ctx, "PUT", u, i)
 }
 
 type HttpResponse struct {
@@ -417,38 +392,16 @@ type HttpResponse struct {
 }
 
 func (index *Index) request(method string, u string, i interface{}) (httpResponse *HttpResponse, e error) {
-	var req *http.Request
-	if i != nil {
-		buf := &bytes.Buffer{}
-		encoder := json.NewEncoder(buf)
-		if e := encoder.Encode(i); e != nil {
-			return nil, e
-		}
-		req, e = http.NewRequest(method, u, buf)
-	} else {
-		req, e = http.NewRequest(method, u, nil)
-	}
-	if e != nil {
-		return nil, e
-	}
-	rsp, e := http.DefaultClient.Do(req)
-	if e != nil {
-		return nil, e
-	}
-	defer rsp.Body.Close()
-	b, e := ioutil.ReadAll(rsp.Body)
+	return index.requestWithContext(context.Background(), method, u, i)
+}
+
+// requestWithContext sends the request using the client of the index. u can be a path or an absolute url of
+// one of the nodes of the client.
+func (index *Index) requestWithContext(ctx context.Context, method string, u string, i interface{}) (httpResponse *HttpResponse, e error) {
+	client := index.client()
+	p, e := client.requestPath(u)
 	if e != nil {
 		return nil, e
 	}
-	httpResponse = &HttpResponse{
-		Response: rsp,
-		Body:     b,
-	}
-	if e != nil {
-		return httpResponse, e
-	}
-	if rsp.Status[0] != OK {
-		return httpResponse, fmt.Errorf("error indexing: %s %s", rsp.Status, string(b))
-	}
-	return httpResponse, nil
+	return client.Do(ctx, method, p, i)
 }
@@ -0,0 +1,120 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type IndexStats struct {
+	Primaries *IndexStatsValues `json:"primaries"`
+	Total     *IndexStatsValues `json:"total"`
+}
+
+type IndexStatsValues struct {
+	Docs     *DocsStats  `json:"docs"`
+	Store    *StoreStats `json:"store"`
+	Indexing *struct {
+		IndexTotal        int64 `json:"index_total"`
+		IndexTimeInMillis int64 `json:"index_time_in_millis"`
+		IndexFailed       int64 `json:"index_failed"`
+	} `json:"indexing,omitempty"`
+	Search *struct {
+		QueryTotal        int64 `json:"query_total"`
+		QueryTimeInMillis int64 `json:"query_time_in_millis"`
+	} `json:"search,omitempty"`
+	Segments *struct {
+		Count int `json:"count"`
+	} `json:"segments,omitempty"`
+}
+
+type indexStatsResponse struct {
+	Shards  *Shards                `json:"_shards"`
+	All     *IndexStats            `json:"_all"`
+	Indices map[string]*IndexStats `json:"indices"`
+}
+
+func (index *Index) stats(ctx context.Context) (*indexStatsResponse, error) {
+	rsp, e := index.requestWithContext(ctx, "GET", index.Path()+"/_stats", nil)
+	if e != nil {
+		return nil, e
+	}
+	stats := &indexStatsResponse{}
+	if e := json.Unmarshal(rsp.Body, stats); e != nil {
+		return nil, e
+	}
+	return stats, nil
+}
+
+// Stats returns the stats of the index summed up over all indices when the index is a pattern or alias.
+func (index *Index) Stats(ctx context.Context) (*IndexStats, error) {
+	stats, e := index.stats(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("Error getting stats of index %s: %w", index.Index, e)
+	}
+	if stats.All == nil {
+		return nil, fmt.Errorf("Error getting stats of index %s: no _all stats in response", index.Index)
+	}
+	return stats.All, nil
+}
+
+// Count returns the number of documents matching the query, all documents are counted when query is nil.
+func (index *Index) Count(ctx context.Context, query *Query) (int64, error) {
+	var body interface{}
+	if query != nil {
+		body = map[string]*Query{"query": query}
+	}
+	rsp, e := index.requestWithContext(ctx, "POST", index.TypePath()+"/_count", body)
+	if e != nil {
+		return 0, fmt.Errorf("Error counting documents: %w", e)
+	}
+	var res struct {
+		Count int64 `json:"count"`
+	}
+	if e := json.Unmarshal(rsp.Body, &res); e != nil {
+		return 0, e
+	}
+	return res.Count, nil
+}
+
+// Open opens a closed index.
+func (index *Index) Open(ctx context.Context) error {
+	if index.Index == "" {
+		return fmt.Errorf("no index set")
+	}
+	_, e := index.requestWithContext(ctx, "POST", index.Path()+"/_open", nil)
+	if e != nil {
+		return fmt.Errorf("Error opening index %s: %w", index.Index, e)
+	}
+	return nil
+}
+
+// Close closes the index. Closed indices can not be read or written but keep their data.
+func (index *Index) Close(ctx context.Context) error {
+	if index.Index == "" {
+		return fmt.Errorf("no index set")
+	}
+	_, e := index.requestWithContext(ctx, "POST", index.Path()+"/_close", nil)
+	if e != nil {
+		return fmt.Errorf("Error closing index %s: %w", index.Index, e)
+	}
+	return nil
+}
+
+// ForceMerge merges the segments of the index down to maxSegments (e.g. 1 for indices which are no longer
+// written). The number of segments is determined by Elasticsearch when maxSegments is 0.
+func (index *Index) ForceMerge(ctx context.Context, maxSegments int) error {
+	if index.Index == "" {
+		return fmt.Errorf("no index set")
+	}
+	p := index.Path() + "/_forcemerge"
+	if maxSegments > 0 {
+		p += "?max_num_segments=" + strconv.Itoa(maxSegments)
+	}
+	_, e := index.requestWithContext(ctx, "POST", p, nil)
+	if e != nil {
+		return fmt.Errorf("Error force merging index %s: %w", index.Index, e)
+	}
+	return nil
+}
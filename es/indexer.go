@@ -1,97 +1,266 @@
 package es
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var timer = time.NewTimer(1 * time.Second)
+const (
+	DefaultIndexerBatchSize  = 100
+	DefaultIndexerIndexEvery = 1 * time.Hour
+	DefaultIndexerWorkers    = 1
+)
 
+// IndexerStats holds the totals of all runs. Use Snapshot to read them while the indexer is running.
 type IndexerStats struct {
 	Runs        int64
 	IndexedDocs int64
+	FailedDocs  int64
+	FailedRuns  int64
+	Bytes       int64
 	TotalTime   time.Duration
 }
 
 func (stats *IndexerStats) Add(count int, dur time.Duration) {
-	stats.Runs++
-	stats.IndexedDocs += int64(count)
-	stats.TotalTime += dur
+	atomic.AddInt64(&stats.Runs, 1)
+	atomic.AddInt64(&stats.IndexedDocs, int64(count))
+	atomic.AddInt64((*int64)(&stats.TotalTime), int64(dur))
+}
+
+func (stats *IndexerStats) add(run *IndexerRun) {
+	if run.Err != nil {
+		atomic.AddInt64(&stats.FailedRuns, 1)
+		if run.Failed == 0 {
+			// the bulk request itself failed
+			atomic.AddInt64(&stats.FailedDocs, int64(run.Docs))
+			return
+		}
+	}
+	stats.Add(run.Docs-run.Failed, run.Duration)
+	atomic.AddInt64(&stats.FailedDocs, int64(run.Failed))
+	atomic.AddInt64(&stats.Bytes, int64(run.Bytes))
+}
+
+func (stats *IndexerStats) Snapshot() IndexerStats {
+	return IndexerStats{
+		Runs:        atomic.LoadInt64(&stats.Runs),
+		IndexedDocs: atomic.LoadInt64(&stats.IndexedDocs),
+		FailedDocs:  atomic.LoadInt64(&stats.FailedDocs),
+		FailedRuns:  atomic.LoadInt64(&stats.FailedRuns),
+		Bytes:       atomic.LoadInt64(&stats.Bytes),
+		TotalTime:   time.Duration(atomic.LoadInt64((*int64)(&stats.TotalTime))),
+	}
+}
+
+// IndexerRun describes a single bulk request of an Indexer.
+type IndexerRun struct {
+	Worker   int
+	Docs     int
+	Failed   int // docs which failed permanently
+	Bytes    int // size of the bulk request, only set when BatchBytes is set
+	Duration time.Duration
+	Err      error
 }
 
+// Indexer indexes docs sent to the channel returned by Start in batches. Batches are flushed when they reach
+// BatchSize docs or BatchBytes bytes or IndexEvery passed, and are sent by Workers concurrent bulk requests.
+// Closing the channel or calling Finish flushes all remaining docs.
 type Indexer struct {
 	Index *Index
 
-	IndexEvery time.Duration // triggers a new index run after that duration, will be reset when BatchSize reached
+	IndexEvery time.Duration // triggers a new index run after that duration, will be reset when a batch is flushed
 	BatchSize  int           // triggers a new index run when the batch reaches that size
+	BatchBytes int           // triggers a new index run when the encoded batch reaches that size, 0 disables
+	Workers    int           // number of concurrent bulk requests, defaults to DefaultIndexerWorkers
+
+	OnError func(error)       // called for failed runs, errors are sent to Errors() when nil
+	OnRun   func(*IndexerRun) // called after every run
+
+	Stats IndexerStats
 
 	docsBatch   []*Doc
+	batchBytes  int
+	encoded     map[*Doc][]byte // bulk lines of the docs in docsBatch, only set when BatchBytes is set
 	docsChannel chan *Doc
-	timer       *time.Timer
-	Stats       IndexerStats
+	batches     chan *indexerBatch
+	errors      chan error
+	stop        chan struct{}
+	stopOnce    sync.Once
+	done        chan struct{}
+	workers     sync.WaitGroup
+	firstErr    error
+	errLock     sync.Mutex
+}
+
+// Errors returns the errors of failed runs when OnError is not set. Errors are dropped when nobody receives
+// them.
+func (indexer *Indexer) Errors() <-chan error {
+	return indexer.errors
 }
 
+// Finish stops the indexer, indexes all docs which were already sent and waits for all running requests. It
+// returns the first error of all runs. No docs must be sent after calling Finish.
 func (indexer *Indexer) Finish() error {
-	return indexer.indexBatch()
+	if indexer.done == nil {
+		return nil
+	}
+	indexer.stopOnce.Do(func() { close(indexer.stop) })
+	<-indexer.done
+	indexer.errLock.Lock()
+	defer indexer.errLock.Unlock()
+	return indexer.firstErr
 }
 
 func (indexer *Indexer) resetBatch() {
 	indexer.docsBatch = make([]*Doc, 0, indexer.BatchSize)
-}
-
-func (indexer *Indexer) resetTimer() bool {
-	return indexer.timer.Reset(indexer.IndexEvery)
+	indexer.batchBytes = 0
+	indexer.encoded = nil
+	if indexer.BatchBytes > 0 {
+		indexer.encoded = make(map[*Doc][]byte, indexer.BatchSize)
+	}
 }
 
 func (indexer *Indexer) Start() chan *Doc {
 	if indexer.BatchSize == 0 {
-		indexer.BatchSize = 100
+		indexer.BatchSize = DefaultIndexerBatchSize
 	}
 	if indexer.IndexEvery == 0 {
-		indexer.IndexEvery = 1 * time.Hour
+		indexer.IndexEvery = DefaultIndexerIndexEvery
+	}
+	if indexer.Workers == 0 {
+		indexer.Workers = DefaultIndexerWorkers
 	}
-	indexer.timer = time.NewTimer(indexer.IndexEvery)
 	indexer.docsChannel = make(chan *Doc, indexer.BatchSize)
+	indexer.batches = make(chan *indexerBatch, indexer.Workers)
+	indexer.errors = make(chan error, indexer.Workers)
+	indexer.stop = make(chan struct{})
+	indexer.done = make(chan struct{})
 	indexer.resetBatch()
-	go func(indexer *Indexer) {
-		for {
-			select {
-			case <-indexer.timer.C:
-				indexer.indexBatch()
-				indexer.resetTimer()
-			case doc, ok := <-indexer.docsChannel:
-				if !ok {
-					indexer.timer.Stop()
-					indexer.indexBatch()
+	for i := 0; i < indexer.Workers; i++ {
+		indexer.workers.Add(1)
+		go indexer.work(i)
+	}
+	go indexer.run()
+	return indexer.docsChannel
+}
+
+func (indexer *Indexer) run() {
+	defer func() {
+		indexer.flush()
+		close(indexer.batches)
+		indexer.workers.Wait()
+		close(indexer.done)
+	}()
+	timer := time.NewTimer(indexer.IndexEvery)
+	defer func() { timer.Stop() }()
+	for {
+		select {
+		case <-timer.C:
+			indexer.flush()
+			timer = time.NewTimer(indexer.IndexEvery)
+		case <-indexer.stop:
+			// docs already buffered in the channel are part of the final batch
+			for {
+				select {
+				case doc, ok := <-indexer.docsChannel:
+					if !ok {
+						return
+					}
+					indexer.add(doc)
+				default:
 					return
 				}
-				if doc.Index == "" {
-					doc.Index = indexer.Index.Index
-				}
-				if doc.Type == "" {
-					doc.Type = indexer.Index.Type
-				}
-				indexer.docsBatch = append(indexer.docsBatch, doc)
-				if len(indexer.docsBatch) >= indexer.BatchSize {
-					indexer.timer.Stop()
-					indexer.indexBatch()
-					indexer.resetTimer()
-				}
+			}
+		case doc, ok := <-indexer.docsChannel:
+			if !ok {
+				return
+			}
+			if indexer.add(doc) {
+				timer.Stop()
+				timer = time.NewTimer(indexer.IndexEvery)
 			}
 		}
-	}(indexer)
-	return indexer.docsChannel
+	}
+}
+
+// add appends doc to the batch and returns true when the batch was flushed.
+func (indexer *Indexer) add(doc *Doc) bool {
+	if doc.Index == "" {
+		doc.Index = indexer.Index.Index
+	}
+	if doc.Type == "" {
+		doc.Type = indexer.Index.Type
+	}
+	indexer.docsBatch = append(indexer.docsBatch, doc)
+	if indexer.BatchBytes > 0 {
+		// the encoded doc is sent as it is, invalid docs are left to fail in the bulk request
+		if b, e := encodeBulk([]*Doc{doc}, nil); e == nil {
+			indexer.encoded[doc] = b
+			indexer.batchBytes += len(b)
+		}
+	}
+	if len(indexer.docsBatch) >= indexer.BatchSize || (indexer.BatchBytes > 0 && indexer.batchBytes >= indexer.BatchBytes) {
+		indexer.flush()
+		return true
+	}
+	return false
 }
 
-func (indexer *Indexer) indexBatch() error {
+// flush hands the current batch to the workers, it blocks when all workers are busy.
+func (indexer *Indexer) flush() {
 	if len(indexer.docsBatch) < 1 {
-		return nil
+		return
 	}
+	indexer.batches <- &indexerBatch{docs: indexer.docsBatch, bytes: indexer.batchBytes, encoded: indexer.encoded}
+	indexer.resetBatch()
+}
+
+type indexerBatch struct {
+	docs    []*Doc
+	bytes   int
+	encoded map[*Doc][]byte
+}
+
+func (indexer *Indexer) work(worker int) {
+	defer indexer.workers.Done()
+	for batch := range indexer.batches {
+		run := indexer.indexBatch(batch)
+		run.Bytes = batch.bytes
+		run.Worker = worker
+		indexer.Stats.add(run)
+		if run.Err != nil {
+			indexer.handleError(run.Err)
+		}
+		if indexer.OnRun != nil {
+			indexer.OnRun(run)
+		}
+	}
+}
+
+func (indexer *Indexer) indexBatch(batch *indexerBatch) *IndexerRun {
+	run := &IndexerRun{Docs: len(batch.docs)}
 	started := time.Now()
-	e := indexer.Index.IndexDocs(indexer.docsBatch)
-	if e != nil {
-		return e
+	failures, e := indexer.Index.bulkWithRetries(context.Background(), batch.docs, batch.encoded)
+	run.Duration = time.Since(started)
+	run.Failed = len(failures)
+	run.Err = indexer.Index.handleFailures(failures, e)
+	return run
+}
+
+func (indexer *Indexer) handleError(e error) {
+	indexer.errLock.Lock()
+	if indexer.firstErr == nil {
+		indexer.firstErr = e
+	}
+	indexer.errLock.Unlock()
+	if indexer.OnError != nil {
+		indexer.OnError(e)
+		return
+	}
+	select {
+	case indexer.errors <- e:
+	default:
 	}
-	indexer.Stats.Add(len(indexer.docsBatch), time.Now().Sub(started))
-	indexer.resetBatch()
-	return nil
 }
@@ -1,9 +1,14 @@
 package es
 
 import (
-	. "github.com/smartystreets/goconvey/convey"
+	"fmt"
+	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 func waitFor(checkEvery time.Duration, maxWait time.Duration, check func() bool) bool {
@@ -25,7 +30,8 @@ func waitFor(checkEvery time.Duration, maxWait time.Duration, check func() bool)
 func TestIndexer(t *testing.T) {
 	Convey("Indexer", t, func() {
 		index.DeleteIndex()
-		index.Refresh()
+		_, e := index.CreateIndex(IndexConfig{})
+		So(e, ShouldBeNil)
 		indexer := &Indexer{Index: index, IndexEvery: 100 * time.Millisecond, BatchSize: 4}
 		So(indexer, ShouldNotBeNil)
 		ch := indexer.Start()
@@ -38,18 +44,18 @@ func TestIndexer(t *testing.T) {
 		So(e, ShouldBeNil)
 		So(rsp, ShouldNotBeNil)
 		So(rsp.Hits.Total, ShouldEqual, 0)
-		So(indexer.Stats.Runs, ShouldEqual, 0)
+		So(indexer.Stats.Snapshot().Runs, ShouldEqual, 0)
 
 		check := waitFor(10*time.Millisecond, 500*time.Millisecond, func() bool {
-			return (indexer.Stats.Runs == 1) && (indexer.Stats.IndexedDocs == 3)
+			return (indexer.Stats.Snapshot().Runs == 1) && (indexer.Stats.Snapshot().IndexedDocs == 3)
 		})
 		So(check, ShouldBeTrue)
 		if !check {
 			t.Fatal("timeout waiting for indexing")
 		}
 		index.Refresh()
-		So(indexer.Stats.Runs, ShouldEqual, 1)
-		So(indexer.Stats.IndexedDocs, ShouldEqual, 3)
+		So(indexer.Stats.Snapshot().Runs, ShouldEqual, 1)
+		So(indexer.Stats.Snapshot().IndexedDocs, ShouldEqual, 3)
 		rsp, e = index.Search(nil)
 		So(e, ShouldBeNil)
 		So(rsp, ShouldNotBeNil)
@@ -60,27 +66,145 @@ func TestIndexer(t *testing.T) {
 		ch <- &Doc{Source: Source{"Raw": "Line 7"}}
 
 		check = waitFor(10*time.Millisecond, 1*time.Second, func() bool {
-			return (indexer.Stats.Runs == 2) && (indexer.Stats.IndexedDocs == 7)
+			return (indexer.Stats.Snapshot().Runs == 2) && (indexer.Stats.Snapshot().IndexedDocs == 7)
 		})
 		So(check, ShouldBeTrue)
 		if !check {
 			t.Fatal("timeout waiting for indexing")
 		}
 		index.Refresh()
-		So(indexer.Stats.Runs, ShouldEqual, 2)
-		So(indexer.Stats.IndexedDocs, ShouldEqual, 7)
+		So(indexer.Stats.Snapshot().Runs, ShouldEqual, 2)
+		So(indexer.Stats.Snapshot().IndexedDocs, ShouldEqual, 7)
 		ch <- &Doc{Source: Source{"Raw": "Line 8"}}
 		close(ch)
 
 		check = waitFor(10*time.Millisecond, 1*time.Second, func() bool {
-			return (indexer.Stats.Runs == 3) && (indexer.Stats.IndexedDocs == 8)
+			return (indexer.Stats.Snapshot().Runs == 3) && (indexer.Stats.Snapshot().IndexedDocs == 8)
 		})
 		So(check, ShouldBeTrue)
 		if !check {
 			t.Fatal("timeout waiting for indexing")
 		}
 		index.Refresh()
-		So(indexer.Stats.Runs, ShouldEqual, 3)
-		So(indexer.Stats.IndexedDocs, ShouldEqual, 8)
+		So(indexer.Stats.Snapshot().Runs, ShouldEqual, 3)
+		So(indexer.Stats.Snapshot().IndexedDocs, ShouldEqual, 8)
+	})
+}
+
+type countingSource struct {
+	encoded int32
+}
+
+func (source *countingSource) MarshalJSON() ([]byte, error) {
+	atomic.AddInt32(&source.encoded, 1)
+	return []byte(`{"Raw":"line"}`), nil
+}
+
+func TestIndexerWorkers(t *testing.T) {
+	Convey("Indexer with workers", t, func() {
+		s := estest.NewServer()
+		defer s.Close()
+		index := bulkIndex(s)
+
+		Convey("flushes by doc count using concurrent requests", func() {
+			var inFlight, maxInFlight int32
+			handler := s.Config.Handler
+			s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				current := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				handler.ServeHTTP(w, r)
+			})
+			indexer := &Indexer{Index: index, BatchSize: 2, Workers: 3}
+			ch := indexer.Start()
+			for i := 0; i < 12; i++ {
+				ch <- &Doc{Id: fmt.Sprint(i), Source: Source{"Raw": "line"}}
+			}
+			So(indexer.Finish(), ShouldBeNil)
+			stats := indexer.Stats.Snapshot()
+			So(stats.Runs, ShouldEqual, 6)
+			So(stats.IndexedDocs, ShouldEqual, 12)
+			So(len(s.Requests()), ShouldEqual, 6)
+			So(maxInFlight, ShouldBeGreaterThan, 1)
+		})
+
+		Convey("flushes by bytes", func() {
+			runs := make(chan *IndexerRun, 10)
+			indexer := &Indexer{Index: index, BatchSize: 100, BatchBytes: 100, OnRun: func(run *IndexerRun) { runs <- run }}
+			ch := indexer.Start()
+			for i := 0; i < 4; i++ {
+				ch <- &Doc{Id: fmt.Sprint(i), Source: Source{"Raw": "a line of 50 bytes ......................."}}
+			}
+			So(indexer.Finish(), ShouldBeNil)
+			close(runs)
+			docs := []int{}
+			for run := range runs {
+				docs = append(docs, run.Docs)
+				So(run.Bytes, ShouldBeGreaterThanOrEqualTo, 100)
+			}
+			So(docs, ShouldResemble, []int{2, 2})
+			So(indexer.Stats.Snapshot().Bytes, ShouldBeGreaterThanOrEqualTo, 200)
+		})
+
+		Convey("encodes docs only once when counting bytes", func() {
+			source := &countingSource{}
+			indexer := &Indexer{Index: index, BatchSize: 100, BatchBytes: 1000}
+			ch := indexer.Start()
+			ch <- &Doc{Id: "1", Source: source}
+			So(indexer.Finish(), ShouldBeNil)
+			So(indexer.Stats.Snapshot().IndexedDocs, ShouldEqual, 1)
+			So(atomic.LoadInt32(&source.encoded), ShouldEqual, 1)
+		})
+
+		Convey("flushes by interval", func() {
+			runs := make(chan *IndexerRun, 10)
+			indexer := &Indexer{Index: index, IndexEvery: 10 * time.Millisecond, OnRun: func(run *IndexerRun) { runs <- run }}
+			ch := indexer.Start()
+			ch <- &Doc{Source: Source{"Raw": "line"}}
+			select {
+			case run := <-runs:
+				So(run.Docs, ShouldEqual, 1)
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for run")
+			}
+			So(indexer.Finish(), ShouldBeNil)
+		})
+
+		Convey("drains the channel when closed", func() {
+			runs := make(chan *IndexerRun, 10)
+			indexer := &Indexer{Index: index, OnRun: func(run *IndexerRun) { runs <- run }}
+			ch := indexer.Start()
+			ch <- &Doc{Source: Source{"Raw": "line 1"}}
+			ch <- &Doc{Source: Source{"Raw": "line 2"}}
+			close(ch)
+			So((<-runs).Docs, ShouldEqual, 2)
+			So(indexer.Finish(), ShouldBeNil)
+		})
+
+		Convey("reports errors", func() {
+			s.FailBulkItems(func(action, index, id string) int { return 400 })
+			indexer := &Indexer{Index: index, BatchSize: 2}
+			ch := indexer.Start()
+			ch <- &Doc{Id: "1", Source: Source{"Raw": "line 1"}}
+			ch <- &Doc{Id: "2", Source: Source{"Raw": "line 2"}}
+			select {
+			case e := <-indexer.Errors():
+				_, ok := e.(*BulkError)
+				So(ok, ShouldBeTrue)
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for error")
+			}
+			So(indexer.Finish(), ShouldNotBeNil)
+			stats := indexer.Stats.Snapshot()
+			So(stats.FailedRuns, ShouldEqual, 1)
+			So(stats.FailedDocs, ShouldEqual, 2)
+			So(stats.IndexedDocs, ShouldEqual, 0)
+		})
 	})
 }
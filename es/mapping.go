@@ -1,16 +1,68 @@
 package es
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
 type IndexMappings map[string]*IndexMapping
 
 type IndexMapping struct {
-	Properties *IndexMappingProperties
+	Dynamic          interface{}             `json:"dynamic,omitempty"` // true, false or strict
+	DynamicTemplates DynamicTemplates        `json:"dynamic_templates,omitempty"`
+	Properties       *IndexMappingProperties `json:"properties,omitempty"`
 }
 
 type IndexMappingProperties map[string]IndexMappingProperty
 
+// IndexMappingProperty is the mapping of a single field. Object and nested fields define their fields in
+// Properties, multi-fields (e.g. a keyword field for a text field) are defined in Fields.
 type IndexMappingProperty struct {
-	Type   string `json:"type"`
-	Format string `json:"format,omitempty"`
+	Type           string                 `json:"type,omitempty"` // empty for objects
+	Format         string                 `json:"format,omitempty"`
+	Index          *bool                  `json:"index,omitempty"`
+	DocValues      *bool                  `json:"doc_values,omitempty"`
+	Store          bool                   `json:"store,omitempty"`
+	Enabled        *bool                  `json:"enabled,omitempty"` // only for objects
+	Analyzer       string                 `json:"analyzer,omitempty"`
+	SearchAnalyzer string                 `json:"search_analyzer,omitempty"`
+	Normalizer     string                 `json:"normalizer,omitempty"`
+	IgnoreAbove    int                    `json:"ignore_above,omitempty"`
+	NullValue      interface{}            `json:"null_value,omitempty"`
+	Dynamic        interface{}            `json:"dynamic,omitempty"`
+	Fields         IndexMappingProperties `json:"fields,omitempty"`
+	Properties     IndexMappingProperties `json:"properties,omitempty"`
+}
+
+const (
+	TypeText    = "text"
+	TypeKeyword = "keyword"
+	TypeLong    = "long"
+	TypeInteger = "integer"
+	TypeShort   = "short"
+	TypeByte    = "byte"
+	TypeDouble  = "double"
+	TypeFloat   = "float"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+	TypeIP      = "ip"
+	TypeObject  = "object"
+	TypeNested  = "nested"
+)
+
+// NewIndexMapping returns a mapping with the given properties.
+func NewIndexMapping(properties IndexMappingProperties) *IndexMapping {
+	return &IndexMapping{Properties: &properties}
+}
+
+// EffectiveType returns the type of the property, object for properties with fields but without type.
+func (property *IndexMappingProperty) EffectiveType() string {
+	if property.Type == "" {
+		return TypeObject
+	}
+	return property.Type
 }
 
 type Mapping map[string]IndexMappings
@@ -22,3 +74,51 @@ func (mapping Mapping) IndexNames() []string {
 	}
 	return names
 }
+
+// GetMapping returns the mapping of the index or nil when the index does not exist. Mappings of indices with
+// types (Elasticsearch < 7.0) are returned for the type of the index.
+func (index *Index) GetMapping(ctx context.Context) (*IndexMapping, error) {
+	rsp, e := index.requestWithContext(ctx, "GET", index.Path()+"/_mapping", nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return nil, nil
+	} else if e != nil {
+		return nil, e
+	}
+	indices := map[string]struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}{}
+	if e := json.Unmarshal(rsp.Body, &indices); e != nil {
+		return nil, e
+	}
+	names := []string{}
+	for name := range indices {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	raw := indices[names[0]].Mappings
+	if index.Type != "" {
+		typed := map[string]json.RawMessage{}
+		if e := json.Unmarshal(raw, &typed); e == nil {
+			if m, ok := typed[index.Type]; ok {
+				raw = m
+			}
+		}
+	}
+	mapping := &IndexMapping{}
+	if e := json.Unmarshal(raw, mapping); e != nil {
+		return nil, e
+	}
+	return mapping, nil
+}
+
+// UpdateMapping adds fields to the mapping of the index. Use Diff to check for incompatible changes first.
+func (index *Index) UpdateMapping(ctx context.Context, mapping *IndexMapping) error {
+	_, e := index.requestWithContext(ctx, "PUT", index.Path()+"/_mapping", mapping)
+	if e != nil {
+		return fmt.Errorf("Error updating mapping of %s: %w", index.Index, e)
+	}
+	return nil
+}
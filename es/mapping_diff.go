@@ -0,0 +1,109 @@
+package es
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MappingDiff lists the differences between a desired and a live mapping. Added fields can be applied with
+// UpdateMapping, incompatible changes require a new index and a reindex.
+type MappingDiff struct {
+	Added        []string // paths of fields missing in the live mapping
+	Incompatible []*MappingConflict
+}
+
+type MappingConflict struct {
+	Path   string // e.g. "request.uri.raw"
+	Reason string
+}
+
+func (conflict *MappingConflict) String() string {
+	return conflict.Path + ": " + conflict.Reason
+}
+
+// Compatible returns true when the desired mapping can be applied to the live index.
+func (diff *MappingDiff) Compatible() bool {
+	return len(diff.Incompatible) == 0
+}
+
+// Empty returns true when the live mapping already contains all fields of the desired mapping.
+func (diff *MappingDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Incompatible) == 0
+}
+
+func (diff *MappingDiff) String() string {
+	lines := []string{}
+	for _, path := range diff.Added {
+		lines = append(lines, "+ "+path)
+	}
+	for _, c := range diff.Incompatible {
+		lines = append(lines, "! "+c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff compares the mapping (desired) with live. Fields only defined in live are ignored as they can not be
+// removed from an index, updatable parameters (e.g. ignore_above or search_analyzer) are not compared.
+func (mapping *IndexMapping) Diff(live *IndexMapping) *MappingDiff {
+	diff := &MappingDiff{}
+	var desired, current IndexMappingProperties
+	if mapping != nil && mapping.Properties != nil {
+		desired = *mapping.Properties
+	}
+	if live != nil && live.Properties != nil {
+		current = *live.Properties
+	}
+	diffProperties(diff, "", desired, current)
+	return diff
+}
+
+func diffProperties(diff *MappingDiff, prefix string, desired, live IndexMappingProperties) {
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := prefix + name
+		d := desired[name]
+		l, ok := live[name]
+		if !ok {
+			diff.Added = append(diff.Added, path)
+			continue
+		}
+		diffProperty(diff, path, &d, &l)
+	}
+}
+
+func diffProperty(diff *MappingDiff, path string, desired, live *IndexMappingProperty) {
+	conflict := func(format string, i ...interface{}) {
+		diff.Incompatible = append(diff.Incompatible, &MappingConflict{Path: path, Reason: fmt.Sprintf(format, i...)})
+	}
+	if desired.EffectiveType() != live.EffectiveType() {
+		conflict("type %s can not be changed to %s", live.EffectiveType(), desired.EffectiveType())
+		return
+	}
+	compare := func(name, d, l string) {
+		if d != l {
+			conflict("%s %q can not be changed to %q", name, l, d)
+		}
+	}
+	compare("analyzer", desired.Analyzer, live.Analyzer)
+	compare("normalizer", desired.Normalizer, live.Normalizer)
+	compare("format", desired.Format, live.Format)
+	compare("index", boolString(desired.Index, true), boolString(live.Index, true))
+	compare("doc_values", boolString(desired.DocValues, true), boolString(live.DocValues, true))
+	if desired.Store != live.Store {
+		conflict("store %t can not be changed to %t", live.Store, desired.Store)
+	}
+	diffProperties(diff, path+".", desired.Fields, live.Fields)
+	diffProperties(diff, path+".", desired.Properties, live.Properties)
+}
+
+func boolString(b *bool, defaultValue bool) string {
+	if b == nil {
+		return fmt.Sprint(defaultValue)
+	}
+	return fmt.Sprint(*b)
+}
@@ -0,0 +1,208 @@
+package es
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	ipType   = reflect.TypeOf(net.IP{})
+)
+
+// MappingFor generates a mapping for the struct v. Field names are taken from json tags, types are derived
+// from the Go types (string fields are mapped as keyword) and can be changed with the es tag:
+//
+//	Message string    `json:"message" es:"text,analyzer=english,field=raw:keyword"`
+//	Host    string    `json:"host" es:"keyword,ignore_above=256,doc_values=false"`
+//	Spans   []*Span   `json:"spans" es:"nested"`
+//	Secret  string    `es:"-"`
+//
+// Supported options are index, doc_values, store, enabled, analyzer, search_analyzer, normalizer, format,
+// ignore_above and field=<name>:<type>[:<analyzer>] for multi-fields.
+func MappingFor(v interface{}) (*IndexMapping, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected struct, got %T", v)
+	}
+	properties, e := structProperties(t, map[reflect.Type]bool{})
+	if e != nil {
+		return nil, e
+	}
+	return NewIndexMapping(properties), nil
+}
+
+func structProperties(t reflect.Type, seen map[reflect.Type]bool) (IndexMappingProperties, error) {
+	if seen[t] {
+		return nil, fmt.Errorf("recursive type %s", t)
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	properties := IndexMappingProperties{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("es")
+		if tag == "-" {
+			continue
+		}
+		name := f.Name
+		if jsonTag := f.Tag.Get("json"); jsonTag != "" {
+			jsonName := strings.Split(jsonTag, ",")[0]
+			if jsonName == "-" {
+				continue
+			}
+			if jsonName != "" {
+				name = jsonName
+			}
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			embedded, e := structProperties(ft, seen)
+			if e != nil {
+				return nil, e
+			}
+			for k, v := range embedded {
+				if _, ok := properties[k]; !ok {
+					properties[k] = v
+				}
+			}
+			continue
+		}
+		property, e := fieldProperty(ft, tag, seen)
+		if e != nil {
+			return nil, fmt.Errorf("%s.%s: %s", t.Name(), f.Name, e)
+		}
+		properties[name] = *property
+	}
+	return properties, nil
+}
+
+func fieldProperty(t reflect.Type, tag string, seen map[reflect.Type]bool) (*IndexMappingProperty, error) {
+	options := strings.Split(tag, ",")
+	property := &IndexMappingProperty{Type: options[0]}
+	elem := t
+	for (elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array || elem.Kind() == reflect.Ptr) && elem != ipType && elem.Elem().Kind() != reflect.Uint8 {
+		elem = elem.Elem()
+	}
+	if property.Type == "" {
+		property.Type = goType(elem)
+	}
+	if property.Type == "" {
+		return nil, fmt.Errorf("no mapping type for %s", t)
+	}
+	if (property.Type == TypeObject || property.Type == TypeNested) && elem.Kind() == reflect.Struct {
+		properties, e := structProperties(elem, seen)
+		if e != nil {
+			return nil, e
+		}
+		property.Properties = properties
+		if property.Type == TypeObject {
+			property.Type = ""
+		}
+	}
+	for _, option := range options[1:] {
+		if e := property.setOption(option); e != nil {
+			return nil, e
+		}
+	}
+	return property, nil
+}
+
+func goType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return TypeDate
+	case t == ipType:
+		return TypeIP
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return TypeKeyword
+	case reflect.Bool:
+		return TypeBoolean
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return TypeLong
+	case reflect.Int32, reflect.Uint16:
+		return TypeInteger
+	case reflect.Int16, reflect.Uint8:
+		return TypeShort
+	case reflect.Int8:
+		return TypeByte
+	case reflect.Float64:
+		return TypeDouble
+	case reflect.Float32:
+		return TypeFloat
+	case reflect.Struct, reflect.Map:
+		return TypeObject
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "binary"
+		}
+	}
+	return ""
+}
+
+func (property *IndexMappingProperty) setOption(option string) error {
+	parts := strings.SplitN(option, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid option %q", option)
+	}
+	key, value := parts[0], parts[1]
+	parseBool := func() (*bool, error) {
+		b, e := strconv.ParseBool(value)
+		return &b, e
+	}
+	var e error
+	switch key {
+	case "index":
+		property.Index, e = parseBool()
+	case "doc_values":
+		property.DocValues, e = parseBool()
+	case "enabled":
+		property.Enabled, e = parseBool()
+	case "store":
+		property.Store, e = strconv.ParseBool(value)
+	case "analyzer":
+		property.Analyzer = value
+	case "search_analyzer":
+		property.SearchAnalyzer = value
+	case "normalizer":
+		property.Normalizer = value
+	case "format":
+		property.Format = value
+	case "ignore_above":
+		property.IgnoreAbove, e = strconv.Atoi(value)
+	case "field":
+		f := strings.Split(value, ":")
+		if len(f) < 2 || len(f) > 3 {
+			return fmt.Errorf("invalid multi-field %q, expected name:type[:analyzer]", value)
+		}
+		if property.Fields == nil {
+			property.Fields = IndexMappingProperties{}
+		}
+		sub := IndexMappingProperty{Type: f[1]}
+		if len(f) == 3 {
+			sub.Analyzer = f[2]
+		}
+		property.Fields[f[0]] = sub
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	if e != nil {
+		return fmt.Errorf("invalid value for %s: %s", key, e)
+	}
+	return nil
+}
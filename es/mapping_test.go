@@ -0,0 +1,159 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mappingSpan struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+}
+
+type mappingMeta struct {
+	Created time.Time `json:"created" es:"date,format=strict_date_optional_time||epoch_millis"`
+}
+
+type mappingRequest struct {
+	mappingMeta
+	Host    string            `json:"host" es:"keyword,ignore_above=256,normalizer=lowercase"`
+	Message string            `json:"message" es:"text,analyzer=english,field=raw:keyword"`
+	Status  int32             `json:"status"`
+	Bytes   int64             `json:"bytes,omitempty"`
+	Ratio   float32           `json:"ratio"`
+	Cached  bool              `json:"cached"`
+	Remote  net.IP            `json:"remote"`
+	Tags    []string          `json:"tags"`
+	Headers map[string]string `json:"headers" es:"object,enabled=false"`
+	User    *struct {
+		Name string `json:"name"`
+	} `json:"user"`
+	Spans    []*mappingSpan `json:"spans" es:"nested"`
+	Body     []byte         `json:"body" es:",index=false,doc_values=false"`
+	Ignored  string         `json:"-"`
+	Secret   string         `es:"-"`
+	internal string
+}
+
+func TestMapping(t *testing.T) {
+	Convey("Mapping", t, func() {
+		Convey("generated from a struct", func() {
+			m, e := MappingFor(&mappingRequest{})
+			So(e, ShouldBeNil)
+			assertGolden(t, "mapping_struct", m)
+		})
+
+		Convey("with invalid tags", func() {
+			_, e := MappingFor(struct {
+				Host string `es:"keyword,unknown=1"`
+			}{})
+			So(e, ShouldNotBeNil)
+			_, e = MappingFor(struct {
+				Host string `es:"keyword,field=raw"`
+			}{})
+			So(e, ShouldNotBeNil)
+			_, e = MappingFor("string")
+			So(e, ShouldNotBeNil)
+		})
+
+		Convey("diff", func() {
+			live, e := MappingFor(&mappingRequest{})
+			So(e, ShouldBeNil)
+			So(live.Diff(live).Empty(), ShouldBeTrue)
+
+			desired, _ := MappingFor(&struct {
+				mappingRequest
+				Host   string `json:"host" es:"text"`
+				Status string `json:"status"`
+				Agent  string `json:"agent"`
+				Spans  []*struct {
+					Name string `json:"name" es:"keyword,doc_values=false"`
+					Kind string `json:"kind"`
+				} `json:"spans" es:"nested"`
+				Message string `json:"message" es:"text,analyzer=english,field=raw:keyword,field=de:text:german"`
+			}{})
+			diff := desired.Diff(live)
+			So(diff.Compatible(), ShouldBeFalse)
+			So(diff.Added, ShouldResemble, []string{"agent", "message.de", "spans.kind"})
+			So(diff.String(), ShouldEqual, `+ agent
++ message.de
++ spans.kind
+! host: type keyword can not be changed to text
+! spans.name: doc_values "true" can not be changed to "false"
+! status: type integer can not be changed to keyword`)
+
+			So(desired.Diff(nil).Added, ShouldHaveLength, 14)
+		})
+
+		Convey("of a live index", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			ctx := context.Background()
+			client := NewClient(s.URL)
+			_, e := client.Do(ctx, "PUT", "/logs-2026.10.16", []byte(`{"aliases":{"logs":{}},"mappings":{"properties":{"host":{"type":"keyword"},"user":{"properties":{"name":{"type":"text","fields":{"raw":{"type":"keyword"}}}}}}}}`))
+			So(e, ShouldBeNil)
+			_, e = client.Do(ctx, "PUT", "/legacy", []byte(`{"mappings":{"line":{"properties":{"host":{"type":"string","index":"not_analyzed"}}}}}`))
+			So(e, ShouldBeNil)
+			index := &Index{Client: client, Index: "logs"}
+			m, e := index.GetMapping(ctx)
+			So(e, ShouldBeNil)
+			So((*m.Properties)["host"].Type, ShouldEqual, TypeKeyword)
+			user := (*m.Properties)["user"]
+			So(user.EffectiveType(), ShouldEqual, TypeObject)
+			So(user.Properties["name"].Fields["raw"].Type, ShouldEqual, TypeKeyword)
+
+			So(index.UpdateMapping(ctx, NewIndexMapping(IndexMappingProperties{"agent": {Type: TypeKeyword}})), ShouldBeNil)
+			req, body := last(s)
+			So(req, ShouldEqual, "PUT /logs/_mapping")
+			So(body, ShouldEqual, `{"properties":{"agent":{"type":"keyword"}}}`)
+			m, e = index.GetMapping(ctx)
+			So(e, ShouldBeNil)
+			So((*m.Properties)["agent"].Type, ShouldEqual, TypeKeyword)
+
+			_, e = (&Index{Client: NewClient(s.URL), Index: "legacy", Type: "line"}).GetMapping(ctx)
+			So(e, ShouldNotBeNil) // index is a string in old mappings
+
+			m, e = (&Index{Client: NewClient(s.URL), Index: "missing"}).GetMapping(ctx)
+			So(e, ShouldBeNil)
+			So(m, ShouldBeNil)
+		})
+	})
+}
+
+func TestIndexConfig(t *testing.T) {
+	Convey("IndexConfig", t, func() {
+		b, e := json.Marshal(KeywordIndex())
+		So(e, ShouldBeNil)
+		So(string(b), ShouldEqual, `{"settings":{"index":{"analysis":{"analyzer":{"default":{"tokenizer":"whitespace"}}}}}}`)
+
+		m := NewIndexMapping(IndexMappingProperties{"host": {Type: TypeKeyword, Normalizer: "lowercase"}})
+		config := KeywordIndex().
+			WithShards(1).
+			WithReplicas(0).
+			WithRefreshInterval("30s").
+			WithCodec("best_compression").
+			WithAnalyzer("path", AnalyzerType{Type: "custom", Tokenizer: "path_hierarchy", Filter: []string{"lowercase"}}).
+			WithNormalizer("lowercase", "lowercase", "asciifolding").
+			WithMapping(m)
+		assertGolden(t, "index_config", config)
+
+		Convey("does not modify the original", func() {
+			base := KeywordIndex()
+			base.WithAnalyzer("default", AnalyzerType{Type: "standard"})
+			So(base.Index.Analysis.Analyzer.Default.Tokenizer, ShouldEqual, "whitespace")
+		})
+
+		Convey("decodes analyzers", func() {
+			a := &Analysis{}
+			So(json.Unmarshal([]byte(`{"analyzer":{"default":{"type":"standard"},"path":{"tokenizer":"path_hierarchy"}}}`), a), ShouldBeNil)
+			So(a.Analyzer.Default.Type, ShouldEqual, "standard")
+			So(a.Analyzer.Custom["path"].Tokenizer, ShouldEqual, "path_hierarchy")
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package es
+
+import (
+	"time"
+)
+
+// Query defines a search query. Exactly one of the fields must be set, queries can be combined with
+// BoolQuery and NestedQuery.
+type Query struct {
+	Filtered    *Filtered                 `json:"filtered,omitempty"` // Deprecated: use Bool
+	QueryString *QueryString              `json:"query_string,omitempty"`
+	MatchAll    *MatchAllQuery            `json:"match_all,omitempty"`
+	Bool        *BoolQuery                `json:"bool,omitempty"`
+	Match       map[string]*MatchQuery    `json:"match,omitempty"`
+	MultiMatch  *MultiMatchQuery          `json:"multi_match,omitempty"`
+	Term        map[string]*TermQuery     `json:"term,omitempty"`
+	Terms       map[string][]interface{}  `json:"terms,omitempty"`
+	Range       map[string]*RangeQuery    `json:"range,omitempty"`
+	Exists      *ExistsQuery              `json:"exists,omitempty"`
+	Prefix      map[string]*PrefixQuery   `json:"prefix,omitempty"`
+	Wildcard    map[string]*WildcardQuery `json:"wildcard,omitempty"`
+	Nested      *NestedQuery              `json:"nested,omitempty"`
+}
+
+type QueryString struct {
+	Query           string   `json:"query,omitempty"`
+	DefaultOperator string   `json:"default_operator,omitempty"`
+	DefaultField    string   `json:"default_field,omitempty"`
+	Fields          []string `json:"fields,omitempty"`
+}
+
+type MatchAllQuery struct {
+	Boost float64 `json:"boost,omitempty"`
+}
+
+// BoolQuery combines queries. Queries in Filter and MustNot are executed in filter context (no scoring).
+type BoolQuery struct {
+	Must               []*Query    `json:"must,omitempty"`
+	Should             []*Query    `json:"should,omitempty"`
+	Filter             []*Query    `json:"filter,omitempty"`
+	MustNot            []*Query    `json:"must_not,omitempty"`
+	MinimumShouldMatch interface{} `json:"minimum_should_match,omitempty"`
+	Boost              float64     `json:"boost,omitempty"`
+}
+
+type MatchQuery struct {
+	Query              interface{} `json:"query"`
+	Operator           string      `json:"operator,omitempty"` // AND or OR
+	Fuzziness          string      `json:"fuzziness,omitempty"`
+	Analyzer           string      `json:"analyzer,omitempty"`
+	MinimumShouldMatch interface{} `json:"minimum_should_match,omitempty"`
+	Boost              float64     `json:"boost,omitempty"`
+}
+
+type MultiMatchQuery struct {
+	Query     string   `json:"query"`
+	Fields    []string `json:"fields,omitempty"`
+	Type      string   `json:"type,omitempty"` // e.g. best_fields, most_fields, phrase
+	Operator  string   `json:"operator,omitempty"`
+	Fuzziness string   `json:"fuzziness,omitempty"`
+	Boost     float64  `json:"boost,omitempty"`
+}
+
+type TermQuery struct {
+	Value interface{} `json:"value"`
+	Boost float64     `json:"boost,omitempty"`
+}
+
+// RangeQuery defines the bounds of a range query. time.Time bounds are encoded as RFC 3339.
+type RangeQuery struct {
+	Gt       interface{} `json:"gt,omitempty"`
+	Gte      interface{} `json:"gte,omitempty"`
+	Lt       interface{} `json:"lt,omitempty"`
+	Lte      interface{} `json:"lte,omitempty"`
+	Format   string      `json:"format,omitempty"`
+	TimeZone string      `json:"time_zone,omitempty"`
+	Boost    float64     `json:"boost,omitempty"`
+}
+
+type ExistsQuery struct {
+	Field string `json:"field"`
+}
+
+type PrefixQuery struct {
+	Value           string `json:"value"`
+	CaseInsensitive bool   `json:"case_insensitive,omitempty"`
+}
+
+type WildcardQuery struct {
+	Value           string  `json:"value"`
+	CaseInsensitive bool    `json:"case_insensitive,omitempty"`
+	Boost           float64 `json:"boost,omitempty"`
+}
+
+type NestedQuery struct {
+	Path      string `json:"path"`
+	Query     *Query `json:"query"`
+	ScoreMode string `json:"score_mode,omitempty"` // avg, max, min, none or sum
+}
+
+func NewMatchAllQuery() *Query {
+	return &Query{MatchAll: &MatchAllQuery{}}
+}
+
+func NewQueryStringQuery(query string) *Query {
+	return &Query{QueryString: &QueryString{Query: query}}
+}
+
+func NewBoolQuery(b *BoolQuery) *Query {
+	return &Query{Bool: b}
+}
+
+func NewMatchQuery(field string, query interface{}) *Query {
+	return &Query{Match: map[string]*MatchQuery{field: {Query: query}}}
+}
+
+func NewMultiMatchQuery(query string, fields ...string) *Query {
+	return &Query{MultiMatch: &MultiMatchQuery{Query: query, Fields: fields}}
+}
+
+func NewTermQuery(field string, value interface{}) *Query {
+	return &Query{Term: map[string]*TermQuery{field: {Value: value}}}
+}
+
+func NewTermsQuery(field string, values ...interface{}) *Query {
+	return &Query{Terms: map[string][]interface{}{field: values}}
+}
+
+func NewRangeQuery(field string, r *RangeQuery) *Query {
+	return &Query{Range: map[string]*RangeQuery{field: r}}
+}
+
+// NewTimeRangeQuery returns a query for from <= field < to. Zero times are left open.
+func NewTimeRangeQuery(field string, from, to time.Time) *Query {
+	r := &RangeQuery{}
+	if !from.IsZero() {
+		r.Gte = from
+	}
+	if !to.IsZero() {
+		r.Lt = to
+	}
+	return NewRangeQuery(field, r)
+}
+
+func NewExistsQuery(field string) *Query {
+	return &Query{Exists: &ExistsQuery{Field: field}}
+}
+
+func NewPrefixQuery(field, prefix string) *Query {
+	return &Query{Prefix: map[string]*PrefixQuery{field: {Value: prefix}}}
+}
+
+func NewWildcardQuery(field, pattern string) *Query {
+	return &Query{Wildcard: map[string]*WildcardQuery{field: {Value: pattern}}}
+}
+
+func NewNestedQuery(path string, query *Query) *Query {
+	return &Query{Nested: &NestedQuery{Path: path, Query: query}}
+}
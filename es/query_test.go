@@ -0,0 +1,70 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func assertGolden(t *testing.T, name string, i interface{}) {
+	b, e := json.MarshalIndent(i, "", "  ")
+	So(e, ShouldBeNil)
+	b = append(b, '\n')
+	path := filepath.Join("testdata", name+".json")
+	if *updateGolden {
+		So(ioutil.WriteFile(path, b, 0644), ShouldBeNil)
+	}
+	expected, e := ioutil.ReadFile(path)
+	So(e, ShouldBeNil)
+	So(string(b), ShouldEqual, string(bytes.TrimRight(expected, "\n"))+"\n")
+}
+
+func TestQueries(t *testing.T) {
+	from := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	queries := map[string]*Query{
+		"query_match_all":    NewMatchAllQuery(),
+		"query_query_string": NewQueryStringQuery("Tag:nginx AND Status:500"),
+		"query_match":        NewMatchQuery("Raw", "connection refused"),
+		"query_multi_match":  NewMultiMatchQuery("timeout", "Raw", "Uri"),
+		"query_terms":        NewTermsQuery("Host", "he-host1", "he-host2"),
+		"query_exists":       NewExistsQuery("UserAgentName"),
+		"query_prefix":       NewPrefixQuery("Uri", "/api/"),
+		"query_wildcard":     NewWildcardQuery("Host", "he-*"),
+		"query_range":        NewRangeQuery("TotalTime", &RangeQuery{Gt: 0.5, Lte: 10}),
+		"query_time_range":   NewTimeRangeQuery("Time", from, from.Add(24*time.Hour)),
+		"query_nested": NewNestedQuery("requests", NewBoolQuery(&BoolQuery{
+			Must: []*Query{NewTermQuery("requests.method", "GET")},
+		})),
+		"query_bool": NewBoolQuery(&BoolQuery{
+			Must:    []*Query{NewMatchQuery("Raw", "error")},
+			Should:  []*Query{NewTermQuery("Tag", "nginx"), NewTermQuery("Tag", "haproxy")},
+			Filter:  []*Query{NewTimeRangeQuery("Time", from, time.Time{})},
+			MustNot: []*Query{NewTermQuery("Status", "200")},
+
+			MinimumShouldMatch: 1,
+		}),
+		"query_filtered": {
+			Filtered: &Filtered{
+				Filter: &Filter{
+					And: []*Filter{
+						{Term: &Term{"Device": "Android"}},
+						{Terms: &Terms{"Action": []interface{}{"api/v1/photos#create"}}},
+					},
+				},
+			},
+		},
+	}
+	Convey("Queries", t, func() {
+		for name, q := range queries {
+			assertGolden(t, name, &Request{Query: q, Size: 10})
+		}
+	})
+}
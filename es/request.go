@@ -1,13 +1,57 @@
 package es
 
 type Request struct {
-	Index  string `json:"-"`
-	Query  *Query `json:"query,omitempty"`
-	Size   int    `json:"size"`
-	Facets `json:"facets,omitempty"`
-	*Sort  `json:"sort,omitempty"`
+	Index string `json:"-"`
+	Query *Query `json:"query,omitempty"`
+	Size  int    `json:"size"`
+	// Deprecated: facets were removed in Elasticsearch 2.0, use Aggregations
+	Facets       `json:"facets,omitempty"`
+	*Sort        `json:"sort,omitempty"`
+	Aggregations Aggregations  `json:"aggs,omitempty"`
+	SearchAfter  []interface{} `json:"search_after,omitempty"`
+	Pit          *PointInTime  `json:"pit,omitempty"`
+
+	Version          bool          `json:"version,omitempty"`             // return _version of hits
+	SeqNoPrimaryTerm bool          `json:"seq_no_primary_term,omitempty"` // return _seq_no and _primary_term of hits
+	Fields           []interface{} `json:"fields,omitempty"`              // field names or {"field": ..., "format": ...}
+	Highlight        *Highlight    `json:"highlight,omitempty"`
+}
+
+type Highlight struct {
+	Fields            map[string]*HighlightField `json:"fields"`
+	PreTags           []string                   `json:"pre_tags,omitempty"`
+	PostTags          []string                   `json:"post_tags,omitempty"`
+	FragmentSize      int                        `json:"fragment_size,omitempty"`
+	NumberOfFragments int                        `json:"number_of_fragments,omitempty"`
+}
+
+type HighlightField struct {
+	FragmentSize      int `json:"fragment_size,omitempty"`
+	NumberOfFragments int `json:"number_of_fragments,omitempty"`
+}
+
+// NewHighlight returns a Highlight for the given fields with default options.
+func NewHighlight(fields ...string) *Highlight {
+	h := &Highlight{Fields: map[string]*HighlightField{}}
+	for _, f := range fields {
+		h.Fields[f] = &HighlightField{}
+	}
+	return h
+}
+
+type PointInTime struct {
+	Id        string `json:"id"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+func (request *Request) AddAggregation(name string, agg *Aggregation) {
+	if request.Aggregations == nil {
+		request.Aggregations = Aggregations{}
+	}
+	request.Aggregations[name] = agg
 }
 
+// Deprecated: use AddAggregation
 func (request *Request) AddFacet(key string, facet *Facet) {
 	if request.Facets == nil {
 		request.Facets = Facets{}
@@ -1,10 +1,46 @@
 package es
 
+import (
+	"fmt"
+	"reflect"
+)
+
 type Response struct {
-	Took     int            `json:"took"`
-	TimedOut bool           `json:"timed_out"`
-	Facets   ResponseFacets `json:"facets"`
-	Hits     Hits           `json:"hits"`
+	Took         int                `json:"took"`
+	TimedOut     bool               `json:"timed_out"`
+	Facets       ResponseFacets     `json:"facets"`
+	Hits         Hits               `json:"hits"`
+	Aggregations AggregationResults `json:"aggregations,omitempty"`
+	ScrollId     string             `json:"_scroll_id,omitempty"`
+	PitId        string             `json:"pit_id,omitempty"`
+}
+
+// Decode decodes the _source of all hits into v which must be a pointer to a slice (e.g. *[]Line or
+// *[]*Line). Decoded hits are appended to the slice.
+func (rsp *Response) Decode(v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("expected pointer to slice, got %T", v)
+	}
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	for _, hit := range rsp.Hits.Hits {
+		elem := reflect.New(elemType)
+		if e := hit.Decode(elem.Interface()); e != nil {
+			return fmt.Errorf("Error decoding hit %s: %s", hit.Id, e)
+		}
+		if isPtr {
+			slice = reflect.Append(slice, elem)
+		} else {
+			slice = reflect.Append(slice, elem.Elem())
+		}
+	}
+	ptr.Elem().Set(slice)
+	return nil
 }
 
 type ResponseFacets map[string]*ResponseFacet
@@ -0,0 +1,195 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	DefaultScanKeepAlive = 1 * time.Minute
+	DefaultScanSize      = 100
+)
+
+// Cursor iterates over all hits of a search request, fetching Request.Size hits per page. It uses the scroll
+// API or search_after with a point in time (Elasticsearch >= 7.10). The scroll context or point in time is
+// released when the last hit was returned or when Close is called.
+type Cursor struct {
+	KeepAlive time.Duration // defaults to DefaultScanKeepAlive
+	Total     int           // total number of hits, set after the first page was fetched
+
+	index        *Index
+	req          Request
+	pointInTime  bool
+	hits         []*Hit
+	scrollId     string
+	pitId        string
+	searchAfter  []interface{}
+	started      bool
+	done         bool
+	lastPageSeen bool
+}
+
+// Scan returns a Cursor using the scroll API.
+func (index *Index) Scan(req *Request) *Cursor {
+	return index.newCursor(req, false)
+}
+
+// ScanWithPointInTime returns a Cursor using search_after and a point in time. Sorting by a unique field
+// is not necessary, Elasticsearch adds the _shard_doc tiebreaker.
+func (index *Index) ScanWithPointInTime(req *Request) *Cursor {
+	return index.newCursor(req, true)
+}
+
+func (index *Index) newCursor(req *Request, pointInTime bool) *Cursor {
+	c := &Cursor{index: index, pointInTime: pointInTime}
+	if req != nil {
+		c.req = *req
+	}
+	if c.req.Size == 0 {
+		c.req.Size = DefaultScanSize
+	}
+	return c
+}
+
+func (c *Cursor) keepAlive() string {
+	d := c.KeepAlive
+	if d == 0 {
+		d = DefaultScanKeepAlive
+	}
+	if d < time.Second {
+		d = time.Second
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+// Next returns the next hit or io.EOF when all hits were returned.
+func (c *Cursor) Next(ctx context.Context) (*Hit, error) {
+	if len(c.hits) == 0 {
+		if c.done {
+			return nil, io.EOF
+		}
+		if e := c.fetch(ctx); e != nil {
+			return nil, e
+		}
+		if len(c.hits) == 0 {
+			c.done = true
+			if e := c.Close(ctx); e != nil {
+				return nil, e
+			}
+			return nil, io.EOF
+		}
+	}
+	hit := c.hits[0]
+	c.hits = c.hits[1:]
+	if len(c.hits) == 0 && c.lastPageSeen {
+		c.done = true
+		if e := c.Close(ctx); e != nil {
+			return hit, e
+		}
+	}
+	return hit, nil
+}
+
+func (c *Cursor) fetch(ctx context.Context) error {
+	var rsp *Response
+	var e error
+	if c.pointInTime {
+		rsp, e = c.fetchPointInTime(ctx)
+	} else {
+		rsp, e = c.fetchScroll(ctx)
+	}
+	if e != nil {
+		return e
+	}
+	c.started = true
+	c.Total = rsp.Hits.Total
+	c.hits = rsp.Hits.Hits
+	if len(c.hits) < c.req.Size {
+		c.lastPageSeen = true
+	}
+	if len(c.hits) > 0 {
+		c.searchAfter = c.hits[len(c.hits)-1].Sort
+	}
+	return nil
+}
+
+func (c *Cursor) fetchScroll(ctx context.Context) (*Response, error) {
+	var u string
+	var body interface{}
+	if !c.started {
+		u = c.index.searchPath() + "?scroll=" + c.keepAlive()
+		body = &c.req
+	} else {
+		u = "/_search/scroll"
+		body = map[string]string{"scroll": c.keepAlive(), "scroll_id": c.scrollId}
+	}
+	rsp, e := c.search(ctx, u, body)
+	if e != nil {
+		return nil, e
+	}
+	if rsp.ScrollId != "" {
+		c.scrollId = rsp.ScrollId
+	}
+	return rsp, nil
+}
+
+func (c *Cursor) fetchPointInTime(ctx context.Context) (*Response, error) {
+	if c.pitId == "" {
+		if c.started {
+			return nil, fmt.Errorf("point in time already closed")
+		}
+		httpResponse, e := c.index.requestWithContext(ctx, "POST", c.index.Path()+"/_pit?keep_alive="+c.keepAlive(), nil)
+		if e != nil {
+			return nil, e
+		}
+		var pit struct {
+			Id string `json:"id"`
+		}
+		if e := json.Unmarshal(httpResponse.Body, &pit); e != nil {
+			return nil, e
+		}
+		c.pitId = pit.Id
+	}
+	req := c.req
+	req.Index = ""
+	req.Pit = &PointInTime{Id: c.pitId, KeepAlive: c.keepAlive()}
+	req.SearchAfter = c.searchAfter
+	rsp, e := c.search(ctx, "/_search", &req)
+	if e != nil {
+		return nil, e
+	}
+	if rsp.PitId != "" {
+		c.pitId = rsp.PitId
+	}
+	return rsp, nil
+}
+
+func (c *Cursor) search(ctx context.Context, u string, body interface{}) (*Response, error) {
+	httpResponse, e := c.index.requestWithContext(ctx, "POST", u, body)
+	if e != nil {
+		return nil, e
+	}
+	rsp := &Response{}
+	if e := json.Unmarshal(httpResponse.Body, rsp); e != nil {
+		return nil, e
+	}
+	return rsp, nil
+}
+
+// Close releases the scroll context or point in time. It is called automatically after the last hit.
+func (c *Cursor) Close(ctx context.Context) (e error) {
+	c.done = true
+	c.hits = nil
+	if c.scrollId != "" {
+		_, e = c.index.requestWithContext(ctx, "DELETE", "/_search/scroll", map[string][]string{"scroll_id": {c.scrollId}})
+		c.scrollId = ""
+	}
+	if c.pitId != "" {
+		_, e = c.index.requestWithContext(ctx, "DELETE", "/_pit", map[string]string{"id": c.pitId})
+		c.pitId = ""
+	}
+	return e
+}
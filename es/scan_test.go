@@ -0,0 +1,105 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// respond answers the requests with the testdata files in order.
+func respond(s *estest.Server, method, path string, files ...string) {
+	for _, f := range files {
+		b, e := ioutil.ReadFile(filepath.Join("testdata", f))
+		if e != nil {
+			panic(e)
+		}
+		s.Respond(method, path, 200, string(b))
+	}
+}
+
+func decodedBodies(s *estest.Server) []map[string]interface{} {
+	bodies := []map[string]interface{}{}
+	for _, b := range s.Bodies() {
+		body := map[string]interface{}{}
+		json.Unmarshal([]byte(b), &body)
+		bodies = append(bodies, body)
+	}
+	return bodies
+}
+
+func collectIds(c *Cursor) ([]string, error) {
+	ids := []string{}
+	for {
+		hit, e := c.Next(context.Background())
+		if e == io.EOF {
+			return ids, nil
+		} else if e != nil {
+			return ids, e
+		}
+		ids = append(ids, hit.Id)
+	}
+}
+
+func TestScan(t *testing.T) {
+	Convey("Scan", t, func() {
+		Convey("with scroll", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			respond(s, "POST", "/logs/_search", "scroll_page1.json")
+			respond(s, "POST", "/_search/scroll", "scroll_page2.json", "scroll_page3.json")
+			respond(s, "DELETE", "/_search/scroll", "scroll_clear.json")
+			c := (&Index{Host: s.Host(), Port: s.Port(), Index: "logs"}).Scan(&Request{Size: 2, Query: NewTermQuery("Tag", "nginx")})
+			ids, e := collectIds(c)
+			So(e, ShouldBeNil)
+			So(ids, ShouldResemble, []string{"1", "2", "3", "4"})
+			So(c.Total, ShouldEqual, 4)
+			So(s.Requests(), ShouldResemble, []string{
+				"POST /logs/_search?scroll=60s", "POST /_search/scroll", "POST /_search/scroll", "DELETE /_search/scroll",
+			})
+			bodies := decodedBodies(s)
+			So(bodies[0]["size"], ShouldEqual, 2)
+			So(bodies[1]["scroll_id"], ShouldEqual, "c2Nyb2xsLTE=")
+			So(bodies[3]["scroll_id"], ShouldResemble, []interface{}{"c2Nyb2xsLTI="})
+			_, e = c.Next(context.Background())
+			So(e, ShouldEqual, io.EOF)
+		})
+
+		Convey("with point in time", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			respond(s, "POST", "/logs/_pit", "pit_open.json")
+			respond(s, "POST", "/_search", "pit_page1.json", "pit_page2.json")
+			respond(s, "DELETE", "/_pit", "pit_close.json")
+			c := (&Index{Host: s.Host(), Port: s.Port(), Index: "logs"}).ScanWithPointInTime(&Request{Size: 2})
+			ids, e := collectIds(c)
+			So(e, ShouldBeNil)
+			So(ids, ShouldResemble, []string{"1", "2", "3"})
+			So(s.Requests(), ShouldResemble, []string{"POST /logs/_pit?keep_alive=60s", "POST /_search", "POST /_search", "DELETE /_pit"})
+			bodies := decodedBodies(s)
+			So(bodies[1]["pit"], ShouldResemble, map[string]interface{}{"id": "cGl0LTE=", "keep_alive": "60s"})
+			So(bodies[1]["search_after"], ShouldBeNil)
+			So(bodies[2]["pit"].(map[string]interface{})["id"], ShouldEqual, "cGl0LTI=")
+			So(bodies[2]["search_after"], ShouldResemble, []interface{}{1760572801000.0, 1.0})
+			So(bodies[3]["id"], ShouldEqual, "cGl0LTI=")
+		})
+
+		Convey("Close before the end", func() {
+			s := estest.NewServer()
+			defer s.Close()
+			respond(s, "POST", "/logs/_search", "scroll_page1.json")
+			respond(s, "DELETE", "/_search/scroll", "scroll_clear.json")
+			c := (&Index{Host: s.Host(), Port: s.Port(), Index: "logs"}).Scan(&Request{Size: 2})
+			hit, e := c.Next(context.Background())
+			So(e, ShouldBeNil)
+			So(hit.Source["Host"], ShouldEqual, "he-host1")
+			So(c.Close(context.Background()), ShouldBeNil)
+			So(s.Requests(), ShouldResemble, []string{"POST /logs/_search?scroll=60s", "DELETE /_search/scroll"})
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package es
+
+import (
+	"encoding/json"
+)
+
+// IndexConfig holds the settings and mappings used to create an index. Use the With methods to build it,
+// e.g. KeywordIndex().WithShards(1).WithReplicas(0).WithMapping(m).
+type IndexConfig struct {
+	Index    IndexIndexConfig `json:"index"`
+	Mappings *IndexMapping    `json:"-"`
+}
+
+// MarshalJSON encodes the config as body of a create index request.
+func (config IndexConfig) MarshalJSON() ([]byte, error) {
+	body := struct {
+		Settings struct {
+			Index IndexIndexConfig `json:"index"`
+		} `json:"settings"`
+		Mappings *IndexMapping `json:"mappings,omitempty"`
+	}{Mappings: config.Mappings}
+	body.Settings.Index = config.Index
+	return json.Marshal(body)
+}
+
+type IndexIndexConfig struct {
+	NumberOfShards   int       `json:"number_of_shards,omitempty"`
+	NumberOfReplicas *int      `json:"number_of_replicas,omitempty"`
+	RefreshInterval  string    `json:"refresh_interval,omitempty"` // e.g. 30s or -1
+	MaxResultWindow  int       `json:"max_result_window,omitempty"`
+	Codec            string    `json:"codec,omitempty"` // e.g. best_compression
+	Analysis         *Analysis `json:"analysis,omitempty"`
+}
+
+type Analysis struct {
+	Analyzer   Analyzer                     `json:"analyzer"`
+	Normalizer map[string]*Normalizer       `json:"normalizer,omitempty"`
+	Tokenizer  map[string]map[string]string `json:"tokenizer,omitempty"`
+	Filter     map[string]interface{}       `json:"filter,omitempty"`
+	CharFilter map[string]interface{}       `json:"char_filter,omitempty"`
+}
+
+// Analyzer holds the default analyzer and custom analyzers by name.
+type Analyzer struct {
+	Default AnalyzerType            `json:"default"`
+	Custom  map[string]AnalyzerType `json:"-"`
+}
+
+func (analyzer Analyzer) MarshalJSON() ([]byte, error) {
+	m := map[string]AnalyzerType{}
+	if !analyzer.Default.isZero() {
+		m["default"] = analyzer.Default
+	}
+	for name, a := range analyzer.Custom {
+		m[name] = a
+	}
+	return json.Marshal(m)
+}
+
+func (analyzer *Analyzer) UnmarshalJSON(b []byte) error {
+	m := map[string]AnalyzerType{}
+	if e := json.Unmarshal(b, &m); e != nil {
+		return e
+	}
+	analyzer.Default = m["default"]
+	delete(m, "default")
+	analyzer.Custom = nil
+	if len(m) > 0 {
+		analyzer.Custom = m
+	}
+	return nil
+}
+
+type AnalyzerType struct {
+	Type       string   `json:"type,omitempty"` // e.g. custom, standard or english
+	Tokenizer  string   `json:"tokenizer,omitempty"`
+	Filter     []string `json:"filter,omitempty"`
+	CharFilter []string `json:"char_filter,omitempty"`
+	Stopwords  string   `json:"stopwords,omitempty"`
+}
+
+func (a AnalyzerType) isZero() bool {
+	return a.Type == "" && a.Tokenizer == "" && len(a.Filter) == 0 && len(a.CharFilter) == 0 && a.Stopwords == ""
+}
+
+type Normalizer struct {
+	Type       string   `json:"type,omitempty"` // custom
+	Filter     []string `json:"filter,omitempty"`
+	CharFilter []string `json:"char_filter,omitempty"`
+}
+
+// KeywordIndex returns a config using the whitespace tokenizer for all fields.
+func KeywordIndex() IndexConfig {
+	return IndexConfig{
+		Index: IndexIndexConfig{
+			Analysis: &Analysis{
+				Analyzer: Analyzer{
+					Default: AnalyzerType{
+						Tokenizer: "whitespace",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (config IndexConfig) analysis() *Analysis {
+	if config.Index.Analysis == nil {
+		return &Analysis{}
+	}
+	a := *config.Index.Analysis
+	return &a
+}
+
+func (config IndexConfig) WithShards(shards int) IndexConfig {
+	config.Index.NumberOfShards = shards
+	return config
+}
+
+func (config IndexConfig) WithReplicas(replicas int) IndexConfig {
+	config.Index.NumberOfReplicas = &replicas
+	return config
+}
+
+func (config IndexConfig) WithRefreshInterval(interval string) IndexConfig {
+	config.Index.RefreshInterval = interval
+	return config
+}
+
+func (config IndexConfig) WithCodec(codec string) IndexConfig {
+	config.Index.Codec = codec
+	return config
+}
+
+// WithAnalyzer adds a custom analyzer, the name "default" replaces the default analyzer.
+func (config IndexConfig) WithAnalyzer(name string, analyzer AnalyzerType) IndexConfig {
+	a := config.analysis()
+	if name == "default" {
+		a.Analyzer.Default = analyzer
+	} else {
+		custom := map[string]AnalyzerType{name: analyzer}
+		for k, v := range a.Analyzer.Custom {
+			if k != name {
+				custom[k] = v
+			}
+		}
+		a.Analyzer.Custom = custom
+	}
+	config.Index.Analysis = a
+	return config
+}
+
+// WithNormalizer adds a custom normalizer for keyword fields, e.g. with the lowercase filter.
+func (config IndexConfig) WithNormalizer(name string, filters ...string) IndexConfig {
+	a := config.analysis()
+	normalizers := map[string]*Normalizer{name: {Type: "custom", Filter: filters}}
+	for k, v := range a.Normalizer {
+		if k != name {
+			normalizers[k] = v
+		}
+	}
+	a.Normalizer = normalizers
+	config.Index.Analysis = a
+	return config
+}
+
+func (config IndexConfig) WithMapping(mapping *IndexMapping) IndexConfig {
+	config.Mappings = mapping
+	return config
+}
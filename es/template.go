@@ -0,0 +1,68 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// IndexTemplate is a composable index template (Elasticsearch >= 7.8) applied to new indices matching
+// IndexPatterns.
+type IndexTemplate struct {
+	IndexPatterns []string               `json:"index_patterns"`
+	Priority      int                    `json:"priority,omitempty"`
+	Version       int                    `json:"version,omitempty"`
+	ComposedOf    []string               `json:"composed_of,omitempty"`
+	Template      *IndexTemplateBody     `json:"template,omitempty"`
+	Meta          map[string]interface{} `json:"_meta,omitempty"`
+}
+
+type IndexTemplateBody struct {
+	Settings interface{}              `json:"settings,omitempty"`
+	Mappings interface{}              `json:"mappings,omitempty"`
+	Aliases  map[string]*AliasOptions `json:"aliases,omitempty"`
+}
+
+func templatePath(name string) string {
+	return "/_index_template/" + url.PathEscape(name)
+}
+
+func (client *Client) PutIndexTemplate(ctx context.Context, name string, template *IndexTemplate) error {
+	if _, e := client.Do(ctx, "PUT", templatePath(name), template); e != nil {
+		return fmt.Errorf("Error putting index template %s: %w", name, e)
+	}
+	return nil
+}
+
+// IndexTemplate returns the template with the given name or nil when it does not exist.
+func (client *Client) IndexTemplate(ctx context.Context, name string) (*IndexTemplate, error) {
+	rsp, e := client.Do(ctx, "GET", templatePath(name), nil)
+	if rsp != nil && rsp.StatusCode == 404 {
+		return nil, nil
+	} else if e != nil {
+		return nil, e
+	}
+	var templates struct {
+		IndexTemplates []struct {
+			Name          string         `json:"name"`
+			IndexTemplate *IndexTemplate `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if e := json.Unmarshal(rsp.Body, &templates); e != nil {
+		return nil, e
+	}
+	for _, t := range templates.IndexTemplates {
+		if t.Name == name {
+			return t.IndexTemplate, nil
+		}
+	}
+	return nil, nil
+}
+
+func (client *Client) DeleteIndexTemplate(ctx context.Context, name string) error {
+	if _, e := client.Do(ctx, "DELETE", templatePath(name), nil); e != nil {
+		return fmt.Errorf("Error deleting index template %s: %w", name, e)
+	}
+	return nil
+}
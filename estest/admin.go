@@ -0,0 +1,307 @@
+package estest
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type template struct {
+	raw      json.RawMessage
+	patterns []string
+	priority int
+	settings map[string]interface{}
+	mappings map[string]interface{}
+	aliases  map[string]json.RawMessage
+}
+
+type aliasAction struct {
+	Index   string   `json:"index"`
+	Indices []string `json:"indices"`
+	Alias   string   `json:"alias"`
+	Aliases []string `json:"aliases"`
+}
+
+func (s *Server) putTemplate(name string, body []byte) *response {
+	var req struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Priority      int      `json:"priority"`
+		Template      struct {
+			Settings map[string]interface{}     `json:"settings"`
+			Mappings map[string]interface{}     `json:"mappings"`
+			Aliases  map[string]json.RawMessage `json:"aliases"`
+		} `json:"template"`
+	}
+	if e := json.Unmarshal(body, &req); e != nil {
+		return errorResponse(400, "parse_exception", e.Error(), "")
+	}
+	if len(req.IndexPatterns) == 0 {
+		return errorResponse(400, "action_request_validation_exception", "index patterns are missing", "")
+	}
+	s.templates[name] = &template{
+		raw:      append(json.RawMessage{}, body...),
+		patterns: req.IndexPatterns,
+		priority: req.Priority,
+		settings: req.Template.Settings,
+		mappings: req.Template.Mappings,
+		aliases:  req.Template.Aliases,
+	}
+	return success(map[string]bool{"acknowledged": true})
+}
+
+func (s *Server) templateRequest(method, name string, body []byte) *response {
+	switch method {
+	case "PUT", "POST":
+		return s.putTemplate(name, body)
+	case "GET":
+		t, ok := s.templates[name]
+		if !ok {
+			return errorResponse(404, "resource_not_found_exception", "index template matching ["+name+"] not found", "")
+		}
+		return success(map[string]interface{}{"index_templates": []interface{}{
+			map[string]interface{}{"name": name, "index_template": t.raw},
+		}})
+	case "DELETE":
+		if _, ok := s.templates[name]; !ok {
+			return errorResponse(404, "resource_not_found_exception", "index_template ["+name+"] missing", "")
+		}
+		delete(s.templates, name)
+		return success(map[string]bool{"acknowledged": true})
+	}
+	return errorResponse(405, "illegal_argument_exception", "method not allowed", "")
+}
+
+// applyTemplate copies settings, mappings and aliases of the matching template with the highest priority to
+// a new index.
+func (s *Server) applyTemplate(idx *index) {
+	var matched *template
+	for _, t := range s.templates {
+		for _, p := range t.patterns {
+			if ok, _ := path.Match(p, idx.name); ok && (matched == nil || t.priority > matched.priority) {
+				matched = t
+			}
+		}
+	}
+	if matched == nil {
+		return
+	}
+	if matched.settings != nil {
+		idx.settings = map[string]interface{}{}
+		mergeMaps(idx.settings, matched.settings)
+	}
+	mergeMaps(idx.mappings, matched.mappings)
+	for alias, options := range matched.aliases {
+		idx.aliases[alias] = options
+	}
+}
+
+// updateAliases executes all add, remove and remove_index actions or none when one of them fails.
+func (s *Server) updateAliases(body []byte) *response {
+	var req struct {
+		Actions []map[string]json.RawMessage `json:"actions"`
+	}
+	if e := json.Unmarshal(body, &req); e != nil {
+		return errorResponse(400, "parse_exception", e.Error(), "")
+	}
+	type change struct {
+		idx     *index
+		alias   string
+		options json.RawMessage
+	}
+	changes := []*change{}
+	removed := map[string]bool{}
+	for _, item := range req.Actions {
+		for action, raw := range item {
+			a := &aliasAction{}
+			if e := json.Unmarshal(raw, a); e != nil {
+				return errorResponse(400, "parse_exception", e.Error(), "")
+			}
+			for _, name := range append(a.Indices, a.Index) {
+				if name == "" {
+					continue
+				}
+				matched := s.match(name)
+				if len(matched) == 0 {
+					return indexNotFound(name)
+				}
+				for _, idx := range matched {
+					switch action {
+					case "add", "remove":
+						for _, alias := range append(a.Aliases, a.Alias) {
+							if alias == "" {
+								continue
+							}
+							if _, ok := idx.aliases[alias]; action == "remove" && !ok {
+								return errorResponse(404, "aliases_not_found_exception", "aliases ["+alias+"] missing", alias)
+							}
+							c := &change{idx: idx, alias: alias}
+							if action == "add" {
+								c.options = aliasOptions(raw)
+							}
+							changes = append(changes, c)
+						}
+					case "remove_index":
+						removed[idx.name] = true
+					default:
+						return errorResponse(400, "illegal_argument_exception", "unsupported action ["+action+"]", "")
+					}
+				}
+			}
+		}
+	}
+	for _, c := range changes {
+		if c.options == nil {
+			delete(c.idx.aliases, c.alias)
+		} else {
+			c.idx.aliases[c.alias] = c.options
+		}
+	}
+	for name := range removed {
+		delete(s.indices, name)
+	}
+	return success(map[string]bool{"acknowledged": true})
+}
+
+// aliasOptions returns the options of an add action without index and alias names.
+func aliasOptions(raw json.RawMessage) json.RawMessage {
+	options := map[string]interface{}{}
+	json.Unmarshal(raw, &options)
+	for _, key := range []string{"index", "indices", "alias", "aliases"} {
+		delete(options, key)
+	}
+	b, _ := json.Marshal(options)
+	return b
+}
+
+func (s *Server) getAlias(name string) *response {
+	rsp := map[string]interface{}{}
+	for _, idx := range s.indices {
+		aliases := map[string]json.RawMessage{}
+		for alias, options := range idx.aliases {
+			if ok, _ := path.Match(name, alias); ok {
+				aliases[alias] = options
+			}
+		}
+		if len(aliases) > 0 {
+			rsp[idx.name] = map[string]interface{}{"aliases": aliases}
+		}
+	}
+	if len(rsp) == 0 {
+		return &response{status: 404, body: map[string]interface{}{"error": "alias [" + name + "] missing", "status": 404}}
+	}
+	return success(rsp)
+}
+
+// resolve returns the write index of an alias or name when it is no alias.
+func (s *Server) resolve(name string) string {
+	if _, ok := s.indices[name]; ok {
+		return name
+	}
+	indices := []string{}
+	for _, idx := range s.indices {
+		if options, ok := idx.aliases[name]; ok {
+			var o struct {
+				IsWriteIndex bool `json:"is_write_index"`
+			}
+			json.Unmarshal(options, &o)
+			if o.IsWriteIndex {
+				return idx.name
+			}
+			indices = append(indices, idx.name)
+		}
+	}
+	if len(indices) == 1 {
+		return indices[0]
+	}
+	return name
+}
+
+// catIndices returns the rows of _cat/indices, the h parameter selects the columns.
+func (s *Server) catIndices(pattern string, r *http.Request) *response {
+	if pattern == "" {
+		pattern = "*"
+	}
+	matched := s.match(pattern)
+	if len(matched) == 0 && !strings.ContainsAny(pattern, "*,") {
+		return indexNotFound(pattern)
+	}
+	columns := []string{}
+	if h := r.URL.Query().Get("h"); h != "" {
+		columns = strings.Split(h, ",")
+	}
+	rows := []map[string]interface{}{}
+	for _, idx := range matched {
+		row := map[string]interface{}{
+			"health": "green", "status": "open", "index": idx.name, "uuid": idx.name, "pri": "1", "rep": "0",
+			"docs.count": strconv.Itoa(len(idx.visible)), "docs.deleted": "0",
+			"store.size": strconv.Itoa(idx.size()), "pri.store.size": strconv.Itoa(idx.size()),
+		}
+		if idx.closed {
+			row["status"] = "close"
+			for _, key := range []string{"health", "docs.count", "docs.deleted", "store.size", "pri.store.size"} {
+				row[key] = nil
+			}
+		}
+		if len(columns) > 0 {
+			selected := map[string]interface{}{}
+			for _, c := range columns {
+				selected[c] = row[c]
+			}
+			row = selected
+		}
+		rows = append(rows, row)
+	}
+	return success(rows)
+}
+
+// size returns the size of all visible sources.
+func (idx *index) size() int {
+	size := 0
+	for _, doc := range idx.visible {
+		size += len(doc.source)
+	}
+	return size
+}
+
+// openClose opens or closes all matching indices.
+func (s *Server) openClose(name string, closed bool) *response {
+	matched := s.match(name)
+	if len(matched) == 0 {
+		return indexNotFound(name)
+	}
+	names := []string{}
+	for _, idx := range matched {
+		idx.closed = closed
+		names = append(names, idx.name)
+	}
+	sort.Strings(names)
+	rsp := map[string]interface{}{"acknowledged": true, "shards_acknowledged": true}
+	if closed {
+		indices := map[string]interface{}{}
+		for _, name := range names {
+			indices[name] = map[string]bool{"closed": true}
+		}
+		rsp["indices"] = indices
+	}
+	return success(rsp)
+}
+
+func (s *Server) forceMerge(name string) *response {
+	matched := s.match(name)
+	if len(matched) == 0 {
+		return indexNotFound(name)
+	}
+	for _, idx := range matched {
+		if idx.closed {
+			return indexClosed(idx.name)
+		}
+	}
+	return success(shards())
+}
+
+func indexClosed(name string) *response {
+	return errorResponse(400, "index_closed_exception", "closed", name)
+}
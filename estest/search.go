@@ -0,0 +1,948 @@
+package estest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type searchRequest struct {
+	Query            interface{}                `json:"query"`
+	Size             *int                       `json:"size"`
+	From             int                        `json:"from"`
+	Sort             interface{}                `json:"sort"`
+	Aggs             map[string]json.RawMessage `json:"aggs"`
+	Aggregations     map[string]json.RawMessage `json:"aggregations"`
+	Facets           map[string]json.RawMessage `json:"facets"`
+	Version          bool                       `json:"version"`
+	SeqNoPrimaryTerm bool                       `json:"seq_no_primary_term"`
+}
+
+type hit struct {
+	index *index
+	doc   *document
+}
+
+func (s *Server) search(name, kind string, r *http.Request, body []byte) *response {
+	matched := s.match(name)
+	if len(matched) == 0 && !strings.ContainsAny(name, "*,") {
+		return indexNotFound(name)
+	}
+	req := &searchRequest{}
+	if len(body) > 0 {
+		if e := json.Unmarshal(body, req); e != nil {
+			return errorResponse(400, "parsing_exception", e.Error(), "")
+		}
+	}
+	q := r.URL.Query()
+	if queryString := q.Get("q"); queryString != "" {
+		req.Query = map[string]interface{}{"query_string": map[string]interface{}{"query": queryString}}
+	}
+	if size := q.Get("size"); size != "" {
+		i, _ := strconv.Atoi(size)
+		req.Size = &i
+	}
+	if from := q.Get("from"); from != "" {
+		req.From, _ = strconv.Atoi(from)
+	}
+	if sortParam := q.Get("sort"); sortParam != "" {
+		req.Sort = strings.Split(sortParam, ",")
+	}
+	if _, e := matches(req.Query, map[string]interface{}{}); e != nil {
+		return errorResponse(400, "parsing_exception", e.Error(), "")
+	}
+	hits := []*hit{}
+	for _, idx := range matched {
+		for _, doc := range idx.sortedDocs() {
+			m, e := matches(req.Query, doc.fields)
+			if e != nil {
+				return errorResponse(400, "parsing_exception", e.Error(), idx.name)
+			}
+			if m {
+				hits = append(hits, &hit{index: idx, doc: doc})
+			}
+		}
+	}
+	if kind == "_count" {
+		return success(map[string]interface{}{"count": len(hits), "_shards": shards()["_shards"]})
+	}
+	if e := sortHits(hits, req.Sort); e != nil {
+		return errorResponse(400, "parsing_exception", e.Error(), "")
+	}
+	size := 10
+	if req.Size != nil {
+		size = *req.Size
+	}
+	page := []interface{}{}
+	for i := req.From; i < len(hits) && i < req.From+size; i++ {
+		h := map[string]interface{}{
+			"_index":  hits[i].index.name,
+			"_type":   "_doc",
+			"_id":     hits[i].doc.id,
+			"_score":  1.0,
+			"_source": hits[i].doc.source,
+		}
+		if req.Version {
+			h["_version"] = hits[i].doc.version
+		}
+		if req.SeqNoPrimaryTerm {
+			h["_seq_no"], h["_primary_term"] = hits[i].doc.seqNo, 1
+		}
+		if req.Sort != nil {
+			h["sort"] = sortValues(hits[i].doc.fields, req.Sort)
+		}
+		page = append(page, h)
+	}
+	rsp := map[string]interface{}{
+		"took":      1,
+		"timed_out": false,
+		"_shards":   shards()["_shards"],
+		"hits": map[string]interface{}{
+			"total":     map[string]interface{}{"value": len(hits), "relation": "eq"},
+			"max_score": 1.0,
+			"hits":      page,
+		},
+	}
+	docs := make([]map[string]interface{}, len(hits))
+	for i, h := range hits {
+		docs[i] = h.doc.fields
+	}
+	aggs := req.Aggs
+	if aggs == nil {
+		aggs = req.Aggregations
+	}
+	if len(aggs) > 0 {
+		results, e := aggregate(aggs, docs)
+		if e != nil {
+			return errorResponse(400, "parsing_exception", e.Error(), "")
+		}
+		rsp["aggregations"] = results
+	}
+	if len(req.Facets) > 0 {
+		results, e := facets(req.Facets, docs)
+		if e != nil {
+			return errorResponse(400, "parsing_exception", e.Error(), "")
+		}
+		rsp["facets"] = results
+	}
+	return success(rsp)
+}
+
+// values returns all values of the field (dot separated path), arrays are flattened. A .keyword suffix is
+// ignored when the document has no such field.
+func values(fields map[string]interface{}, field string) []interface{} {
+	vals := lookup(fields, strings.Split(field, "."))
+	if len(vals) == 0 && strings.HasSuffix(field, ".keyword") {
+		vals = lookup(fields, strings.Split(strings.TrimSuffix(field, ".keyword"), "."))
+	}
+	return vals
+}
+
+func lookup(v interface{}, parts []string) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		vals := []interface{}{}
+		for _, item := range v {
+			vals = append(vals, lookup(item, parts)...)
+		}
+		return vals
+	case map[string]interface{}:
+		if len(parts) == 0 {
+			return []interface{}{v}
+		}
+		// field names may contain dots
+		for i := len(parts); i > 0; i-- {
+			if child, ok := v[strings.Join(parts[:i], ".")]; ok {
+				return lookup(child, parts[i:])
+			}
+		}
+		return nil
+	case nil:
+		return nil
+	}
+	if len(parts) > 0 {
+		return nil
+	}
+	return []interface{}{v}
+}
+
+// allValues returns the values of all leaf fields.
+func allValues(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		vals := []interface{}{}
+		for _, item := range v {
+			vals = append(vals, allValues(item)...)
+		}
+		return vals
+	case map[string]interface{}:
+		vals := []interface{}{}
+		for _, item := range v {
+			vals = append(vals, allValues(item)...)
+		}
+		return vals
+	case nil:
+		return nil
+	}
+	return []interface{}{v}
+}
+
+// tokens splits text like the standard analyzer (lowercase, split on non letters and digits).
+func tokens(v interface{}) []string {
+	return strings.FieldsFunc(strings.ToLower(fmt.Sprint(v)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func equal(a, b interface{}) bool {
+	fa, aIsNum := number(a)
+	fb, bIsNum := number(b)
+	if aIsNum && bIsNum {
+		return fa == fb
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, e := v.Float64()
+		return f, e == nil
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// compare returns -1, 0 or 1. Numbers are compared numerically, all other values as strings.
+func compare(a, b interface{}) int {
+	fa, aIsNum := number(a)
+	fb, bIsNum := number(b)
+	if !aIsNum || !bIsNum {
+		if aIsNum != bIsNum {
+			// e.g. a numeric field compared with a numeric string from a query
+			if f, e := strconv.ParseFloat(fmt.Sprint(a), 64); e == nil {
+				fa, aIsNum = f, true
+			}
+			if f, e := strconv.ParseFloat(fmt.Sprint(b), 64); e == nil {
+				fb, bIsNum = f, true
+			}
+		}
+	}
+	if aIsNum && bIsNum {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// matches evaluates the query for the document. A nil query matches all documents.
+func matches(query interface{}, fields map[string]interface{}) (bool, error) {
+	if query == nil {
+		return true, nil
+	}
+	q, ok := query.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("query must be an object, got %T", query)
+	}
+	if len(q) == 0 {
+		return true, nil
+	}
+	for kind, body := range q {
+		m, e := matchesKind(kind, body, fields)
+		if e != nil || !m {
+			return false, e
+		}
+	}
+	return true, nil
+}
+
+func matchesKind(kind string, body interface{}, fields map[string]interface{}) (bool, error) {
+	switch kind {
+	case "match_all":
+		return true, nil
+	case "match_none":
+		return false, nil
+	case "bool":
+		return matchesBool(body, fields)
+	case "filtered":
+		b, _ := body.(map[string]interface{})
+		if m, e := matches(b["query"], fields); e != nil || !m {
+			return false, e
+		}
+		return matches(b["filter"], fields)
+	case "and":
+		list, _ := body.([]interface{})
+		for _, sub := range list {
+			if m, e := matches(sub, fields); e != nil || !m {
+				return false, e
+			}
+		}
+		return true, nil
+	case "nested", "constant_score":
+		b, _ := body.(map[string]interface{})
+		if sub, ok := b["query"]; ok {
+			return matches(sub, fields)
+		}
+		return matches(b["filter"], fields)
+	case "exists":
+		b, _ := body.(map[string]interface{})
+		field, _ := b["field"].(string)
+		return len(values(fields, field)) > 0, nil
+	case "query_string":
+		b, _ := body.(map[string]interface{})
+		return matchesQueryString(b, fields)
+	case "multi_match":
+		b, _ := body.(map[string]interface{})
+		list, _ := b["fields"].([]interface{})
+		for _, f := range list {
+			name := strings.SplitN(fmt.Sprint(f), "^", 2)[0]
+			if m := matchText(values(fields, name), b["query"], fmt.Sprint(b["operator"])); m {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	b, ok := body.(map[string]interface{})
+	if !ok || len(b) != 1 {
+		return false, fmt.Errorf("[%s] query malformed, expected a single field", kind)
+	}
+	for field, params := range b {
+		vals := values(fields, field)
+		switch kind {
+		case "term":
+			value := params
+			if p, ok := params.(map[string]interface{}); ok {
+				value = p["value"]
+			}
+			for _, v := range vals {
+				if equal(v, value) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "terms":
+			list, _ := params.([]interface{})
+			for _, v := range vals {
+				for _, value := range list {
+					if equal(v, value) {
+						return true, nil
+					}
+				}
+			}
+			return false, nil
+		case "match", "match_phrase":
+			query, operator := params, ""
+			if p, ok := params.(map[string]interface{}); ok {
+				query, operator = p["query"], fmt.Sprint(p["operator"])
+			}
+			if kind == "match_phrase" {
+				operator = "and"
+			}
+			return matchText(vals, query, operator), nil
+		case "range":
+			p, _ := params.(map[string]interface{})
+			for _, v := range vals {
+				if inRange(v, p) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "prefix", "wildcard":
+			value := params
+			if p, ok := params.(map[string]interface{}); ok {
+				value = p["value"]
+			}
+			pattern := fmt.Sprint(value)
+			if kind == "prefix" {
+				pattern = escapePattern(pattern) + "*"
+			}
+			for _, v := range vals {
+				if m, _ := path.Match(pattern, fmt.Sprint(v)); m {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("unsupported query [%s]", kind)
+}
+
+func escapePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+	return r.Replace(s)
+}
+
+func matchText(vals []interface{}, query interface{}, operator string) bool {
+	docTokens := map[string]bool{}
+	for _, v := range vals {
+		for _, t := range tokens(v) {
+			docTokens[t] = true
+		}
+	}
+	queryTokens := tokens(query)
+	if len(queryTokens) == 0 {
+		return false
+	}
+	matched := 0
+	for _, t := range queryTokens {
+		if docTokens[t] {
+			matched++
+		}
+	}
+	if strings.EqualFold(operator, "and") {
+		return matched == len(queryTokens)
+	}
+	return matched > 0
+}
+
+func inRange(v interface{}, p map[string]interface{}) bool {
+	// from/to were used by range filters before Elasticsearch 1.0
+	bounds := map[string]interface{}{}
+	for k, bound := range p {
+		switch k {
+		case "from":
+			k = "gte"
+		case "to":
+			k = "lte"
+		}
+		bounds[k] = bound
+	}
+	for op, bound := range bounds {
+		if bound == nil {
+			continue
+		}
+		c := compare(v, bound)
+		switch op {
+		case "gt":
+			if c <= 0 {
+				return false
+			}
+		case "gte":
+			if c < 0 {
+				return false
+			}
+		case "lt":
+			if c >= 0 {
+				return false
+			}
+		case "lte":
+			if c > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesBool(body interface{}, fields map[string]interface{}) (bool, error) {
+	b, ok := body.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("[bool] query malformed")
+	}
+	clauses := func(key string) []interface{} {
+		switch v := b[key].(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			return []interface{}{v}
+		}
+		return nil
+	}
+	for _, key := range []string{"must", "filter"} {
+		for _, q := range clauses(key) {
+			if m, e := matches(q, fields); e != nil || !m {
+				return false, e
+			}
+		}
+	}
+	for _, q := range clauses("must_not") {
+		if m, e := matches(q, fields); e != nil || m {
+			return false, e
+		}
+	}
+	should := clauses("should")
+	if len(should) == 0 {
+		return true, nil
+	}
+	minimum := 1
+	if len(clauses("must"))+len(clauses("filter")) > 0 {
+		minimum = 0
+	}
+	if v, ok := number(b["minimum_should_match"]); ok {
+		minimum = int(v)
+	}
+	matched := 0
+	for _, q := range should {
+		m, e := matches(q, fields)
+		if e != nil {
+			return false, e
+		}
+		if m {
+			matched++
+		}
+	}
+	return matched >= minimum, nil
+}
+
+// matchesQueryString supports terms (field:value or value), ranges (field:[a TO b] and field:{a TO b}),
+// quoted phrases, wildcards and the operators AND, OR and NOT.
+func matchesQueryString(b map[string]interface{}, fields map[string]interface{}) (bool, error) {
+	query := fmt.Sprint(b["query"])
+	defaultAnd := strings.EqualFold(fmt.Sprint(b["default_operator"]), "and")
+	defaultField, _ := b["default_field"].(string)
+	clauses, e := parseQueryString(query)
+	if e != nil {
+		return false, e
+	}
+	hasAnd := defaultAnd
+	for _, c := range clauses {
+		if c.and {
+			hasAnd = true
+		}
+	}
+	anyShould, matchedShould := false, false
+	for _, c := range clauses {
+		if c.field == "" {
+			c.field = defaultField
+		}
+		m := c.matches(fields)
+		switch {
+		case c.not:
+			if m {
+				return false, nil
+			}
+		case hasAnd:
+			if !m {
+				return false, nil
+			}
+		default:
+			anyShould = true
+			matchedShould = matchedShould || m
+		}
+	}
+	return !anyShould || matchedShould, nil
+}
+
+type queryClause struct {
+	field      string
+	value      string
+	phrase     bool
+	rangeQuery map[string]interface{}
+	and        bool
+	not        bool
+}
+
+func parseQueryString(query string) ([]*queryClause, error) {
+	words := []string{}
+	current := &strings.Builder{}
+	inQuotes, inRange := false, false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case (r == '[' || r == '{') && !inQuotes:
+			inRange = true
+			current.WriteRune(r)
+		case (r == ']' || r == '}') && !inQuotes:
+			inRange = false
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuotes && !inRange:
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes || inRange {
+		return nil, fmt.Errorf("failed to parse query [%s]", query)
+	}
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+	clauses := []*queryClause{}
+	and, not := false, false
+	for _, w := range words {
+		switch w {
+		case "AND", "&&":
+			and = true
+			if len(clauses) > 0 {
+				clauses[len(clauses)-1].and = true
+			}
+			continue
+		case "OR", "||":
+			continue
+		case "NOT", "!":
+			not = true
+			continue
+		}
+		c := &queryClause{and: and, not: not}
+		and, not = false, false
+		if strings.HasPrefix(w, "-") {
+			c.not, w = true, w[1:]
+		} else if strings.HasPrefix(w, "+") {
+			c.and, w = true, w[1:]
+		}
+		if i := strings.Index(w, ":"); i > 0 && !strings.HasPrefix(w, `"`) {
+			c.field, w = w[:i], w[i+1:]
+		}
+		switch {
+		case len(w) > 1 && (w[0] == '[' || w[0] == '{'):
+			parts := strings.Split(w[1:len(w)-1], " TO ")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("failed to parse range [%s]", w)
+			}
+			lower, upper := "gte", "lte"
+			if w[0] == '{' {
+				lower = "gt"
+			}
+			if w[len(w)-1] == '}' {
+				upper = "lt"
+			}
+			c.rangeQuery = map[string]interface{}{}
+			if from := strings.TrimSpace(parts[0]); from != "*" {
+				c.rangeQuery[lower] = from
+			}
+			if to := strings.TrimSpace(parts[1]); to != "*" {
+				c.rangeQuery[upper] = to
+			}
+		case strings.HasPrefix(w, `"`):
+			c.phrase, c.value = true, strings.Trim(w, `"`)
+		default:
+			c.value = w
+		}
+		clauses = append(clauses, c)
+	}
+	return clauses, nil
+}
+
+func (c *queryClause) matches(fields map[string]interface{}) bool {
+	var vals []interface{}
+	if c.field == "" || c.field == "*" {
+		vals = allValues(fields)
+	} else {
+		vals = values(fields, c.field)
+	}
+	if c.rangeQuery != nil {
+		for _, v := range vals {
+			if inRange(v, c.rangeQuery) {
+				return true
+			}
+		}
+		return false
+	}
+	if c.phrase {
+		return matchText(vals, c.value, "and")
+	}
+	if strings.ContainsAny(c.value, "*?") {
+		pattern := strings.ToLower(c.value)
+		for _, v := range vals {
+			for _, t := range append(tokens(v), strings.ToLower(fmt.Sprint(v))) {
+				if m, _ := path.Match(pattern, t); m {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, v := range vals {
+		if equal(v, c.value) {
+			return true
+		}
+	}
+	return matchText(vals, c.value, "and")
+}
+
+type sortField struct {
+	field string
+	desc  bool
+}
+
+func parseSort(s interface{}) ([]*sortField, error) {
+	fieldsOf := func(v interface{}) ([]*sortField, error) {
+		switch v := v.(type) {
+		case string:
+			parts := strings.SplitN(v, ":", 2)
+			return []*sortField{{field: parts[0], desc: len(parts) == 2 && parts[1] == "desc" || parts[0] == "_score"}}, nil
+		case map[string]interface{}:
+			names := make([]string, 0, len(v))
+			for name := range v {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			list := []*sortField{}
+			for _, name := range names {
+				f := &sortField{field: name, desc: name == "_score"}
+				switch o := v[name].(type) {
+				case string:
+					f.desc = o == "desc"
+				case map[string]interface{}:
+					if order, ok := o["order"].(string); ok {
+						f.desc = order == "desc"
+					}
+				}
+				list = append(list, f)
+			}
+			return list, nil
+		}
+		return nil, fmt.Errorf("invalid sort %v", v)
+	}
+	switch s := s.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		list := []*sortField{}
+		for _, item := range s {
+			f, e := fieldsOf(item)
+			if e != nil {
+				return nil, e
+			}
+			list = append(list, f...)
+		}
+		return list, nil
+	case []string:
+		list := []*sortField{}
+		for _, item := range s {
+			f, _ := fieldsOf(item)
+			list = append(list, f...)
+		}
+		return list, nil
+	}
+	return fieldsOf(s)
+}
+
+func sortHits(hits []*hit, s interface{}) error {
+	fields, e := parseSort(s)
+	if e != nil || len(fields) == 0 {
+		return e
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		for _, f := range fields {
+			if f.field == "_score" || f.field == "_doc" || f.field == "_shard_doc" {
+				continue
+			}
+			a, b := values(hits[i].doc.fields, f.field), values(hits[j].doc.fields, f.field)
+			switch {
+			case len(a) == 0 && len(b) == 0:
+				continue
+			case len(a) == 0:
+				return false // missing values last
+			case len(b) == 0:
+				return true
+			}
+			c := compare(a[0], b[0])
+			if c == 0 {
+				continue
+			}
+			if f.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+func sortValues(fields map[string]interface{}, s interface{}) []interface{} {
+	list, _ := parseSort(s)
+	vals := []interface{}{}
+	for _, f := range list {
+		if v := values(fields, f.field); len(v) > 0 {
+			vals = append(vals, v[0])
+		} else {
+			vals = append(vals, nil)
+		}
+	}
+	return vals
+}
+
+type aggregation struct {
+	Terms *struct {
+		Field string `json:"field"`
+		Size  int    `json:"size"`
+	} `json:"terms"`
+	Min          *metricAgg                 `json:"min"`
+	Max          *metricAgg                 `json:"max"`
+	Avg          *metricAgg                 `json:"avg"`
+	Sum          *metricAgg                 `json:"sum"`
+	ValueCount   *metricAgg                 `json:"value_count"`
+	Stats        *metricAgg                 `json:"stats"`
+	Aggs         map[string]json.RawMessage `json:"aggs"`
+	Aggregations map[string]json.RawMessage `json:"aggregations"`
+}
+
+type metricAgg struct {
+	Field string `json:"field"`
+}
+
+func aggregate(aggs map[string]json.RawMessage, docs []map[string]interface{}) (map[string]interface{}, error) {
+	results := map[string]interface{}{}
+	for name, raw := range aggs {
+		agg := &aggregation{}
+		if e := json.Unmarshal(raw, agg); e != nil {
+			return nil, e
+		}
+		sub := agg.Aggs
+		if sub == nil {
+			sub = agg.Aggregations
+		}
+		var e error
+		switch {
+		case agg.Terms != nil:
+			results[name], e = termsAggregation(agg.Terms.Field, agg.Terms.Size, sub, docs)
+		case agg.Min != nil:
+			results[name] = map[string]interface{}{"value": metric(agg.Min.Field, docs)["min"]}
+		case agg.Max != nil:
+			results[name] = map[string]interface{}{"value": metric(agg.Max.Field, docs)["max"]}
+		case agg.Avg != nil:
+			results[name] = map[string]interface{}{"value": metric(agg.Avg.Field, docs)["avg"]}
+		case agg.Sum != nil:
+			results[name] = map[string]interface{}{"value": metric(agg.Sum.Field, docs)["sum"]}
+		case agg.ValueCount != nil:
+			results[name] = map[string]interface{}{"value": metric(agg.ValueCount.Field, docs)["count"]}
+		case agg.Stats != nil:
+			results[name] = metric(agg.Stats.Field, docs)
+		default:
+			return nil, fmt.Errorf("unsupported aggregation [%s]", name)
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+	return results, nil
+}
+
+func metric(field string, docs []map[string]interface{}) map[string]interface{} {
+	count, sum, min, max := 0, 0.0, math.Inf(1), math.Inf(-1)
+	for _, doc := range docs {
+		for _, v := range values(doc, field) {
+			f, ok := number(v)
+			if !ok {
+				continue
+			}
+			count++
+			sum += f
+			min = math.Min(min, f)
+			max = math.Max(max, f)
+		}
+	}
+	if count == 0 {
+		return map[string]interface{}{"count": 0, "min": nil, "max": nil, "avg": nil, "sum": 0.0}
+	}
+	return map[string]interface{}{"count": count, "min": min, "max": max, "avg": sum / float64(count), "sum": sum}
+}
+
+type termCount struct {
+	key  interface{}
+	docs []map[string]interface{}
+}
+
+// countTerms returns the terms of the field sorted by count (desc) and key (asc).
+func countTerms(field string, docs []map[string]interface{}) (counts []*termCount, missing int) {
+	byKey := map[string]*termCount{}
+	for _, doc := range docs {
+		vals := values(doc, field)
+		if len(vals) == 0 {
+			missing++
+		}
+		seen := map[string]bool{}
+		for _, v := range vals {
+			key := fmt.Sprint(v)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if byKey[key] == nil {
+				byKey[key] = &termCount{key: v}
+				counts = append(counts, byKey[key])
+			}
+			byKey[key].docs = append(byKey[key].docs, doc)
+		}
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if len(counts[i].docs) != len(counts[j].docs) {
+			return len(counts[i].docs) > len(counts[j].docs)
+		}
+		return compare(counts[i].key, counts[j].key) < 0
+	})
+	return counts, missing
+}
+
+func termsAggregation(field string, size int, sub map[string]json.RawMessage, docs []map[string]interface{}) (interface{}, error) {
+	if size == 0 {
+		size = 10
+	}
+	counts, _ := countTerms(field, docs)
+	buckets := []interface{}{}
+	other := 0
+	for i, c := range counts {
+		if i >= size {
+			other += len(c.docs)
+			continue
+		}
+		bucket := map[string]interface{}{"key": c.key, "doc_count": len(c.docs)}
+		if len(sub) > 0 {
+			results, e := aggregate(sub, c.docs)
+			if e != nil {
+				return nil, e
+			}
+			for k, v := range results {
+				bucket[k] = v
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+	return map[string]interface{}{"doc_count_error_upper_bound": 0, "sum_other_doc_count": other, "buckets": buckets}, nil
+}
+
+func facets(facets map[string]json.RawMessage, docs []map[string]interface{}) (map[string]interface{}, error) {
+	results := map[string]interface{}{}
+	for name, raw := range facets {
+		var facet struct {
+			Terms *struct {
+				Field string `json:"field"`
+				Size  int    `json:"size"`
+			} `json:"terms"`
+		}
+		if e := json.Unmarshal(raw, &facet); e != nil {
+			return nil, e
+		}
+		if facet.Terms == nil {
+			return nil, fmt.Errorf("unsupported facet [%s]", name)
+		}
+		size := facet.Terms.Size
+		if size == 0 {
+			size = 10
+		}
+		counts, missing := countTerms(facet.Terms.Field, docs)
+		terms := []interface{}{}
+		total, other := 0, 0
+		for i, c := range counts {
+			total += len(c.docs)
+			if i >= size {
+				other += len(c.docs)
+				continue
+			}
+			terms = append(terms, map[string]interface{}{"term": c.key, "count": len(c.docs)})
+		}
+		results[name] = map[string]interface{}{"_type": "terms", "missing": missing, "total": total, "other": other, "terms": terms}
+	}
+	return results, nil
+}
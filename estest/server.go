@@ -0,0 +1,884 @@
+// Package estest provides an in-memory fake of the Elasticsearch HTTP API for tests.
+//
+// The fake supports creating, deleting, opening, closing and force merging indices, mappings, aliases,
+// composable index templates, index stats, _cat/indices, cluster health, single document requests, _bulk,
+// _refresh, _count, _delete_by_query and a subset of _search: match_all, term, terms, match, multi_match,
+// range, exists, prefix, wildcard, bool and simple query_string queries, sorting, size/from, terms
+// aggregations with metric sub aggregations and terms facets. Like Elasticsearch, documents become visible to
+// searches after a refresh, get requests are realtime.
+//
+// Requests and their bodies are recorded. Respond registers canned responses for APIs the fake does not
+// support or to inject errors, FailBulkItems rejects single items of bulk requests.
+//
+//	s := estest.NewServer()
+//	defer s.Close()
+//	index := &es.Index{Host: s.Host(), Port: s.Port(), Index: "logs"}
+package estest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a fake Elasticsearch node. All methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	indices   map[string]*index
+	templates map[string]*template
+	requests  []string
+	bodies    []string
+	responses map[string][]*cannedResponse
+	failItem  func(action, index, id string) int
+	nextId    int
+}
+
+type cannedResponse struct {
+	status int
+	body   string
+}
+
+type document struct {
+	id      string
+	source  json.RawMessage
+	fields  map[string]interface{}
+	version int64
+	seqNo   int64
+	order   int
+}
+
+type index struct {
+	name     string
+	docs     map[string]*document
+	visible  map[string]*document // docs as of the last refresh
+	mappings map[string]interface{}
+	settings map[string]interface{}
+	aliases  map[string]json.RawMessage // options by alias name
+	closed   bool
+	seqNo    int64
+	order    int
+}
+
+// NewServer starts a server without indices, it must be closed with Close.
+func NewServer() *Server {
+	s := &Server{}
+	s.reset()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Host returns the host of the server, use it together with Port for es.Index.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// Reset deletes all indices, templates, recorded requests, canned responses and bulk item failures.
+func (s *Server) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reset()
+}
+
+func (s *Server) reset() {
+	s.indices = map[string]*index{}
+	s.templates = map[string]*template{}
+	s.requests = nil
+	s.bodies = nil
+	s.responses = map[string][]*cannedResponse{}
+	s.failItem = nil
+}
+
+// Requests returns all requests received so far as "METHOD /path?query".
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Bodies returns the bodies of all requests received so far in the order of Requests.
+func (s *Server) Bodies() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.bodies...)
+}
+
+// Respond answers requests matching method and path (without query) with status and body instead of the fake.
+// Responses registered for the same request are used in order, the last one answers all further requests.
+func (s *Server) Respond(method, path string, status int, body string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := method + " " + path
+	s.responses[key] = append(s.responses[key], &cannedResponse{status: status, body: body})
+}
+
+// FailBulkItems calls f for every item of bulk requests. Items for which f returns a status other than 0 fail
+// with that status, 429 is reported as es_rejected_execution_exception and all others as
+// mapper_parsing_exception. f must not call methods of the server.
+func (s *Server) FailBulkItems(f func(action, index, id string) int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failItem = f
+}
+
+// canned returns the next canned response for the request or nil.
+func (s *Server) canned(r *http.Request) *cannedResponse {
+	key := r.Method + " " + r.URL.Path
+	list := s.responses[key]
+	if len(list) == 0 {
+		return nil
+	}
+	if len(list) > 1 {
+		s.responses[key] = list[1:]
+	}
+	return list[0]
+}
+
+// Indices returns the sorted names of all indices.
+func (s *Server) Indices() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	names := []string{}
+	for name := range s.indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Count returns the number of documents in the index including documents which are not yet refreshed.
+func (s *Server) Count(name string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if idx, ok := s.indices[name]; ok {
+		return len(idx.docs)
+	}
+	return 0
+}
+
+// Source returns the source of a document or nil when it does not exist.
+func (s *Server) Source(name, id string) json.RawMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if idx, ok := s.indices[name]; ok {
+		if doc, ok := idx.docs[id]; ok {
+			return doc.source
+		}
+	}
+	return nil
+}
+
+// Refresh makes all documents of all indices visible to searches.
+func (s *Server) Refresh() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, idx := range s.indices {
+		idx.refresh()
+	}
+}
+
+func newIndex(name string) *index {
+	return &index{name: name, docs: map[string]*document{}, visible: map[string]*document{}, mappings: map[string]interface{}{}, aliases: map[string]json.RawMessage{}, seqNo: -1}
+}
+
+func (idx *index) refresh() {
+	idx.visible = make(map[string]*document, len(idx.docs))
+	for id, doc := range idx.docs {
+		idx.visible[id] = doc
+	}
+}
+
+// sortedDocs returns the visible docs in the order they were indexed.
+func (idx *index) sortedDocs() []*document {
+	docs := make([]*document, 0, len(idx.visible))
+	for _, doc := range idx.visible {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].order < docs[j].order })
+	return docs
+}
+
+type response struct {
+	status int
+	body   interface{}
+}
+
+func success(body interface{}) *response {
+	return &response{status: 200, body: body}
+}
+
+func errorResponse(status int, errType, reason, indexName string) *response {
+	cause := map[string]interface{}{"type": errType, "reason": reason}
+	if indexName != "" {
+		cause["index"] = indexName
+	}
+	e := map[string]interface{}{"root_cause": []interface{}{cause}, "type": errType, "reason": reason}
+	if indexName != "" {
+		e["index"] = indexName
+	}
+	return &response{status: status, body: map[string]interface{}{"error": e, "status": status}}
+}
+
+func indexNotFound(name string) *response {
+	return errorResponse(404, "index_not_found_exception", "no such index ["+name+"]", name)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		http.Error(w, e.Error(), 500)
+		return
+	}
+	s.lock.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	s.bodies = append(s.bodies, string(body))
+	if canned := s.canned(r); canned != nil {
+		s.lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(canned.status)
+		w.Write([]byte(canned.body))
+		return
+	}
+	rsp := s.route(r, body)
+	if refresh := r.URL.Query().Get("refresh"); refresh == "true" || refresh == "wait_for" || refresh == "" && r.URL.Query()["refresh"] != nil {
+		for _, idx := range s.indices {
+			idx.refresh()
+		}
+	}
+	s.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rsp.status)
+	if r.Method != "HEAD" && rsp.body != nil {
+		json.NewEncoder(w).Encode(rsp.body)
+	}
+}
+
+func (s *Server) route(r *http.Request, body []byte) *response {
+	parts := []string{}
+	for _, p := range strings.Split(strings.Trim(r.URL.Path, "/"), "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return success(map[string]interface{}{"name": "estest", "cluster_name": "estest", "version": map[string]string{"number": "7.17.0"}})
+	}
+	last := parts[len(parts)-1]
+	switch {
+	case parts[0] == "_bulk":
+		return s.bulk("", body)
+	case parts[0] == "_refresh":
+		for _, idx := range s.indices {
+			idx.refresh()
+		}
+		return success(shards())
+	case parts[0] == "_mapping":
+		return s.getMapping("*")
+	case parts[0] == "_search" || parts[0] == "_count":
+		return s.search("*", parts[0], r, body)
+	case parts[0] == "_stats":
+		return s.stats("*")
+	case parts[0] == "_cluster" && len(parts) > 1 && parts[1] == "health":
+		return s.health()
+	case parts[0] == "_aliases" && r.Method == "POST":
+		return s.updateAliases(body)
+	case parts[0] == "_alias" && len(parts) == 2 && r.Method == "GET":
+		return s.getAlias(parts[1])
+	case parts[0] == "_index_template" && len(parts) == 2:
+		return s.templateRequest(r.Method, parts[1], body)
+	case parts[0] == "_cat" && len(parts) > 1 && parts[1] == "indices" && r.Method == "GET":
+		return s.catIndices(strings.Join(parts[2:], ","), r)
+	case strings.HasPrefix(parts[0], "_"):
+		return errorResponse(400, "illegal_argument_exception", "unsupported request "+r.Method+" "+r.URL.Path, "")
+	}
+	name := parts[0]
+	switch {
+	case len(parts) == 1:
+		return s.indexRequest(r.Method, name, body)
+	case last == "_open" && r.Method == "POST":
+		return s.openClose(name, false)
+	case last == "_close" && r.Method == "POST":
+		return s.openClose(name, true)
+	}
+	if !strings.Contains(name, "*") {
+		for _, idx := range s.match(name) {
+			if idx.closed {
+				return indexClosed(idx.name)
+			}
+		}
+	}
+	switch {
+	case last == "_forcemerge" && r.Method == "POST":
+		return s.forceMerge(name)
+	case last == "_bulk":
+		return s.bulk(name, body)
+	case last == "_refresh":
+		matched := s.match(name)
+		if len(matched) == 0 && !strings.ContainsAny(name, "*,") {
+			return indexNotFound(name)
+		}
+		for _, idx := range matched {
+			idx.refresh()
+		}
+		return success(shards())
+	case last == "_mapping" && r.Method == "GET":
+		return s.getMapping(name)
+	case last == "_mapping":
+		return s.putMapping(name, body)
+	case last == "_search" || last == "_count":
+		return s.search(name, last, r, body)
+	case last == "_stats":
+		return s.stats(name)
+	case last == "_delete_by_query":
+		return s.deleteByQuery(name, body, "")
+	case last == "_query" && r.Method == "DELETE":
+		return s.deleteByQuery(name, nil, r.URL.Query().Get("q"))
+	}
+	// single documents are read and written through the write index of aliases
+	name = s.resolve(name)
+	switch {
+	case parts[1] == "_update" && len(parts) == 3:
+		return s.update(name, parts[2], r, body)
+	case len(parts) == 4 && last == "_update":
+		return s.update(name, parts[2], r, body)
+	case parts[1] == "_create" && len(parts) == 3:
+		return s.write(name, parts[2], r, body, true)
+	case len(parts) == 3:
+		return s.docRequest(name, parts[2], r, body)
+	case len(parts) == 2 && (r.Method == "POST" || r.Method == "PUT"):
+		return s.write(name, "", r, body, false)
+	}
+	return errorResponse(400, "illegal_argument_exception", "unsupported request "+r.Method+" "+r.URL.Path, "")
+}
+
+func shards() map[string]interface{} {
+	return map[string]interface{}{"_shards": map[string]int{"total": 1, "successful": 1, "failed": 0}}
+}
+
+// health always reports a green single node cluster.
+func (s *Server) health() *response {
+	shards := len(s.indices)
+	return success(map[string]interface{}{
+		"cluster_name": "estest", "status": "green", "timed_out": false, "number_of_nodes": 1, "number_of_data_nodes": 1,
+		"active_primary_shards": shards, "active_shards": shards, "active_shards_percent_as_number": 100.0,
+	})
+}
+
+func (s *Server) stats(name string) *response {
+	matched := s.match(name)
+	if len(matched) == 0 && !strings.ContainsAny(name, "*,") {
+		return indexNotFound(name)
+	}
+	stats := func(docs int) map[string]interface{} {
+		values := map[string]interface{}{"docs": map[string]int{"count": docs, "deleted": 0}}
+		return map[string]interface{}{"primaries": values, "total": values}
+	}
+	total := 0
+	indices := map[string]interface{}{}
+	for _, idx := range matched {
+		total += len(idx.visible)
+		indices[idx.name] = stats(len(idx.visible))
+	}
+	return success(map[string]interface{}{
+		"_shards": map[string]int{"total": len(matched), "successful": len(matched), "failed": 0},
+		"_all":    stats(total),
+		"indices": indices,
+	})
+}
+
+// match returns the indices matching a comma separated list of names, aliases or wildcard patterns.
+func (s *Server) match(pattern string) []*index {
+	matched := []*index{}
+	seen := map[string]bool{}
+	for _, p := range strings.Split(pattern, ",") {
+		if p == "_all" {
+			p = "*"
+		}
+		for name, idx := range s.indices {
+			if seen[name] {
+				continue
+			}
+			ok, _ := path.Match(p, name)
+			for alias := range idx.aliases {
+				if !ok {
+					ok, _ = path.Match(p, alias)
+				}
+			}
+			if ok {
+				seen[name] = true
+				matched = append(matched, idx)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].name < matched[j].name })
+	return matched
+}
+
+func (s *Server) indexRequest(method, name string, body []byte) *response {
+	idx, exists := s.indices[name]
+	switch method {
+	case "HEAD", "GET":
+		if !exists {
+			idx, exists = s.indices[s.resolve(name)]
+		}
+		if !exists {
+			return indexNotFound(name)
+		}
+		return success(map[string]interface{}{name: map[string]interface{}{"mappings": idx.mappings, "settings": idx.settings}})
+	case "DELETE":
+		matched := s.match(name)
+		if len(matched) == 0 {
+			return indexNotFound(name)
+		}
+		for _, idx := range matched {
+			delete(s.indices, idx.name)
+		}
+		return success(map[string]bool{"acknowledged": true})
+	case "PUT", "POST":
+		if exists {
+			return errorResponse(400, "resource_already_exists_exception", "index ["+name+"] already exists", name)
+		}
+		idx = newIndex(name)
+		s.applyTemplate(idx)
+		if len(body) > 0 {
+			var config struct {
+				Settings map[string]interface{}     `json:"settings"`
+				Mappings map[string]interface{}     `json:"mappings"`
+				Aliases  map[string]json.RawMessage `json:"aliases"`
+			}
+			if e := json.Unmarshal(body, &config); e != nil {
+				return errorResponse(400, "parse_exception", e.Error(), name)
+			}
+			if config.Settings != nil {
+				if idx.settings == nil {
+					idx.settings = map[string]interface{}{}
+				}
+				mergeMaps(idx.settings, config.Settings)
+			}
+			mergeMaps(idx.mappings, config.Mappings)
+			for alias, options := range config.Aliases {
+				idx.aliases[alias] = options
+			}
+		}
+		s.indices[name] = idx
+		return success(map[string]interface{}{"acknowledged": true, "shards_acknowledged": true, "index": name})
+	}
+	return errorResponse(405, "illegal_argument_exception", "method not allowed", name)
+}
+
+func (s *Server) getMapping(name string) *response {
+	matched := s.match(name)
+	if len(matched) == 0 && !strings.ContainsAny(name, "*,") {
+		return indexNotFound(name)
+	}
+	rsp := map[string]interface{}{}
+	for _, idx := range matched {
+		rsp[idx.name] = map[string]interface{}{"mappings": idx.mappings}
+	}
+	return success(rsp)
+}
+
+func (s *Server) putMapping(name string, body []byte) *response {
+	matched := s.match(name)
+	if len(matched) == 0 {
+		return indexNotFound(name)
+	}
+	mapping := map[string]interface{}{}
+	if e := json.Unmarshal(body, &mapping); e != nil {
+		return errorResponse(400, "parse_exception", e.Error(), name)
+	}
+	for _, idx := range matched {
+		mergeMaps(idx.mappings, mapping)
+	}
+	return success(map[string]bool{"acknowledged": true})
+}
+
+func mergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeMaps(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func (s *Server) getOrCreate(name string) *index {
+	idx, ok := s.indices[name]
+	if !ok {
+		idx = newIndex(name)
+		s.applyTemplate(idx)
+		s.indices[name] = idx
+	}
+	return idx
+}
+
+func (s *Server) newId() string {
+	s.nextId++
+	return fmt.Sprintf("estest-%d", s.nextId)
+}
+
+func docMeta(idx *index, doc *document, result string) map[string]interface{} {
+	return map[string]interface{}{
+		"_index":        idx.name,
+		"_type":         "_doc",
+		"_id":           doc.id,
+		"_version":      doc.version,
+		"_seq_no":       doc.seqNo,
+		"_primary_term": 1,
+		"result":        result,
+		"_shards":       map[string]int{"total": 1, "successful": 1, "failed": 0},
+	}
+}
+
+// put stores the source and returns the document and the result (created or updated).
+func (idx *index) put(id string, source []byte) (*document, string, error) {
+	fields := map[string]interface{}{}
+	if e := json.Unmarshal(source, &fields); e != nil {
+		return nil, "", e
+	}
+	idx.seqNo++
+	result := "updated"
+	doc, exists := idx.docs[id]
+	if !exists {
+		result = "created"
+		idx.order++
+		doc = &document{id: id, order: idx.order}
+	}
+	updated := *doc
+	updated.source = append(json.RawMessage{}, source...)
+	updated.fields = fields
+	updated.version++
+	updated.seqNo = idx.seqNo
+	idx.docs[id] = &updated
+	return &updated, result, nil
+}
+
+func (idx *index) remove(id string) (*document, bool) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return nil, false
+	}
+	delete(idx.docs, id)
+	idx.seqNo++
+	deleted := *doc
+	deleted.version++
+	deleted.seqNo = idx.seqNo
+	return &deleted, true
+}
+
+// checkConditions validates if_seq_no, if_primary_term and version parameters.
+func checkConditions(idx *index, id string, r *http.Request) *response {
+	q := r.URL.Query()
+	var doc *document
+	if idx != nil {
+		doc = idx.docs[id]
+	}
+	if ifSeqNo := q.Get("if_seq_no"); ifSeqNo != "" {
+		if doc == nil || ifSeqNo != strconv.FormatInt(doc.seqNo, 10) || q.Get("if_primary_term") != "1" {
+			current := "-2"
+			if doc != nil {
+				current = strconv.FormatInt(doc.seqNo, 10)
+			}
+			return versionConflict(idx, id, "required seqNo ["+ifSeqNo+"], primary term ["+q.Get("if_primary_term")+"]. current document has seqNo ["+current+"] and primary term [1]")
+		}
+	}
+	if version := q.Get("version"); version != "" {
+		v, _ := strconv.ParseInt(version, 10, 64)
+		switch q.Get("version_type") {
+		case "external":
+			if doc != nil && v <= doc.version {
+				return versionConflict(idx, id, fmt.Sprintf("current version [%d] is higher or equal to the one provided [%d]", doc.version, v))
+			}
+		case "external_gte":
+			if doc != nil && v < doc.version {
+				return versionConflict(idx, id, fmt.Sprintf("current version [%d] is higher than the one provided [%d]", doc.version, v))
+			}
+		default:
+			if doc == nil || doc.version != v {
+				return versionConflict(idx, id, fmt.Sprintf("required version [%d]", v))
+			}
+		}
+	}
+	return nil
+}
+
+func versionConflict(idx *index, id, reason string) *response {
+	name := ""
+	if idx != nil {
+		name = idx.name
+	}
+	return errorResponse(409, "version_conflict_engine_exception", "["+id+"]: version conflict, "+reason, name)
+}
+
+// applyExternalVersion sets the version for writes with version_type external.
+func applyExternalVersion(doc *document, r *http.Request) {
+	q := r.URL.Query()
+	if t := q.Get("version_type"); t == "external" || t == "external_gte" {
+		doc.version, _ = strconv.ParseInt(q.Get("version"), 10, 64)
+	}
+}
+
+func (s *Server) docRequest(name, id string, r *http.Request, body []byte) *response {
+	idx, exists := s.indices[name]
+	switch r.Method {
+	case "GET", "HEAD":
+		if !exists {
+			return indexNotFound(name)
+		}
+		doc, ok := idx.docs[id]
+		if !ok {
+			return &response{status: 404, body: map[string]interface{}{"_index": name, "_type": "_doc", "_id": id, "found": false}}
+		}
+		return success(map[string]interface{}{
+			"_index": name, "_type": "_doc", "_id": id, "_version": doc.version, "_seq_no": doc.seqNo,
+			"_primary_term": 1, "found": true, "_source": doc.source,
+		})
+	case "PUT", "POST":
+		return s.write(name, id, r, body, r.URL.Query().Get("op_type") == "create")
+	case "DELETE":
+		if !exists {
+			return indexNotFound(name)
+		}
+		if rsp := checkConditions(idx, id, r); rsp != nil {
+			return rsp
+		}
+		doc, ok := idx.remove(id)
+		if !ok {
+			return &response{status: 404, body: map[string]interface{}{"_index": name, "_type": "_doc", "_id": id, "result": "not_found"}}
+		}
+		return success(docMeta(idx, doc, "deleted"))
+	}
+	return errorResponse(405, "illegal_argument_exception", "method not allowed", name)
+}
+
+func (s *Server) write(name, id string, r *http.Request, body []byte, create bool) *response {
+	idx := s.indices[name]
+	if id == "" {
+		id = s.newId()
+	}
+	if create && idx != nil {
+		if _, exists := idx.docs[id]; exists {
+			return versionConflict(idx, id, "document already exists (current version ["+strconv.FormatInt(idx.docs[id].version, 10)+"])")
+		}
+	}
+	if rsp := checkConditions(idx, id, r); rsp != nil {
+		return rsp
+	}
+	idx = s.getOrCreate(name)
+	doc, result, e := idx.put(id, body)
+	if e != nil {
+		return errorResponse(400, "mapper_parsing_exception", "failed to parse: "+e.Error(), name)
+	}
+	applyExternalVersion(doc, r)
+	rsp := success(docMeta(idx, doc, result))
+	if result == "created" {
+		rsp.status = 201
+	}
+	return rsp
+}
+
+type updateBody struct {
+	Doc         map[string]interface{} `json:"doc"`
+	DocAsUpsert bool                   `json:"doc_as_upsert"`
+	Upsert      map[string]interface{} `json:"upsert"`
+}
+
+func (s *Server) update(name, id string, r *http.Request, body []byte) *response {
+	update := &updateBody{}
+	if e := json.Unmarshal(body, update); e != nil {
+		return errorResponse(400, "parse_exception", e.Error(), name)
+	}
+	if rsp := checkConditions(s.indices[name], id, r); rsp != nil {
+		return rsp
+	}
+	rsp, _ := s.applyUpdate(name, id, update)
+	return rsp
+}
+
+func (s *Server) applyUpdate(name, id string, update *updateBody) (*response, bool) {
+	idx := s.indices[name]
+	var current *document
+	if idx != nil {
+		current = idx.docs[id]
+	}
+	var source map[string]interface{}
+	switch {
+	case current != nil:
+		source = current.fields
+		merged := map[string]interface{}{}
+		b, _ := json.Marshal(source)
+		json.Unmarshal(b, &merged)
+		mergeMaps(merged, update.Doc)
+		source = merged
+	case update.DocAsUpsert:
+		source = update.Doc
+	case update.Upsert != nil:
+		source = update.Upsert
+	default:
+		return errorResponse(404, "document_missing_exception", "["+id+"]: document missing", name), false
+	}
+	b, _ := json.Marshal(source)
+	if current != nil && bytes.Equal(b, current.source) {
+		return success(docMeta(idx, current, "noop")), true
+	}
+	idx = s.getOrCreate(name)
+	doc, result, e := idx.put(id, b)
+	if e != nil {
+		return errorResponse(400, "mapper_parsing_exception", e.Error(), name), false
+	}
+	rsp := success(docMeta(idx, doc, result))
+	if result == "created" {
+		rsp.status = 201
+	}
+	return rsp, true
+}
+
+func (s *Server) bulk(defaultIndex string, body []byte) *response {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	items := []interface{}{}
+	errors := false
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		meta := map[string]map[string]interface{}{}
+		if e := json.Unmarshal(line, &meta); e != nil || len(meta) != 1 {
+			return errorResponse(400, "illegal_argument_exception", "Malformed action/metadata line", "")
+		}
+		for action, atts := range meta {
+			name, _ := atts["_index"].(string)
+			if name == "" {
+				name = defaultIndex
+			}
+			id, _ := atts["_id"].(string)
+			var source []byte
+			if action != "delete" {
+				if !scanner.Scan() {
+					return errorResponse(400, "illegal_argument_exception", "The bulk request must be terminated by a newline", "")
+				}
+				source = append([]byte{}, scanner.Bytes()...)
+			}
+			if name != "" {
+				name = s.resolve(name)
+			}
+			rsp := s.bulkItem(action, name, id, source)
+			item := map[string]interface{}{"_index": name, "_type": "_doc", "_id": id, "status": rsp.status}
+			if m, ok := rsp.body.(map[string]interface{}); ok {
+				for _, k := range []string{"_id", "_version", "_seq_no", "_primary_term", "result"} {
+					if v, ok := m[k]; ok {
+						item[k] = v
+					}
+				}
+				if e, ok := m["error"]; ok {
+					item["error"] = e
+					errors = true
+				}
+			}
+			items = append(items, map[string]interface{}{action: item})
+		}
+	}
+	return success(map[string]interface{}{"took": 1, "errors": errors, "items": items})
+}
+
+func (s *Server) bulkItem(action, name, id string, source []byte) *response {
+	if name == "" {
+		return errorResponse(400, "action_request_validation_exception", "index is missing", "")
+	}
+	if s.failItem != nil {
+		if status := s.failItem(action, name, id); status != 0 {
+			if status == 429 {
+				return errorResponse(status, "es_rejected_execution_exception", "rejected execution of bulk item", name)
+			}
+			return errorResponse(status, "mapper_parsing_exception", "failed to parse", name)
+		}
+	}
+	if idx, ok := s.indices[name]; ok && idx.closed {
+		return indexClosed(name)
+	}
+	r := &http.Request{URL: &url.URL{}}
+	switch action {
+	case "index":
+		return s.write(name, id, r, source, false)
+	case "create":
+		return s.write(name, id, r, source, true)
+	case "update":
+		update := &updateBody{}
+		if e := json.Unmarshal(source, update); e != nil {
+			return errorResponse(400, "parse_exception", e.Error(), name)
+		}
+		rsp, _ := s.applyUpdate(name, id, update)
+		return rsp
+	case "delete":
+		idx, ok := s.indices[name]
+		if !ok {
+			return indexNotFound(name)
+		}
+		doc, found := idx.remove(id)
+		if !found {
+			return &response{status: 404, body: map[string]interface{}{"_id": id, "result": "not_found"}}
+		}
+		return success(docMeta(idx, doc, "deleted"))
+	}
+	return errorResponse(400, "illegal_argument_exception", "Malformed action/metadata line, expected one of [create, delete, index, update] but found ["+action+"]", name)
+}
+
+func (s *Server) deleteByQuery(name string, body []byte, q string) *response {
+	matched := s.match(name)
+	if len(matched) == 0 {
+		return indexNotFound(name)
+	}
+	var query interface{}
+	if q != "" {
+		query = map[string]interface{}{"query_string": map[string]interface{}{"query": q}}
+	} else {
+		var req struct {
+			Query interface{} `json:"query"`
+		}
+		if e := json.Unmarshal(body, &req); e != nil {
+			return errorResponse(400, "parse_exception", e.Error(), name)
+		}
+		query = req.Query
+	}
+	if _, e := matches(query, map[string]interface{}{}); e != nil {
+		return errorResponse(400, "parsing_exception", e.Error(), name)
+	}
+	deleted := 0
+	for _, idx := range matched {
+		for _, doc := range idx.sortedDocs() {
+			m, e := matches(query, doc.fields)
+			if e != nil {
+				return errorResponse(400, "parsing_exception", e.Error(), idx.name)
+			}
+			if m {
+				if _, ok := idx.remove(doc.id); ok {
+					deleted++
+				}
+			}
+		}
+	}
+	return success(map[string]interface{}{"took": 1, "timed_out": false, "total": deleted, "deleted": deleted, "failures": []interface{}{}})
+}
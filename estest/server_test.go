@@ -0,0 +1,284 @@
+package estest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func do(s *Server, method, path, body string) (int, map[string]interface{}) {
+	req, e := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if e != nil {
+		panic(e)
+	}
+	rsp, e := http.DefaultClient.Do(req)
+	if e != nil {
+		panic(e)
+	}
+	defer rsp.Body.Close()
+	b, _ := ioutil.ReadAll(rsp.Body)
+	m := map[string]interface{}{}
+	json.Unmarshal(b, &m)
+	return rsp.StatusCode, m
+}
+
+// get returns the value at the dot separated path of the response.
+func get(m map[string]interface{}, key string) interface{} {
+	vals := values(m, key)
+	if len(vals) == 0 {
+		return nil
+	}
+	return vals[0]
+}
+
+func ids(rsp map[string]interface{}) []string {
+	ids := []string{}
+	for _, hit := range values(rsp, "hits.hits") {
+		ids = append(ids, hit.(map[string]interface{})["_id"].(string))
+	}
+	return ids
+}
+
+const bulkBody = `{"index":{"_index":"logs","_id":"1"}}
+{"host":"web1","status":200,"took":10,"message":"GET /index.html","tags":["a","b"]}
+{"index":{"_index":"logs","_id":"2"}}
+{"host":"web2","status":500,"took":30,"message":"GET /api/users failed"}
+{"create":{"_index":"logs","_id":"3"}}
+{"host":"web1","status":404,"took":20,"message":"POST /api/login","user":{"name":"jane"}}
+{"index":{"_index":"logs","_id":"4"}}
+{"host":"web1","status":200,"took":40,"message":"GET /api/users"}
+{"create":{"_index":"logs","_id":"1"}}
+{"host":"web3"}
+{"delete":{"_index":"logs","_id":"5"}}
+`
+
+func TestServer(t *testing.T) {
+	Convey("Server", t, func() {
+		s := NewServer()
+		defer s.Close()
+
+		status, rsp := do(s, "GET", "/logs/_search", "")
+		So(status, ShouldEqual, 404)
+		So(get(rsp, "error.type"), ShouldEqual, "index_not_found_exception")
+
+		status, _ = do(s, "PUT", "/logs", `{"mappings":{"properties":{"host":{"type":"keyword"}}}}`)
+		So(status, ShouldEqual, 200)
+		status, rsp = do(s, "PUT", "/logs", "")
+		So(status, ShouldEqual, 400)
+		So(get(rsp, "error.type"), ShouldEqual, "resource_already_exists_exception")
+
+		status, rsp = do(s, "POST", "/_bulk", bulkBody)
+		So(status, ShouldEqual, 200)
+		So(rsp["errors"], ShouldEqual, true)
+		items := rsp["items"].([]interface{})
+		So(len(items), ShouldEqual, 6)
+		So(get(items[0].(map[string]interface{}), "index.result"), ShouldEqual, "created")
+		So(get(items[4].(map[string]interface{}), "create.status"), ShouldEqual, 409)
+		So(get(items[5].(map[string]interface{}), "delete.status"), ShouldEqual, 404)
+		So(s.Count("logs"), ShouldEqual, 4)
+
+		Convey("documents are only visible to searches after a refresh", func() {
+			_, rsp := do(s, "GET", "/logs/_search", "")
+			So(get(rsp, "hits.total.value"), ShouldEqual, 0)
+			status, rsp := do(s, "GET", "/logs/_doc/1", "")
+			So(status, ShouldEqual, 200)
+			So(get(rsp, "_source.host"), ShouldEqual, "web1")
+			s.Refresh()
+			_, rsp = do(s, "GET", "/logs/_search", "")
+			So(get(rsp, "hits.total.value"), ShouldEqual, 4)
+		})
+
+		Convey("mappings", func() {
+			status, _ := do(s, "PUT", "/logs/_mapping", `{"properties":{"status":{"type":"integer"}}}`)
+			So(status, ShouldEqual, 200)
+			_, rsp := do(s, "GET", "/logs/_mapping", "")
+			So(get(rsp, "logs.mappings.properties.host.type"), ShouldEqual, "keyword")
+			So(get(rsp, "logs.mappings.properties.status.type"), ShouldEqual, "integer")
+		})
+
+		Convey("search", func() {
+			s.Refresh()
+			search := func(body string) map[string]interface{} {
+				status, rsp := do(s, "POST", "/logs/_search", body)
+				So(status, ShouldEqual, 200)
+				return rsp
+			}
+			So(ids(search(`{"query":{"term":{"host":"web1"}}}`)), ShouldResemble, []string{"1", "3", "4"})
+			So(ids(search(`{"query":{"term":{"host.keyword":{"value":"web2"}}}}`)), ShouldResemble, []string{"2"})
+			So(ids(search(`{"query":{"terms":{"status":[404,500]}}}`)), ShouldResemble, []string{"2", "3"})
+			So(ids(search(`{"query":{"match":{"message":"users login"}}}`)), ShouldResemble, []string{"2", "3", "4"})
+			So(ids(search(`{"query":{"match":{"message":{"query":"api users","operator":"and"}}}}`)), ShouldResemble, []string{"2", "4"})
+			So(ids(search(`{"query":{"range":{"took":{"gt":10,"lte":30}}}}`)), ShouldResemble, []string{"2", "3"})
+			So(ids(search(`{"query":{"exists":{"field":"user.name"}}}`)), ShouldResemble, []string{"3"})
+			So(ids(search(`{"query":{"bool":{"filter":[{"term":{"host":"web1"}}],"must_not":{"term":{"status":404}}}}}`)), ShouldResemble, []string{"1", "4"})
+			So(ids(search(`{"query":{"bool":{"should":[{"term":{"tags":"b"}},{"prefix":{"host":"web2"}}]}}}`)), ShouldResemble, []string{"1", "2"})
+			So(ids(search(`{"query":{"query_string":{"query":"host:web1 AND status:[200 TO 299]"}}}`)), ShouldResemble, []string{"1", "4"})
+			So(ids(search(`{"query":{"query_string":{"query":"failed OR jane"}}}`)), ShouldResemble, []string{"2", "3"})
+			So(ids(search(`{"query":{"query_string":{"query":"host:web* NOT status:200"}}}`)), ShouldResemble, []string{"2", "3"})
+			So(ids(search(`{"query":{"filtered":{"filter":{"and":[{"term":{"host":"web1"}},{"range":{"took":{"from":20}}}]}}}}`)), ShouldResemble, []string{"3", "4"})
+
+			Convey("sorting and paging", func() {
+				rsp := search(`{"sort":[{"took":{"order":"desc"}}],"size":2,"from":1}`)
+				So(ids(rsp), ShouldResemble, []string{"2", "3"})
+				So(get(rsp, "hits.total.value"), ShouldEqual, 4)
+				So(ids(search(`{"sort":["host",{"status":"desc"}]}`)), ShouldResemble, []string{"3", "1", "4", "2"})
+				So(ids(search(`{"sort":{"user.name":"asc"}}`)), ShouldResemble, []string{"3", "1", "2", "4"})
+				_, rsp = do(s, "GET", "/logs/_search?q=host:web1&size=1&sort=took:desc", "")
+				So(ids(rsp), ShouldResemble, []string{"4"})
+			})
+
+			Convey("aggregations", func() {
+				rsp := search(`{"size":0,"aggs":{"hosts":{"terms":{"field":"host"},"aggs":{"took":{"avg":{"field":"took"}},"stats":{"stats":{"field":"took"}}}}}}`)
+				So(ids(rsp), ShouldBeEmpty)
+				buckets := values(rsp, "aggregations.hosts.buckets")
+				So(len(buckets), ShouldEqual, 2)
+				So(get(buckets[0].(map[string]interface{}), "key"), ShouldEqual, "web1")
+				So(get(buckets[0].(map[string]interface{}), "doc_count"), ShouldEqual, 3)
+				So(get(buckets[0].(map[string]interface{}), "took.value"), ShouldAlmostEqual, 70.0/3)
+				So(get(buckets[0].(map[string]interface{}), "stats.max"), ShouldEqual, 40)
+				So(get(buckets[1].(map[string]interface{}), "key"), ShouldEqual, "web2")
+			})
+
+			Convey("facets", func() {
+				rsp := search(`{"facets":{"hosts":{"terms":{"field":"host","size":1}}}}`)
+				So(get(rsp, "facets.hosts.total"), ShouldEqual, 4)
+				So(get(rsp, "facets.hosts.other"), ShouldEqual, 1)
+				So(values(rsp, "facets.hosts.terms"), ShouldResemble, []interface{}{map[string]interface{}{"term": "web1", "count": 3.0}})
+			})
+
+			Convey("count", func() {
+				_, rsp := do(s, "POST", "/log*/_count", `{"query":{"term":{"status":200}}}`)
+				So(rsp["count"], ShouldEqual, 2)
+			})
+		})
+
+		Convey("delete by query", func() {
+			s.Refresh()
+			status, rsp := do(s, "POST", "/logs/_delete_by_query?refresh", `{"query":{"term":{"host":"web1"}}}`)
+			So(status, ShouldEqual, 200)
+			So(rsp["deleted"], ShouldEqual, 3)
+			So(s.Count("logs"), ShouldEqual, 1)
+		})
+
+		Convey("single documents", func() {
+			status, rsp := do(s, "PUT", "/logs/_doc/9?op_type=create", `{"host":"web9"}`)
+			So(status, ShouldEqual, 201)
+			So(rsp["_seq_no"], ShouldEqual, 4)
+			status, _ = do(s, "POST", "/logs/_update/9?if_seq_no=3&if_primary_term=1", `{"doc":{"status":200}}`)
+			So(status, ShouldEqual, 409)
+			status, rsp = do(s, "POST", "/logs/_update/9?if_seq_no=4&if_primary_term=1", `{"doc":{"status":200}}`)
+			So(status, ShouldEqual, 200)
+			So(rsp["_version"], ShouldEqual, 2)
+			So(string(s.Source("logs", "9")), ShouldEqual, `{"host":"web9","status":200}`)
+			status, _ = do(s, "DELETE", "/logs/_doc/9", "")
+			So(status, ShouldEqual, 200)
+			status, _ = do(s, "HEAD", "/logs/_doc/9", "")
+			So(status, ShouldEqual, 404)
+		})
+
+		Convey("records requests and deletes indices", func() {
+			So(s.Requests()[0], ShouldEqual, "GET /logs/_search")
+			So(s.Indices(), ShouldResemble, []string{"logs"})
+			status, _ := do(s, "DELETE", "/logs", "")
+			So(status, ShouldEqual, 200)
+			So(s.Indices(), ShouldBeEmpty)
+			s.Reset()
+			So(s.Requests(), ShouldBeEmpty)
+		})
+
+		Convey("aliases and templates", func() {
+			status, _ := do(s, "PUT", "/_index_template/metrics", `{"index_patterns":["metrics-*"],"template":{"settings":{"number_of_shards":1},"aliases":{"metrics":{}}}}`)
+			So(status, ShouldEqual, 200)
+			status, rsp := do(s, "GET", "/_index_template/metrics", "")
+			So(status, ShouldEqual, 200)
+			So(get(rsp, "index_templates.name"), ShouldEqual, "metrics")
+			status, _ = do(s, "GET", "/_index_template/other", "")
+			So(status, ShouldEqual, 404)
+
+			status, _ = do(s, "PUT", "/metrics-a/_doc/1", `{"value":1}`)
+			So(status, ShouldEqual, 201)
+			status, rsp = do(s, "GET", "/_alias/metrics", "")
+			So(status, ShouldEqual, 200)
+			So(rsp["metrics-a"], ShouldNotBeNil)
+			status, _ = do(s, "GET", "/_alias/missing", "")
+			So(status, ShouldEqual, 404)
+
+			status, _ = do(s, "POST", "/_aliases", `{"actions":[{"add":{"index":"logs","alias":"metrics","is_write_index":true}}]}`)
+			So(status, ShouldEqual, 200)
+			status, _ = do(s, "PUT", "/metrics/_doc/9", `{"value":2}`)
+			So(status, ShouldEqual, 201)
+			So(s.Source("logs", "9"), ShouldNotBeNil)
+			status, rsp = do(s, "GET", "/metrics/_count", "")
+			So(rsp["count"], ShouldEqual, 0)
+			s.Refresh()
+			status, rsp = do(s, "GET", "/metrics/_count", "")
+			So(rsp["count"], ShouldEqual, 6)
+
+			status, _ = do(s, "POST", "/_aliases", `{"actions":[{"remove":{"index":"logs","alias":"other"}},{"remove":{"index":"logs","alias":"metrics"}}]}`)
+			So(status, ShouldEqual, 404)
+			status, rsp = do(s, "GET", "/_alias/metrics", "")
+			So(rsp["logs"], ShouldNotBeNil)
+		})
+
+		Convey("open, close, force merge and cat indices", func() {
+			status, _ := do(s, "POST", "/logs/_close", "")
+			So(status, ShouldEqual, 200)
+			status, rsp := do(s, "GET", "/logs/_search", "")
+			So(status, ShouldEqual, 400)
+			So(get(rsp, "error.type"), ShouldEqual, "index_closed_exception")
+			status, _ = do(s, "POST", "/logs/_forcemerge", "")
+			So(status, ShouldEqual, 400)
+
+			req, _ := http.NewRequest("GET", s.URL+"/_cat/indices/l*?format=json&h=index,status,docs.count", nil)
+			httpRsp, e := http.DefaultClient.Do(req)
+			So(e, ShouldBeNil)
+			rows := []map[string]interface{}{}
+			So(json.NewDecoder(httpRsp.Body).Decode(&rows), ShouldBeNil)
+			httpRsp.Body.Close()
+			So(rows, ShouldResemble, []map[string]interface{}{{"index": "logs", "status": "close", "docs.count": nil}})
+
+			status, _ = do(s, "POST", "/logs/_open", "")
+			So(status, ShouldEqual, 200)
+			status, _ = do(s, "POST", "/logs/_forcemerge?max_num_segments=1", "")
+			So(status, ShouldEqual, 200)
+			status, _ = do(s, "POST", "/other/_open", "")
+			So(status, ShouldEqual, 404)
+			So(s.Indices(), ShouldResemble, []string{"logs"})
+		})
+
+		Convey("canned responses and failures", func() {
+			s.Respond("GET", "/_nodes/stats", 200, `{"nodes":{}}`)
+			s.Respond("POST", "/_reindex", 500, `{"error":"first"}`)
+			s.Respond("POST", "/_reindex", 200, `{"created":1}`)
+			status, rsp := do(s, "GET", "/_nodes/stats", "")
+			So(status, ShouldEqual, 200)
+			So(rsp["nodes"], ShouldResemble, map[string]interface{}{})
+			status, _ = do(s, "POST", "/_reindex", `{"source":{}}`)
+			So(status, ShouldEqual, 500)
+			for i := 0; i < 2; i++ {
+				status, rsp = do(s, "POST", "/_reindex", "")
+				So(status, ShouldEqual, 200)
+				So(rsp["created"], ShouldEqual, 1)
+			}
+			So(s.Bodies()[len(s.Bodies())-3], ShouldEqual, `{"source":{}}`)
+
+			s.FailBulkItems(func(action, index, id string) int {
+				if id == "8" {
+					return 429
+				}
+				return 0
+			})
+			status, rsp = do(s, "POST", "/logs/_bulk", "{\"index\":{\"_id\":\"7\"}}\n{}\n{\"index\":{\"_id\":\"8\"}}\n{}\n")
+			So(status, ShouldEqual, 200)
+			So(rsp["errors"], ShouldEqual, true)
+			items := rsp["items"].([]interface{})
+			So(get(items[0].(map[string]interface{}), "index.status"), ShouldEqual, 201)
+			So(get(items[1].(map[string]interface{}), "index.error.type"), ShouldEqual, "es_rejected_execution_exception")
+			So(s.Source("logs", "8"), ShouldBeNil)
+		})
+	})
+}
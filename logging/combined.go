@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CombinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// CombinedLine is a line in the combined log format used by nginx and Apache, e.g.
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.0" 200 2326 "http://ref/" "Mozilla/4.08"
+//
+// Lines in the common log format (without referer and user agent) are parsed too.
+type CombinedLine struct {
+	*SyslogLine
+	RemoteAddr  string
+	RemoteUser  string
+	RequestTime time.Time
+	Method      string
+	Uri         string
+	Protocol    string
+	Status      string
+	Length      int
+	Referer     string
+	UserAgent   string
+}
+
+var combinedRegexp = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// Parse parses the payload of the line (i.e. the syslog message or a line of an access log file).
+func (line *CombinedLine) Parse(raw string) (e error) {
+	m := combinedRegexp.FindStringSubmatch(strings.TrimSpace(raw))
+	if m == nil {
+		return fmt.Errorf("line is not in combined log format")
+	}
+	line.RemoteAddr = m[1]
+	if m[2] != "-" {
+		line.RemoteUser = m[2]
+	}
+	line.RequestTime, e = time.Parse(CombinedTimeLayout, m[3])
+	if e != nil {
+		return e
+	}
+	if request := strings.Fields(unescapeQuoted(m[4])); len(request) >= 2 {
+		line.Method, line.Uri = request[0], request[1]
+		if len(request) > 2 {
+			line.Protocol = request[2]
+		}
+	}
+	line.Status = m[5]
+	if m[6] != "-" {
+		line.Length, _ = strconv.Atoi(m[6])
+	}
+	if ref := unescapeQuoted(m[7]); ref != "-" {
+		line.Referer = ref
+	}
+	if ua := unescapeQuoted(m[8]); ua != "-" {
+		line.UserAgent = ua
+	}
+	return nil
+}
+
+func unescapeQuoted(s string) string {
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
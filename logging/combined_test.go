@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCombinedLine(t *testing.T) {
+	Convey("Parse combined log format", t, func() {
+		for raw, expected := range map[string]*CombinedLine{
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`: {
+				RemoteAddr: "127.0.0.1", RemoteUser: "frank", RequestTime: time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+				Method: "GET", Uri: "/apache_pb.gif", Protocol: "HTTP/1.0", Status: "200", Length: 2326,
+				Referer: "http://www.example.com/start.html", UserAgent: "Mozilla/4.08 [en] (Win98; I ;Nav)",
+			},
+			`10.0.0.1 - - [16/Oct/2026:10:00:00 +0000] "POST /api/login?next=%2F HTTP/1.1" 302 - "-" "curl/8.0 \"beta\""`: {
+				RemoteAddr: "10.0.0.1", RequestTime: time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+				Method: "POST", Uri: "/api/login?next=%2F", Protocol: "HTTP/1.1", Status: "302", UserAgent: `curl/8.0 "beta"`,
+			},
+			`::1 - - [16/Oct/2026:10:00:00 +0200] "-" 400 0`: {
+				RemoteAddr: "::1", RequestTime: time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC), Status: "400",
+			},
+		} {
+			line := &CombinedLine{}
+			So(line.Parse(raw), ShouldBeNil)
+			So(line.RequestTime.Equal(expected.RequestTime), ShouldBeTrue)
+			line.RequestTime = expected.RequestTime
+			So(line, ShouldResemble, expected)
+		}
+		for _, raw := range []string{``, `127.0.0.1 - - [invalid] "GET / HTTP/1.0" 200 1`, `GET / HTTP/1.0`} {
+			So((&CombinedLine{}).Parse(raw), ShouldNotBeNil)
+		}
+	})
+
+	Convey("Parse combined log format from syslog", t, func() {
+		registry := NewRegistry()
+		registry.Register("apache2", CombinedParser)
+		line, e := registry.Parse(`2026-10-16T10:00:00+00:00 web1 apache2[1]: 10.0.0.1 - - [16/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1" 200 512 "-" "curl/8.0"`)
+		So(e, ShouldBeNil)
+		combined := line.(*CombinedLine)
+		So(combined.Host, ShouldEqual, "web1")
+		So(combined.Uri, ShouldEqual, "/")
+		So(combined.Length, ShouldEqual, 512)
+	})
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DockerLine is a line written by the json-file logging driver of Docker, e.g.
+//
+//	{"log":"GET / 200\n","stream":"stdout","time":"2026-10-16T10:00:00.123456789Z"}
+type DockerLine struct {
+	*SyslogLine
+	Log       string
+	Stream    string // stdout or stderr
+	Timestamp time.Time
+	Attrs     map[string]string `json:",omitempty"` // set with --log-opt labels or env
+}
+
+// Parse parses a line of a json-file log or the payload of a syslog line. The trailing newline of the log is
+// removed.
+func (line *DockerLine) Parse(raw string) error {
+	var entry struct {
+		Log    *string           `json:"log"`
+		Stream string            `json:"stream"`
+		Time   time.Time         `json:"time"`
+		Attrs  map[string]string `json:"attrs"`
+	}
+	if e := json.Unmarshal([]byte(strings.TrimSpace(raw)), &entry); e != nil {
+		return e
+	}
+	if entry.Log == nil {
+		return fmt.Errorf("no log in docker line")
+	}
+	line.Log = strings.TrimSuffix(strings.TrimSuffix(*entry.Log, "\n"), "\r")
+	line.Stream, line.Timestamp, line.Attrs = entry.Stream, entry.Time, entry.Attrs
+	return nil
+}
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerLine(t *testing.T) {
+	Convey("Parse docker json-file line", t, func() {
+		for raw, expected := range map[string]*DockerLine{
+			`{"log":"GET / 200\n","stream":"stdout","time":"2026-10-16T10:00:00.123456789Z"}`: {
+				Log: "GET / 200", Stream: "stdout", Timestamp: time.Date(2026, 10, 16, 10, 0, 0, 123456789, time.UTC),
+			},
+			`{"log":"panic: boom\r\n","stream":"stderr","time":"2026-10-16T10:00:01Z","attrs":{"service":"api"}}`: {
+				Log: "panic: boom", Stream: "stderr", Timestamp: time.Date(2026, 10, 16, 10, 0, 1, 0, time.UTC), Attrs: map[string]string{"service": "api"},
+			},
+			`{"log":"","stream":"stdout","time":"2026-10-16T10:00:02Z"}`: {
+				Stream: "stdout", Timestamp: time.Date(2026, 10, 16, 10, 0, 2, 0, time.UTC),
+			},
+		} {
+			line := &DockerLine{}
+			So(line.Parse(raw), ShouldBeNil)
+			So(line, ShouldResemble, expected)
+		}
+		for _, raw := range []string{``, `{"stream":"stdout"}`, `{"log":1}`, `GET / 200`} {
+			So((&DockerLine{}).Parse(raw), ShouldNotBeNil)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"github.com/dynport/dgtk/es"
 	"github.com/dynport/dgtk/util"
@@ -23,21 +24,36 @@ var (
 )
 
 const (
-	LogsExchange = "syslog"
-	DefaultTtl   = int32(60000)
+	LogsExchange             = "syslog"
+	DefaultTtl               = int32(60000)
+	DefaultIndexerBatchSize  = 100
+	DefaultIndexerIndexEvery = 5 * time.Second
+	DefaultRequeueDelay      = 5 * time.Second
 )
 
 func log(format string, i ...interface{}) {
 	fmt.Printf(format+"\n", i...)
 }
 
+// Indexer indexes the lines published to the LogsExchange. Deliveries are acked after the bulk request
+// containing their line succeeded, so lines are indexed at least once (ids are derived from the raw line,
+// which makes indexing the same line twice idempotent). Lines which can not be parsed or are rejected by
+// Elasticsearch are nacked without requeueing and routed to the DeadLetterExchange when it is set. Lines
+// still rejected with a retryable status (e.g. 429) after all bulk retries are requeued after RequeueDelay.
+//
+// Queue arguments can not be changed for existing queues: setting DeadLetterExchange for a queue declared
+// without it makes the declare fail with PRECONDITION_FAILED. Delete the queue or use a new QueueName then.
 type Indexer struct {
 	AMQPAddress        string
 	ElasticSearchHost  string
 	ElasticSearchIndex string
 	ElasticSearchType  string
 	QueueName          string
-	BatchSize          int
+	BatchSize          int           // defaults to DefaultIndexerBatchSize
+	IndexEvery         time.Duration // flushes incomplete batches, defaults to DefaultIndexerIndexEvery
+	Prefetch           int           // QoS prefetch count, defaults to BatchSize
+	DeadLetterExchange string        // set as x-dead-letter-exchange of the queue and declared as fanout exchange
+	RequeueDelay       time.Duration // wait before requeueing retryable failures, defaults to DefaultRequeueDelay
 	Ttl                int32
 	Debug              bool
 }
@@ -67,7 +83,7 @@ func (indexer *Indexer) NewEsIndex() *es.Index {
 		Host:      indexer.ElasticSearchHost,
 		Index:     indexer.ElasticSearchIndex,
 		Type:      indexer.ElasticSearchType,
-		BatchSize: indexer.BatchSize,
+		BatchSize: indexer.batchSize(),
 		Debug:     indexer.Debug,
 	}
 }
@@ -83,11 +99,25 @@ func (indexer *Indexer) RunWithoutReconnect() error {
 		return e
 	}
 	defer channel.Close()
+	prefetch := indexer.Prefetch
+	if prefetch == 0 {
+		prefetch = indexer.batchSize()
+	}
+	if e := channel.Qos(prefetch, 0, false); e != nil {
+		return e
+	}
 	t := amqp.Table{}
 	if indexer.Ttl == 0 {
 		indexer.Ttl = DefaultTtl
 	}
 	t["x-message-ttl"] = indexer.Ttl
+	if indexer.DeadLetterExchange != "" {
+		e = channel.ExchangeDeclare(indexer.DeadLetterExchange, "fanout", true, false, false, false, nil)
+		if e != nil {
+			return e
+		}
+		t["x-dead-letter-exchange"] = indexer.DeadLetterExchange
+	}
 	_, e = channel.QueueDeclare(indexer.QueueName, false, false, false, false, t)
 	if e != nil {
 		return e
@@ -110,22 +140,139 @@ func (indexer *Indexer) RunWithoutReconnect() error {
 	if e != nil {
 		return e
 	}
-	for del := range c {
-		raw := string(del.Body)
-		if line := parseLine(raw); line != nil {
-			ok, e := index.EnqueueBulkIndex(util.MD5String(raw), line)
-			if e != nil {
-				log(e.Error())
-			} else if ok {
-				del.Ack(true)
+	return indexer.consume(context.Background(), index, c)
+}
+
+func (indexer *Indexer) batchSize() int {
+	if indexer.BatchSize > 0 {
+		return indexer.BatchSize
+	}
+	return DefaultIndexerBatchSize
+}
+
+// consume indexes the parsed deliveries in batches until the channel is closed and flushes the last batch.
+// When a bulk request fails the deliveries of the batch are requeued and the error is returned.
+func (indexer *Indexer) consume(ctx context.Context, index *es.Index, deliveries <-chan amqp.Delivery) error {
+	every := indexer.IndexEvery
+	if every == 0 {
+		every = DefaultIndexerIndexEvery
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	batch := &deliveryBatch{}
+	for {
+		select {
+		case del, ok := <-deliveries:
+			if !ok {
+				if e := indexer.flush(ctx, index, batch); e != nil {
+					return e
+				}
+				log("finished")
+				return nil
+			}
+			raw := string(del.Body)
+			line := parseLine(raw)
+			if line == nil {
+				if e := del.Nack(false, false); e != nil {
+					return e
+				}
+				continue
+			}
+			batch.add(del, &es.Doc{Id: util.MD5String(raw), Source: line})
+			if len(batch.docs) >= indexer.batchSize() {
+				if e := indexer.flush(ctx, index, batch); e != nil {
+					return e
+				}
+			}
+		case <-ticker.C:
+			if e := indexer.flush(ctx, index, batch); e != nil {
+				return e
 			}
 		}
 	}
-	index.RunBatchIndex()
-	log("finished")
+}
+
+type deliveryBatch struct {
+	deliveries []amqp.Delivery
+	docs       []*es.Doc
+}
+
+func (batch *deliveryBatch) add(del amqp.Delivery, doc *es.Doc) {
+	batch.deliveries = append(batch.deliveries, del)
+	batch.docs = append(batch.docs, doc)
+}
+
+func (batch *deliveryBatch) reset() {
+	batch.deliveries, batch.docs = nil, nil
+}
+
+// flush indexes the batch, acks the deliveries of indexed docs and nacks the ones which failed.
+func (indexer *Indexer) flush(ctx context.Context, index *es.Index, batch *deliveryBatch) error {
+	if len(batch.docs) == 0 {
+		return nil
+	}
+	defer batch.reset()
+	failures, e := index.BulkWithRetries(ctx, batch.docs)
+	if e != nil {
+		for _, del := range batch.deliveries {
+			del.Nack(false, true)
+		}
+		return fmt.Errorf("Error indexing %d lines: %w", len(batch.docs), e)
+	}
+	if len(failures) == 0 {
+		return batch.deliveries[len(batch.deliveries)-1].Ack(true)
+	}
+	failed := map[*es.Doc]*es.BulkItemResult{}
+	for _, f := range failures {
+		failed[f.Doc] = f.Result
+		log("ERROR: indexing line failed with status %d: %v", f.Result.Status, f.Result.Error)
+	}
+	requeue := []amqp.Delivery{}
+	for i, del := range batch.deliveries {
+		if result, ok := failed[batch.docs[i]]; !ok {
+			e = del.Ack(false)
+		} else if result.Retryable() {
+			requeue = append(requeue, del)
+		} else {
+			e = del.Nack(false, false)
+		}
+		if e != nil {
+			return e
+		}
+	}
+	if len(requeue) == 0 {
+		return nil
+	}
+	// requeued deliveries are redelivered immediately, so wait for the cluster to recover first
+	if e := indexer.waitBeforeRequeue(ctx); e != nil {
+		for _, del := range requeue {
+			del.Nack(false, true)
+		}
+		return fmt.Errorf("Error waiting to requeue %d lines: %w", len(requeue), e)
+	}
+	for _, del := range requeue {
+		if e := del.Nack(false, true); e != nil {
+			return e
+		}
+	}
 	return nil
 }
 
+func (indexer *Indexer) waitBeforeRequeue(ctx context.Context) error {
+	delay := indexer.RequeueDelay
+	if delay == 0 {
+		delay = DefaultRequeueDelay
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (indexer *Indexer) CreateMappingWhenNotExists(esIndex *es.Index) error {
 	mapping, e := esIndex.Mapping()
 	if e != nil {
@@ -152,17 +299,11 @@ type Parser interface {
 	Parse(string) error
 }
 
-func parseLine(line string) Parser {
-	parsers := []Parser{
-		&NginxLine{},
-		&UnicornLine{},
-		&HAProxyLine{},
-		&SyslogLine{},
-	}
-	for _, parser := range parsers {
-		if e := parser.Parse(line); e == nil {
-			return parser
-		}
+// parseLine returns the line parsed by the DefaultRegistry or nil when it could not be parsed.
+func parseLine(raw string) interface{} {
+	line, e := DefaultRegistry.Parse(raw)
+	if e != nil {
+		return nil
 	}
-	return nil
+	return line
 }
@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/es"
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/streadway/amqp"
+)
+
+type acknowledger struct {
+	acks     []uint64
+	nacks    []uint64
+	requeued []uint64
+	lock     sync.Mutex
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.acks = append(a.acks, tag)
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	} else {
+		a.nacks = append(a.nacks, tag)
+	}
+	return nil
+}
+
+func (a *acknowledger) snapshot() (acks, nacks, requeued []uint64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.acks, a.nacks, a.requeued
+}
+
+func newDeliveries(ack *acknowledger, lines ...string) chan amqp.Delivery {
+	deliveries := make(chan amqp.Delivery, len(lines))
+	for i, line := range lines {
+		deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: uint64(i + 1), Body: []byte(line)}
+	}
+	return deliveries
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return nil
+}
+
+func TestIndexer(t *testing.T) {
+	Convey("Indexer", t, func() {
+		server := estest.NewServer()
+		defer server.Close()
+		indexer := &Indexer{ElasticSearchIndex: "logs", ElasticSearchType: "line", BatchSize: 2}
+		index := indexer.NewEsIndex()
+		index.Host, index.Port = server.Host(), server.Port()
+
+		So(indexer.CreateMappingWhenNotExists(index), ShouldBeNil)
+		So(server.Indices(), ShouldResemble, []string{"logs"})
+		mapping, e := index.Mapping()
+		So(e, ShouldBeNil)
+		So(mapping, ShouldNotBeNil)
+		So(indexer.CreateMappingWhenNotExists(index), ShouldBeNil)
+
+		ack := &acknowledger{}
+		deliveries := newDeliveries(ack, UNICORN_LINE, "not a syslog line", HAPROXY_LINE, LINE_WITH_SEVERITY)
+		close(deliveries)
+		So(indexer.consume(context.Background(), index, deliveries), ShouldBeNil)
+		acks, nacks, requeued := ack.snapshot()
+		So(acks, ShouldResemble, []uint64{3, 4})
+		So(nacks, ShouldResemble, []uint64{2})
+		So(requeued, ShouldBeNil)
+		So(server.Count("logs"), ShouldEqual, 3)
+
+		So(index.Refresh(), ShouldBeNil)
+		rsp, e := index.Search(&es.Request{Query: &es.Query{QueryString: &es.QueryString{Query: "haproxy"}}})
+		So(e, ShouldBeNil)
+		So(rsp.Hits.Total, ShouldEqual, 1)
+
+		Convey("incomplete batches are flushed after IndexEvery", func() {
+			indexer.BatchSize, indexer.IndexEvery = 10, 10*time.Millisecond
+			ack := &acknowledger{}
+			deliveries := newDeliveries(ack, SSL_LINE)
+			done := make(chan error)
+			go func() { done <- indexer.consume(context.Background(), index, deliveries) }()
+			for i := 0; i < 100; i++ {
+				if acks, _, _ := ack.snapshot(); len(acks) > 0 {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			acks, _, _ := ack.snapshot()
+			So(acks, ShouldResemble, []uint64{1})
+			close(deliveries)
+			So(<-done, ShouldBeNil)
+		})
+
+		Convey("failed bulk requests requeue the batch", func() {
+			index.Port = closedPort()
+			ack := &acknowledger{}
+			deliveries := newDeliveries(ack, UNICORN_LINE, HAPROXY_LINE, SSL_LINE)
+			e := indexer.consume(context.Background(), index, deliveries)
+			So(e, ShouldNotBeNil)
+			acks, nacks, requeued := ack.snapshot()
+			So(acks, ShouldBeNil)
+			So(nacks, ShouldBeNil)
+			So(requeued, ShouldResemble, []uint64{1, 2})
+		})
+	})
+
+	Convey("Indexer with failing bulk items", t, func() {
+		bulk := estest.NewServer()
+		defer bulk.Close()
+		bulk.Respond("POST", "/_bulk", 200, `{"errors":true,"items":[`+
+			`{"index":{"_id":"1","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}},`+
+			`{"index":{"_id":"2","status":201}},`+
+			`{"index":{"_id":"3","status":429,"error":{"type":"es_rejected_execution_exception","reason":"rejected"}}}]}`)
+		indexer := &Indexer{ElasticSearchIndex: "logs", ElasticSearchType: "line", BatchSize: 3, RequeueDelay: 50 * time.Millisecond}
+		index := indexer.NewEsIndex()
+		index.Host, index.Port, index.BulkRetries = bulk.Host(), bulk.Port(), -1
+		ack := &acknowledger{}
+		deliveries := newDeliveries(ack, UNICORN_LINE, HAPROXY_LINE, SSL_LINE)
+		close(deliveries)
+		started := time.Now()
+		So(indexer.consume(context.Background(), index, deliveries), ShouldBeNil)
+		So(time.Since(started), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		acks, nacks, requeued := ack.snapshot()
+		So(acks, ShouldResemble, []uint64{2})
+		So(nacks, ShouldResemble, []uint64{1})
+		So(requeued, ShouldResemble, []uint64{3})
+
+		Convey("retryable failures are requeued when the context is done while waiting", func() {
+			indexer.RequeueDelay = time.Hour
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			ack := &acknowledger{}
+			deliveries := newDeliveries(ack, UNICORN_LINE, HAPROXY_LINE, SSL_LINE)
+			e := indexer.consume(ctx, index, deliveries)
+			So(errors.Is(e, context.DeadlineExceeded), ShouldBeTrue)
+			_, _, requeued := ack.snapshot()
+			So(requeued, ShouldResemble, []uint64{3})
+		})
+	})
+}
+
+// closedPort returns a local port nobody listens on.
+func closedPort() int {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		panic(e)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -8,17 +8,25 @@ import (
 	"time"
 )
 
+// SyslogLine is a line in the format written by rsyslog (<time> <host> <tag>: <message>), RFC 3164 or
+// RFC 5424. The tag is set to the APP-NAME of RFC 5424 lines.
 type SyslogLine struct {
-	Raw        string
-	Time       time.Time
-	Host       string
-	Tag        string
-	Severity   string
-	Pid        int
-	fields     []string
-	parsed     bool
-	tags       map[string]interface{}
-	tagsParsed bool
+	Raw            string
+	Time           time.Time
+	Host           string
+	Tag            string
+	Severity       string
+	Pid            int
+	Facility       string                       `json:",omitempty"` // only set for lines with priority
+	Version        int                          `json:",omitempty"` // 1 for RFC 5424 lines
+	ProcId         string                       `json:",omitempty"`
+	MsgId          string                       `json:",omitempty"`
+	StructuredData map[string]map[string]string `json:",omitempty"` // params by SD-ID
+	fields         []string
+	message        string
+	parsed         bool
+	tags           map[string]interface{}
+	tagsParsed     bool
 }
 
 var validKeyRegexp = regexp.MustCompile("(?i)^[a-z]+$")
@@ -98,27 +106,68 @@ const (
 
 var TagRegexp = regexp.MustCompile("(.*?)\\[(\\d*)\\]")
 
+// Parse parses lines written by rsyslog with high precision timestamps, RFC 3164 and RFC 5424 lines. Errors
+// are returned as *ParseError.
 func (line *SyslogLine) Parse(raw string) (e error) {
 	if line.parsed {
 		return nil
 	}
 	line.Raw = raw
-	line.fields = strings.Fields(raw)
+	switch {
+	case strings.HasPrefix(raw, "<"):
+		e = line.parseWithPriority(raw)
+	case startsWithMonth(raw):
+		e = line.parseRFC3164(raw, 0)
+	default:
+		e = line.parseRsyslog(raw, 0)
+	}
+	if e != nil {
+		return e
+	}
+	line.parsed = true
+	return nil
+}
+
+func (line *SyslogLine) parseRsyslog(raw string, offset int) (e error) {
+	line.fields = strings.Fields(raw[offset:])
 	if len(line.fields) >= 3 {
 		line.Time, e = time.Parse(timeLayout, line.fields[0])
 		if e != nil {
 			line.Time, e = time.Parse(timeLayoutWithoutMicro, line.fields[0])
 			if e != nil {
-				return e
+				return &ParseError{Raw: raw, Offset: offset, Err: ErrInvalidTimestamp, Detail: e.Error()}
 			}
 		}
 		line.Host = line.fields[1]
-		line.Tag, line.Severity, line.Pid = parseTag(line.fields[2])
+		tag, severity, pid := parseTag(line.fields[2])
+		line.Tag, line.Pid = tag, pid
+		if severity != "" {
+			line.Severity = severity
+		}
 	}
-	line.parsed = true
+	line.message = messageAfterFields(raw[offset:], 3)
 	return nil
 }
 
+// messageAfterFields returns the part of raw after the first n fields.
+func messageAfterFields(raw string, n int) string {
+	rest := raw
+	for i := 0; i < n; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return ""
+		}
+		rest = rest[end:]
+	}
+	return strings.TrimLeft(rest, " \t")
+}
+
+// Message returns the part of the line after the tag (after the structured data for RFC 5424 lines).
+func (line *SyslogLine) Message() string {
+	return line.message
+}
+
 func parseTag(raw string) (tag, severity string, pid int) {
 	tagAndSeverity, pid := splitTagAndPid(raw)
 	tag, severity = splitTagAndSeverity(tagAndSeverity)
@@ -165,13 +214,17 @@ func (line *UnicornLine) Parse(raw string) error {
 	if line.Tag != "unicorn" {
 		return fmt.Errorf("tag %q not supported", line.Tag)
 	}
+	line.parseMessage()
+	return nil
+}
+
+func (line *UnicornLine) parseMessage() {
 	if len(line.fields) >= 4 {
-		parts := UUIDRegexp.FindStringSubmatch(raw)
+		parts := UUIDRegexp.FindStringSubmatch(line.Raw)
 		if len(parts) > 1 {
 			line.UUID = parts[1]
 		}
 	}
-	return nil
 }
 
 type NginxLine struct {
@@ -200,6 +253,11 @@ func (line *NginxLine) Parse(raw string) error {
 	if line.Tag != "ssl_endpoint" && line.Tag != "nginx" {
 		return fmt.Errorf("tag %q not supported", line.Tag)
 	}
+	line.parseMessage()
+	return nil
+}
+
+func (line *NginxLine) parseMessage() {
 	for _, field := range line.fields {
 		parts := strings.SplitN(field, "=", 2)
 		if len(parts) == 2 {
@@ -221,7 +279,7 @@ func (line *NginxLine) Parse(raw string) error {
 			}
 		}
 	}
-	quotes := quotesRegexp.FindAllStringSubmatch(raw, -1)
+	quotes := quotesRegexp.FindAllStringSubmatch(line.Raw, -1)
 	for _, quote := range quotes {
 		switch quote[1] {
 		case "ua":
@@ -233,7 +291,6 @@ func (line *NginxLine) Parse(raw string) error {
 		default:
 		}
 	}
-	return nil
 }
 
 type HAProxyLine struct {
@@ -269,6 +326,11 @@ func (line *HAProxyLine) Parse(raw string) error {
 	if line.Tag != "haproxy" {
 		return fmt.Errorf("tag was %s", line.Tag)
 	}
+	line.parseMessage()
+	return nil
+}
+
+func (line *HAProxyLine) parseMessage() {
 	if len(line.fields) > 16 {
 		line.Frontend = line.fields[5]
 		backend := line.fields[6]
@@ -314,5 +376,4 @@ func (line *HAProxyLine) Parse(raw string) error {
 		line.Method = line.fields[15][1:]
 		line.Uri = line.fields[16]
 	}
-	return nil
 }
@@ -1,24 +1,35 @@
 package logging
 
 import (
+	"bufio"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
+// RemoteLog streams the hourly log files of a host (written by rsyslog to /var/log/hourly). Remote hosts are
+// accessed via SSH, the local files are read when Host is empty.
 type RemoteLog struct {
 	Host          string
-	Pattern       string
+	User          string // defaults to root
+	Port          int    // defaults to 22
+	KeyFile       string // private key used in addition to the keys of the ssh agent
+	Pattern       string // Go regexp (grep basic regexp before Reader was native), only matching lines are returned
 	Tail          bool
 	Time          time.Time
-	Compress      bool
+	Compress      bool // compress uncompressed files for the transfer
 	CustomLogRoot string
 }
 
 const (
 	DEFAULT_LOG_ROOT = "/var/log/hourly"
 	HOURLY_PATTERN   = "2006/01/02/2006-01-02T15.log"
+	DefaultUser      = "root"
 )
 
 func NewRemoteLogFromTime(host string, t time.Time, pattern string) *RemoteLog {
@@ -42,7 +53,7 @@ func (rl *RemoteLog) Current() string {
 
 func (rl *RemoteLog) Path() string {
 	if !rl.Time.IsZero() {
-		return rl.Time.UTC().Format(rl.LogRoot() + "/" + HOURLY_PATTERN)
+		return rl.LogRoot() + "/" + rl.Time.UTC().Format(HOURLY_PATTERN)
 	}
 	return rl.Current()
 }
@@ -51,6 +62,10 @@ func (rl *RemoteLog) GzipPath() string {
 	return rl.Path() + ".gz"
 }
 
+// Command returns a shell command streaming the log.
+//
+// Deprecated: Reader runs the commands itself and filters with Go regexps, GrepCmd interprets Pattern as
+// grep basic regexp.
 func (rl *RemoteLog) Command() string {
 	cmd := rl.CatCmd()
 	if rl.Pattern != "" {
@@ -62,10 +77,12 @@ func (rl *RemoteLog) Command() string {
 	return cmd
 }
 
+// Deprecated: see Command.
 func (rl *RemoteLog) GrepCmd() string {
 	return "grep " + rl.Pattern
 }
 
+// Deprecated: see Command.
 func (rl *RemoteLog) CatCmd() string {
 	if rl.Tail {
 		return "tail -n 0 -F " + rl.Current()
@@ -73,27 +90,283 @@ func (rl *RemoteLog) CatCmd() string {
 	return "{ test -e " + rl.Path() + " && cat " + rl.Path() + "; test -e " + rl.GzipPath() + " && cat " + rl.GzipPath() + " | gunzip; }"
 }
 
-func (rl *RemoteLog) Reader() (reader io.ReadCloser, e error) {
-	c := rl.Command()
-	var cmd *exec.Cmd
-	if rl.Host != "" {
-		cmd = exec.Command("ssh", "-t", "-l", "root", rl.Host, c)
-	} else {
-		cmd = exec.Command("bash", "-c", c)
+func (rl *RemoteLog) user() string {
+	if rl.User != "" {
+		return rl.User
 	}
-	reader, e = cmd.StdoutPipe()
+	return DefaultUser
+}
+
+// remoteCommand is a command streaming a file, gzipped output is decompressed locally.
+type remoteCommand struct {
+	Cmd  string
+	Gzip bool
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// catIfExists streams the file when it exists. Commands are exec'ed so killing the process stops reading.
+func catIfExists(path string, compress bool) string {
+	cmd := "cat"
+	if compress {
+		cmd = "gzip -c"
+	}
+	return "test ! -e " + shellQuote(path) + " || exec " + cmd + " " + shellQuote(path)
+}
+
+// commands returns the commands to read the log. The plain file is read before its gzipped sibling.
+func (rl *RemoteLog) commands() []*remoteCommand {
+	if rl.Tail {
+		return []*remoteCommand{{Cmd: "exec tail -n 0 -F " + shellQuote(rl.Current())}}
+	}
+	return []*remoteCommand{
+		{Cmd: catIfExists(rl.Path(), rl.Compress), Gzip: rl.Compress},
+		{Cmd: catIfExists(rl.GzipPath(), false), Gzip: true},
+	}
+}
+
+// Reader returns the lines of the log matching Pattern. Close stops the running command (e.g. tail -F) and
+// closes the SSH connection.
+func (rl *RemoteLog) Reader() (io.ReadCloser, error) {
+	filter, e := rl.filter()
 	if e != nil {
 		return nil, e
 	}
-	e = cmd.Start()
+	r, e := rl.runner()
 	if e != nil {
 		return nil, e
 	}
-	if rl.Compress {
-		reader, e = gzip.NewReader(reader)
+	return newRemoteLogReader(r, rl.commands(), filter), nil
+}
+
+func (rl *RemoteLog) filter() (*regexp.Regexp, error) {
+	if rl.Pattern == "" {
+		return nil, nil
+	}
+	filter, e := regexp.Compile(rl.Pattern)
+	if e != nil {
+		return nil, fmt.Errorf("Error compiling pattern %q: %w", rl.Pattern, e)
+	}
+	return filter, nil
+}
+
+// runner connects to the host or returns a runner for local commands when Host is empty.
+func (rl *RemoteLog) runner() (runner, error) {
+	if rl.Host == "" {
+		return &localRunner{}, nil
+	}
+	r, e := dialSSH(rl.Host, rl.Port, rl.user(), rl.KeyFile)
+	if e != nil {
+		return nil, fmt.Errorf("Error connecting to %s: %w", rl.Host, e)
+	}
+	return r, nil
+}
+
+func newRemoteLogReader(r runner, commands []*remoteCommand, filter *regexp.Regexp) io.ReadCloser {
+	reader := &remoteLogReader{runner: r, commands: commands}
+	if filter == nil {
+		return reader
+	}
+	return &filterReader{ReadCloser: reader, lines: bufio.NewReader(reader), filter: filter}
+}
+
+// runner starts commands on a host.
+type runner interface {
+	Start(cmd string) (process, error)
+	Close() error
+}
+
+// process is a started command.
+type process interface {
+	Stdout() io.Reader
+	Wait() error
+	Kill() error
+}
+
+// remoteLogReader runs the commands one after the other and returns their concatenated output.
+type remoteLogReader struct {
+	runner   runner
+	commands []*remoteCommand
+
+	current process
+	reader  io.Reader
+	closed  bool
+	lock    sync.Mutex
+}
+
+func (reader *remoteLogReader) Read(b []byte) (int, error) {
+	for {
+		reader.lock.Lock()
+		if reader.closed {
+			reader.lock.Unlock()
+			return 0, io.EOF
+		}
+		if reader.current == nil {
+			if len(reader.commands) == 0 {
+				reader.lock.Unlock()
+				return 0, io.EOF
+			}
+			if e := reader.start(); e != nil {
+				reader.lock.Unlock()
+				return 0, e
+			}
+		}
+		r := reader.reader
+		reader.lock.Unlock()
+
+		n, e := r.Read(b)
+		if e != nil && reader.isClosed() {
+			return n, io.EOF
+		}
+		if e == io.EOF {
+			if e := reader.finish(); e != nil {
+				return n, e
+			}
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, e
+	}
+}
+
+// start starts the next command, the lock must be held.
+func (reader *remoteLogReader) start() (e error) {
+	cmd := reader.commands[0]
+	p, e := reader.runner.Start(cmd.Cmd)
+	if e != nil {
+		return fmt.Errorf("Error starting %q: %w", cmd.Cmd, e)
+	}
+	reader.current, reader.reader = p, p.Stdout()
+	if cmd.Gzip {
+		reader.reader = &gunzipReader{r: reader.reader}
+	}
+	return nil
+}
+
+// finish waits for the current command after its output was read.
+func (reader *remoteLogReader) finish() error {
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	if reader.current == nil {
+		return nil
+	}
+	cmd := reader.commands[0]
+	e := reader.current.Wait()
+	reader.current, reader.reader = nil, nil
+	reader.commands = reader.commands[1:]
+	if e != nil && !reader.closed {
+		return fmt.Errorf("Error running %q: %w", cmd.Cmd, e)
+	}
+	return nil
+}
+
+func (reader *remoteLogReader) isClosed() bool {
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	return reader.closed
+}
+
+// Close kills the running command and closes the connection.
+func (reader *remoteLogReader) Close() error {
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	if reader.closed {
+		return nil
+	}
+	reader.closed = true
+	if reader.current != nil {
+		reader.current.Kill()
+		reader.current.Wait()
+		reader.current = nil
+	}
+	return reader.runner.Close()
+}
+
+// gunzipReader decompresses r. Empty input (i.e. a missing file) results in empty output instead of an
+// error reading the gzip header.
+type gunzipReader struct {
+	r  io.Reader
+	gz io.Reader
+}
+
+func (reader *gunzipReader) Read(b []byte) (int, error) {
+	if reader.gz == nil {
+		buffered := bufio.NewReader(reader.r)
+		if _, e := buffered.Peek(1); e != nil {
+			return 0, e
+		}
+		gz, e := gzip.NewReader(buffered)
+		if e != nil {
+			return 0, fmt.Errorf("Error reading gzip header: %w", e)
+		}
+		reader.gz = gz
+	}
+	return reader.gz.Read(b)
+}
+
+// filterReader returns only the lines matching the filter.
+type filterReader struct {
+	io.ReadCloser
+	lines  *bufio.Reader
+	filter *regexp.Regexp
+	buf    []byte
+}
+
+func (reader *filterReader) Read(b []byte) (int, error) {
+	for len(reader.buf) == 0 {
+		line, e := reader.lines.ReadBytes('\n')
+		if len(line) > 0 && reader.filter.Match(line) {
+			reader.buf = line
+		}
 		if e != nil {
-			return nil, e
+			if len(reader.buf) > 0 {
+				break
+			}
+			return 0, e
 		}
 	}
-	return reader, nil
+	n := copy(b, reader.buf)
+	reader.buf = reader.buf[n:]
+	return n, nil
+}
+
+// localRunner runs the commands with sh on the local host.
+type localRunner struct{}
+
+func (r *localRunner) Start(cmd string) (process, error) {
+	c := exec.Command("sh", "-c", cmd)
+	stdout, e := c.StdoutPipe()
+	if e != nil {
+		return nil, e
+	}
+	if e := c.Start(); e != nil {
+		return nil, e
+	}
+	return &localProcess{cmd: c, stdout: stdout}, nil
+}
+
+func (r *localRunner) Close() error {
+	return nil
+}
+
+type localProcess struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+}
+
+func (p *localProcess) Stdout() io.Reader {
+	return p.stdout
+}
+
+func (p *localProcess) Wait() error {
+	return p.cmd.Wait()
+}
+
+func (p *localProcess) Kill() error {
+	return p.cmd.Process.Kill()
 }
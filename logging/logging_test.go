@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeLogFile(path string, compress bool, lines ...string) error {
+	if e := os.MkdirAll(filepath.Dir(path), 0755); e != nil {
+		return e
+	}
+	f, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	if !compress {
+		for _, line := range lines {
+			if _, e := f.WriteString(line + "\n"); e != nil {
+				return e
+			}
+		}
+		return nil
+	}
+	gz := gzip.NewWriter(f)
+	for _, line := range lines {
+		if _, e := gz.Write([]byte(line + "\n")); e != nil {
+			return e
+		}
+	}
+	return gz.Close()
+}
+
+func TestRemoteLog(t *testing.T) {
+	Convey("RemoteLog", t, func() {
+		root, e := ioutil.TempDir("", "it's a log root")
+		So(e, ShouldBeNil)
+		defer os.RemoveAll(root)
+		rl := &RemoteLog{CustomLogRoot: root, Time: time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC)}
+		So(rl.Path(), ShouldEqual, root+"/2026/10/16/2026-10-16T10.log")
+
+		Convey("deprecated commands", func() {
+			tail := &RemoteLog{Tail: true, Pattern: "nginx", Compress: true}
+			So(tail.Command(), ShouldEqual, "tail -n 0 -F /var/log/hourly/current | grep nginx | gzip")
+			So(rl.CatCmd(), ShouldContainSubstring, "cat "+rl.GzipPath()+" | gunzip")
+		})
+
+		Convey("reads the plain and the gzipped file", func() {
+			So(writeLogFile(rl.Path(), false, UNICORN_LINE, SSL_LINE), ShouldBeNil)
+			So(writeLogFile(rl.GzipPath(), true, HAPROXY_LINE), ShouldBeNil)
+			r, e := rl.Reader()
+			So(e, ShouldBeNil)
+			b, e := ioutil.ReadAll(r)
+			So(e, ShouldBeNil)
+			So(string(b), ShouldEqual, UNICORN_LINE+"\n"+SSL_LINE+"\n"+HAPROXY_LINE+"\n")
+			So(r.Close(), ShouldBeNil)
+		})
+
+		Convey("compresses the plain file for the transfer", func() {
+			So(writeLogFile(rl.Path(), false, UNICORN_LINE), ShouldBeNil)
+			rl.Compress = true
+			r, e := rl.Reader()
+			So(e, ShouldBeNil)
+			defer r.Close()
+			b, e := ioutil.ReadAll(r)
+			So(e, ShouldBeNil)
+			So(string(b), ShouldEqual, UNICORN_LINE+"\n")
+		})
+
+		Convey("filters with Go regexps", func() {
+			So(writeLogFile(rl.GzipPath(), true, UNICORN_LINE, SSL_LINE, HAPROXY_LINE), ShouldBeNil)
+			rl.Pattern = `status=\d{3} .*'?"Amazon`
+			r, e := rl.Reader()
+			So(e, ShouldBeNil)
+			defer r.Close()
+			b, e := ioutil.ReadAll(r)
+			So(e, ShouldBeNil)
+			So(string(b), ShouldEqual, SSL_LINE+"\n")
+
+			rl.Pattern = "(invalid"
+			_, e = rl.Reader()
+			So(e, ShouldNotBeNil)
+		})
+
+		Convey("missing files are empty", func() {
+			r, e := rl.Reader()
+			So(e, ShouldBeNil)
+			b, e := ioutil.ReadAll(r)
+			So(e, ShouldBeNil)
+			So(len(b), ShouldEqual, 0)
+		})
+
+		Convey("Close stops tailing", func() {
+			So(writeLogFile(rl.Current(), false), ShouldBeNil)
+			rl.Tail = true
+			r, e := rl.Reader()
+			So(e, ShouldBeNil)
+			lines := make(chan string)
+			go func() {
+				scanner := bufio.NewScanner(r)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+				close(lines)
+			}()
+			var line string
+			for i := 0; i < 50 && line == ""; i++ {
+				f, e := os.OpenFile(rl.Current(), os.O_APPEND|os.O_WRONLY, 0644)
+				So(e, ShouldBeNil)
+				f.WriteString(SSL_LINE + "\n")
+				f.Close()
+				select {
+				case line = <-lines:
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			So(line, ShouldEqual, SSL_LINE)
+			So(r.Close(), ShouldBeNil)
+			for range lines {
+			}
+		})
+	})
+}
@@ -0,0 +1,252 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynport/dgtk/opentsdb"
+	"github.com/dynport/dgtk/stats"
+)
+
+const (
+	DefaultMetricsInterval = time.Minute
+	DefaultMetricsDelay    = 10 * time.Second
+)
+
+var DefaultMetricsPercentiles = []int{50, 95, 99}
+
+// MetricsAggregator computes request metrics from parsed nginx and haproxy lines. Requests are counted per
+// interval (by the time of the line) and grouped by host, backend (haproxy only) and status class, e.g.
+//
+//	put nginx.requests 1476612000 12 host=web1 status=5xx
+//	put haproxy.latency.p95 1476612000 230 backend=api host=lb1 status=2xx
+//
+// Latencies are in milliseconds (total time for nginx, server response time for haproxy). The aggregator
+// implements Sink, so it can be used with a Server.
+type MetricsAggregator struct {
+	Interval    time.Duration // defaults to DefaultMetricsInterval
+	Delay       time.Duration // time to wait for late lines before an interval is flushed, defaults to DefaultMetricsDelay
+	Percentiles []int         // defaults to DefaultMetricsPercentiles
+
+	intervals map[time.Time]map[metricsGroup]*metricsCounter
+	flushed   time.Time // start of the newest flushed interval, older lines are dropped
+	lock      sync.Mutex
+}
+
+func NewMetricsAggregator(interval time.Duration) *MetricsAggregator {
+	return &MetricsAggregator{Interval: interval}
+}
+
+type metricsGroup struct {
+	Prefix  string
+	Host    string
+	Backend string
+	Status  string
+}
+
+func (group metricsGroup) tags() string {
+	tags := "host=" + tagValue(group.Host)
+	if group.Backend != "" {
+		tags = "backend=" + tagValue(group.Backend) + " " + tags
+	}
+	return tags + " status=" + group.Status
+}
+
+type metricsCounter struct {
+	requests int
+	bytes    int
+	latency  *stats.Stats
+}
+
+// tagValue replaces characters not allowed in OpenTSDB tag values.
+func tagValue(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == '/':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// statusClass returns e.g. "5xx" for "503" and "unknown" for invalid status codes (e.g. -1 for aborted
+// haproxy requests).
+func statusClass(status string) string {
+	if code, e := strconv.Atoi(status); e == nil && code >= 100 && code < 600 {
+		return status[:1] + "xx"
+	}
+	return "unknown"
+}
+
+func (aggregator *MetricsAggregator) interval() time.Duration {
+	if aggregator.Interval > 0 {
+		return aggregator.Interval
+	}
+	return DefaultMetricsInterval
+}
+
+func (aggregator *MetricsAggregator) delay() time.Duration {
+	if aggregator.Delay > 0 {
+		return aggregator.Delay
+	}
+	return DefaultMetricsDelay
+}
+
+func (aggregator *MetricsAggregator) percentiles() []int {
+	if len(aggregator.Percentiles) > 0 {
+		return aggregator.Percentiles
+	}
+	return DefaultMetricsPercentiles
+}
+
+// Add adds a line returned by a LineParser. It returns false for other lines and lines of already flushed
+// intervals.
+func (aggregator *MetricsAggregator) Add(line interface{}) bool {
+	var syslog *SyslogLine
+	var group metricsGroup
+	var length, latency int
+	switch line := line.(type) {
+	case *NginxLine:
+		syslog = line.SyslogLine
+		group = metricsGroup{Prefix: "nginx", Status: statusClass(line.Status)}
+		length, latency = line.Length, int(line.TotalTime*1000+0.5)
+	case *HAProxyLine:
+		syslog = &line.SyslogLine
+		group = metricsGroup{Prefix: "haproxy", Backend: line.Backend, Status: statusClass(line.Status)}
+		length, latency = line.Length, line.ServerResponseTime
+	default:
+		return false
+	}
+	if syslog == nil || syslog.Time.IsZero() {
+		return false
+	}
+	group.Host = syslog.Host
+	start := syslog.Time.UTC().Truncate(aggregator.interval())
+
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+	if !aggregator.flushed.IsZero() && !start.After(aggregator.flushed) {
+		return false
+	}
+	if aggregator.intervals == nil {
+		aggregator.intervals = map[time.Time]map[metricsGroup]*metricsCounter{}
+	}
+	groups := aggregator.intervals[start]
+	if groups == nil {
+		groups = map[metricsGroup]*metricsCounter{}
+		aggregator.intervals[start] = groups
+	}
+	counter := groups[group]
+	if counter == nil {
+		counter = &metricsCounter{latency: stats.NewStreaming()}
+		groups[group] = counter
+	}
+	counter.requests++
+	counter.bytes += length
+	if latency >= 0 {
+		counter.latency.Add(latency)
+	}
+	return true
+}
+
+// Handle adds the line of the message, messages of other lines are ignored.
+func (aggregator *MetricsAggregator) Handle(ctx context.Context, msg *Message) error {
+	aggregator.Add(msg.Line)
+	return nil
+}
+
+// Flush returns the values of all intervals which ended at least Delay before now. Values are ordered by
+// time, key and tags.
+func (aggregator *MetricsAggregator) Flush(now time.Time) []*opentsdb.MetricValue {
+	return aggregator.flush(func(start time.Time) bool {
+		return !start.Add(aggregator.interval() + aggregator.delay()).After(now)
+	})
+}
+
+// FlushAll returns the values of all intervals including the current one.
+func (aggregator *MetricsAggregator) FlushAll() []*opentsdb.MetricValue {
+	return aggregator.flush(func(time.Time) bool { return true })
+}
+
+func (aggregator *MetricsAggregator) flush(done func(start time.Time) bool) []*opentsdb.MetricValue {
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+	starts := []time.Time{}
+	for start := range aggregator.intervals {
+		if done(start) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	values := []*opentsdb.MetricValue{}
+	for _, start := range starts {
+		values = append(values, aggregator.values(start, aggregator.intervals[start])...)
+		delete(aggregator.intervals, start)
+		if start.After(aggregator.flushed) {
+			aggregator.flushed = start
+		}
+	}
+	return values
+}
+
+func (aggregator *MetricsAggregator) values(start time.Time, groups map[metricsGroup]*metricsCounter) []*opentsdb.MetricValue {
+	values := []*opentsdb.MetricValue{}
+	add := func(key string, value float64, tags string) {
+		values = append(values, &opentsdb.MetricValue{Key: key, Value: value, Time: start, Tags: tags})
+	}
+	for group, counter := range groups {
+		tags := group.tags()
+		add(group.Prefix+".requests", float64(counter.requests), tags)
+		add(group.Prefix+".bytes", float64(counter.bytes), tags)
+		if counter.latency.Len() == 0 {
+			continue
+		}
+		for _, perc := range aggregator.percentiles() {
+			add(fmt.Sprintf("%s.latency.p%d", group.Prefix, perc), float64(counter.latency.Perc(perc)), tags)
+		}
+		add(group.Prefix+".latency.max", float64(counter.latency.Max()), tags)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Key != values[j].Key {
+			return values[i].Key < values[j].Key
+		}
+		return values[i].Tags < values[j].Tags
+	})
+	return values
+}
+
+// Run writes the values of finished intervals as put commands to w (e.g. a connection to the telnet API of
+// OpenTSDB) every Interval. All remaining values are written when ctx is done.
+func (aggregator *MetricsAggregator) Run(ctx context.Context, w io.Writer) error {
+	ticker := time.NewTicker(aggregator.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if e := WritePuts(w, aggregator.Flush(now)); e != nil {
+				return e
+			}
+		case <-ctx.Done():
+			return WritePuts(w, aggregator.FlushAll())
+		}
+	}
+}
+
+// WritePuts writes the values as put commands, one per line.
+func WritePuts(w io.Writer, values []*opentsdb.MetricValue) error {
+	for _, value := range values {
+		if _, e := io.WriteString(w, value.Put()+"\n"); e != nil {
+			return fmt.Errorf("Error writing metrics: %w", e)
+		}
+	}
+	return nil
+}
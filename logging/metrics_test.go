@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func nginxLine(t time.Time, host, status string, total float64) *NginxLine {
+	line := &NginxLine{}
+	raw := fmt.Sprintf("%s %s nginx: 1.2.3.4 - host=example.com method=GET status=%s length=100 total=%.3f ua=\"curl\"", t.Format(timeLayout), host, status, total)
+	if e := line.Parse(raw); e != nil {
+		panic(e)
+	}
+	return line
+}
+
+func TestMetricsAggregator(t *testing.T) {
+	t0 := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	Convey("MetricsAggregator", t, func() {
+		aggregator := NewMetricsAggregator(time.Minute)
+		for i := 1; i <= 100; i++ {
+			So(aggregator.Add(nginxLine(t0.Add(time.Duration(i)*100*time.Millisecond), "web1", "200", float64(i)/1000)), ShouldBeTrue)
+		}
+		So(aggregator.Add(nginxLine(t0.Add(10*time.Second), "web1", "503", 1.5)), ShouldBeTrue)
+		So(aggregator.Add(nginxLine(t0.Add(70*time.Second), "web2", "404", 0.01)), ShouldBeTrue)
+		So(aggregator.Add(&SyslogLine{Raw: "other", Time: t0}), ShouldBeFalse)
+
+		So(aggregator.Flush(t0.Add(65*time.Second)), ShouldBeEmpty)
+
+		buf := &bytes.Buffer{}
+		So(WritePuts(buf, aggregator.Flush(t0.Add(70*time.Second))), ShouldBeNil)
+		// percentiles are approximated with 1% accuracy (96 is reported as 97)
+		So(strings.Split(strings.TrimSpace(buf.String()), "\n"), ShouldResemble, []string{
+			"put nginx.bytes 1792144800 10000 host=web1 status=2xx",
+			"put nginx.bytes 1792144800 100 host=web1 status=5xx",
+			"put nginx.latency.max 1792144800 100 host=web1 status=2xx",
+			"put nginx.latency.max 1792144800 1500 host=web1 status=5xx",
+			"put nginx.latency.p50 1792144800 51 host=web1 status=2xx",
+			"put nginx.latency.p50 1792144800 1500 host=web1 status=5xx",
+			"put nginx.latency.p95 1792144800 97 host=web1 status=2xx",
+			"put nginx.latency.p95 1792144800 1500 host=web1 status=5xx",
+			"put nginx.latency.p99 1792144800 100 host=web1 status=2xx",
+			"put nginx.latency.p99 1792144800 1500 host=web1 status=5xx",
+			"put nginx.requests 1792144800 100 host=web1 status=2xx",
+			"put nginx.requests 1792144800 1 host=web1 status=5xx",
+		})
+
+		So(aggregator.Add(nginxLine(t0.Add(30*time.Second), "web1", "200", 0.1)), ShouldBeFalse)
+
+		values := aggregator.FlushAll()
+		puts := []string{}
+		for _, value := range values {
+			puts = append(puts, value.Put())
+		}
+		So(puts, ShouldContain, "put nginx.requests 1792144860 1 host=web2 status=4xx")
+		So(aggregator.FlushAll(), ShouldBeEmpty)
+	})
+
+	Convey("haproxy lines are grouped by backend", t, func() {
+		aggregator := NewMetricsAggregator(time.Hour)
+		haproxy := &HAProxyLine{}
+		So(haproxy.Parse(HAPROXY_LINE), ShouldBeNil)
+		So(aggregator.Add(haproxy), ShouldBeTrue)
+		puts := []string{}
+		for _, value := range aggregator.FlushAll() {
+			puts = append(puts, value.Put())
+		}
+		So(puts, ShouldContain, "put haproxy.requests 1383998400 1 backend=ff host=192.168.0.6 status=2xx")
+		So(puts, ShouldContain, "put haproxy.latency.p99 1383998400 392 backend=ff host=192.168.0.6 status=2xx")
+	})
+
+	Convey("status classes and tag values", t, func() {
+		So(statusClass("200"), ShouldEqual, "2xx")
+		So(statusClass("-1"), ShouldEqual, "unknown")
+		So(statusClass("999"), ShouldEqual, "unknown")
+		So(tagValue("web 1:80"), ShouldEqual, "web_1_80")
+		So(tagValue(""), ShouldEqual, "unknown")
+	})
+
+	Convey("Run writes remaining values when the context is done", t, func() {
+		aggregator := &MetricsAggregator{Interval: time.Hour}
+		So(aggregator.Handle(context.Background(), &Message{Line: nginxLine(t0, "web1", "500", 0.2)}), ShouldBeNil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		buf := &bytes.Buffer{}
+		So(aggregator.Run(ctx, buf), ShouldBeNil)
+		So(buf.String(), ShouldContainSubstring, "put nginx.requests 1792144800 1 host=web1 status=5xx\n")
+	})
+}
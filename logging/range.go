@@ -0,0 +1,229 @@
+package logging
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// RemoteLogRange streams the lines logged between From (inclusive) and To (exclusive) from the hourly files
+// (and their gzipped siblings) of one or more hosts. The settings of the embedded RemoteLog (e.g. User,
+// Pattern or CustomLogRoot) are used for all hosts, Time and Tail are ignored.
+//
+// Ranges are limited to MaxRangeHours hours to catch mistakes like a From in the wrong year.
+type RemoteLogRange struct {
+	RemoteLog
+	Hosts []string  // defaults to RemoteLog.Host
+	From  time.Time // required
+	To    time.Time // defaults to now
+}
+
+const MaxRangeHours = 31 * 24
+
+func (r *RemoteLogRange) to() time.Time {
+	if r.To.IsZero() {
+		return time.Now()
+	}
+	return r.To
+}
+
+// Hours returns the hours of all hourly files in the range.
+func (r *RemoteLogRange) Hours() []time.Time {
+	hours := []time.Time{}
+	to := r.to()
+	for hour := r.From.UTC().Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		hours = append(hours, hour)
+	}
+	return hours
+}
+
+func (r *RemoteLogRange) validate() error {
+	to := r.to()
+	switch {
+	case r.From.IsZero():
+		return fmt.Errorf("From must be set")
+	case !r.From.Before(to):
+		return fmt.Errorf("From (%s) must be before To (%s)", r.From, to)
+	case to.Sub(r.From.Truncate(time.Hour)) > MaxRangeHours*time.Hour:
+		return fmt.Errorf("range from %s to %s exceeds the maximum of %d hours", r.From, to, MaxRangeHours)
+	}
+	return nil
+}
+
+func (r *RemoteLogRange) hosts() []string {
+	if len(r.Hosts) > 0 {
+		return r.Hosts
+	}
+	return []string{r.Host}
+}
+
+// commands returns the commands reading all hourly files of the range.
+func (r *RemoteLogRange) commands() []*remoteCommand {
+	commands := []*remoteCommand{}
+	for _, hour := range r.Hours() {
+		rl := r.RemoteLog
+		rl.Time, rl.Tail = hour, false
+		commands = append(commands, rl.commands()...)
+	}
+	return commands
+}
+
+// Reader opens the logs of all hosts and returns a reader merging their lines by timestamp. Lines of the
+// same host are expected to be ordered by time.
+func (r *RemoteLogRange) Reader() (*RangeReader, error) {
+	if e := r.validate(); e != nil {
+		return nil, e
+	}
+	filter, e := r.filter()
+	if e != nil {
+		return nil, e
+	}
+	commands := r.commands()
+	reader := &RangeReader{from: r.From, to: r.to()}
+	for i, host := range r.hosts() {
+		rl := r.RemoteLog
+		rl.Host = host
+		runner, e := rl.runner()
+		if e != nil {
+			reader.Close()
+			return nil, e
+		}
+		source := &rangeSource{index: i, reader: newRemoteLogReader(runner, commands, filter)}
+		source.lines = bufio.NewReader(source.reader)
+		reader.sources = append(reader.sources, source)
+	}
+	return reader, nil
+}
+
+// RangeReader returns the lines of several hosts ordered by their timestamp.
+type RangeReader struct {
+	from    time.Time
+	to      time.Time
+	sources []*rangeSource
+	heap    rangeHeap
+	started bool
+	buf     []byte
+}
+
+// rangeSource reads the lines of one host.
+type rangeSource struct {
+	index   int
+	reader  io.ReadCloser
+	lines   *bufio.Reader
+	current *SyslogLine
+	last    time.Time
+}
+
+// next reads the next line in the range. Lines which can not be parsed (e.g. continuation lines of multi
+// line messages) get the time of the previous line.
+func (source *rangeSource) next(from, to time.Time) error {
+	for {
+		raw, e := source.lines.ReadString('\n')
+		if raw == "" && e != nil {
+			return e
+		}
+		raw = strings.TrimRight(raw, "\r\n")
+		line := &SyslogLine{}
+		if line.Parse(raw) != nil || line.Time.IsZero() {
+			line = &SyslogLine{Raw: raw, Time: source.last}
+		}
+		source.last = line.Time
+		if !line.Time.Before(from) && line.Time.Before(to) {
+			source.current = line
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+	}
+}
+
+type rangeHeap []*rangeSource
+
+func (h rangeHeap) Len() int {
+	return len(h)
+}
+
+func (h rangeHeap) Less(i, j int) bool {
+	if h[i].current.Time.Equal(h[j].current.Time) {
+		return h[i].index < h[j].index
+	}
+	return h[i].current.Time.Before(h[j].current.Time)
+}
+
+func (h rangeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *rangeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*rangeSource))
+}
+
+func (h *rangeHeap) Pop() interface{} {
+	old := *h
+	source := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return source
+}
+
+// Next returns the next line of all hosts. It returns io.EOF after the last line.
+func (reader *RangeReader) Next() (*SyslogLine, error) {
+	if !reader.started {
+		reader.started = true
+		for _, source := range reader.sources {
+			if e := reader.advance(source); e != nil {
+				return nil, e
+			}
+		}
+	}
+	if len(reader.heap) == 0 {
+		return nil, io.EOF
+	}
+	source := heap.Pop(&reader.heap).(*rangeSource)
+	line := source.current
+	if e := reader.advance(source); e != nil {
+		return nil, e
+	}
+	return line, nil
+}
+
+// advance reads the next line of the source and pushes it to the heap unless the source is exhausted.
+func (reader *RangeReader) advance(source *rangeSource) error {
+	source.current = nil
+	e := source.next(reader.from, reader.to)
+	if source.current != nil {
+		heap.Push(&reader.heap, source)
+	}
+	if e != nil && e != io.EOF {
+		return e
+	}
+	return nil
+}
+
+// Read returns the raw lines returned by Next separated by newlines.
+func (reader *RangeReader) Read(b []byte) (int, error) {
+	for len(reader.buf) == 0 {
+		line, e := reader.Next()
+		if e != nil {
+			return 0, e
+		}
+		reader.buf = []byte(line.Raw + "\n")
+	}
+	n := copy(b, reader.buf)
+	reader.buf = reader.buf[n:]
+	return n, nil
+}
+
+// Close closes the readers of all hosts.
+func (reader *RangeReader) Close() error {
+	var first error
+	for _, source := range reader.sources {
+		if e := source.reader.Close(); e != nil && first == nil {
+			first = e
+		}
+	}
+	return first
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func rsyslogLine(t time.Time, host, message string) string {
+	return t.Format(timeLayout) + " " + host + " app[1]: " + message
+}
+
+func newRangeReader(from, to time.Time, hosts ...[]string) *RangeReader {
+	reader := &RangeReader{from: from, to: to}
+	for i, lines := range hosts {
+		r := ioutil.NopCloser(strings.NewReader(strings.Join(lines, "\n")))
+		reader.sources = append(reader.sources, &rangeSource{index: i, reader: r, lines: bufio.NewReader(r)})
+	}
+	return reader
+}
+
+func TestRemoteLogRange(t *testing.T) {
+	t0 := time.Date(2026, 10, 16, 9, 59, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+
+	Convey("Hours", t, func() {
+		r := &RemoteLogRange{From: at(0), To: at(62)}
+		So(r.Hours(), ShouldResemble, []time.Time{
+			time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC),
+		})
+		r.To = at(1)
+		So(len(r.Hours()), ShouldEqual, 1)
+		r.To = at(0)
+		_, e := r.Reader()
+		So(e, ShouldNotBeNil)
+
+		_, e = (&RemoteLogRange{To: at(0)}).Reader()
+		So(e, ShouldNotBeNil)
+		So(e.Error(), ShouldContainSubstring, "From must be set")
+
+		r = &RemoteLogRange{From: at(0).Add(-MaxRangeHours * time.Hour), To: at(1)}
+		_, e = r.Reader()
+		So(e, ShouldNotBeNil)
+		So(e.Error(), ShouldContainSubstring, "exceeds the maximum")
+	})
+
+	Convey("merges hosts by time", t, func() {
+		reader := newRangeReader(at(1), at(5),
+			[]string{
+				rsyslogLine(at(0), "a", "too early"),
+				rsyslogLine(at(1), "a", "first"),
+				"  continuation of first",
+				rsyslogLine(at(3), "a", "third"),
+				rsyslogLine(at(5), "a", "too late"),
+			},
+			[]string{
+				rsyslogLine(at(1), "b", "first of b"),
+				rsyslogLine(at(2), "b", "second"),
+				rsyslogLine(at(4), "b", "fourth"),
+			},
+			nil,
+		)
+		messages := []string{}
+		for {
+			line, e := reader.Next()
+			if e == io.EOF {
+				break
+			}
+			So(e, ShouldBeNil)
+			if line.Host == "" {
+				messages = append(messages, strings.TrimSpace(line.Raw))
+			} else {
+				messages = append(messages, line.Message())
+			}
+		}
+		So(messages, ShouldResemble, []string{
+			"first", "continuation of first", "first of b", "second", "third", "fourth",
+		})
+	})
+
+	Convey("reads the hourly files of the range", t, func() {
+		root, e := ioutil.TempDir("", "range")
+		So(e, ShouldBeNil)
+		defer os.RemoveAll(root)
+		hour := func(t time.Time) *RemoteLog { return &RemoteLog{CustomLogRoot: root, Time: t} }
+		So(writeLogFile(hour(at(0)).Path(), false, rsyslogLine(at(-10), "a", "before"), rsyslogLine(at(0), "a", "in range")), ShouldBeNil)
+		So(writeLogFile(hour(at(1)).GzipPath(), true, rsyslogLine(at(1), "a", "gzipped"), rsyslogLine(at(30), "a", "match")), ShouldBeNil)
+		So(writeLogFile(hour(at(61)).Path(), false, rsyslogLine(at(61), "a", "next hour")), ShouldBeNil)
+
+		r := &RemoteLogRange{RemoteLog: RemoteLog{CustomLogRoot: root}, From: at(0), To: at(61)}
+		reader, e := r.Reader()
+		So(e, ShouldBeNil)
+		b, e := ioutil.ReadAll(reader)
+		So(e, ShouldBeNil)
+		So(reader.Close(), ShouldBeNil)
+		So(string(b), ShouldEqual, strings.Join([]string{
+			rsyslogLine(at(0), "a", "in range"), rsyslogLine(at(1), "a", "gzipped"), rsyslogLine(at(30), "a", "match"),
+		}, "\n")+"\n")
+
+		r.Pattern = "match"
+		reader, e = r.Reader()
+		So(e, ShouldBeNil)
+		defer reader.Close()
+		line, e := reader.Next()
+		So(e, ShouldBeNil)
+		So(line.Time.Equal(at(30)), ShouldBeTrue)
+		_, e = reader.Next()
+		So(e, ShouldEqual, io.EOF)
+	})
+}
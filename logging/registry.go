@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"sync"
+)
+
+// LineParser parses the message of a syslog line. The syslog prefix (time, host and tag) is parsed once by
+// the Registry before the line is passed to the parser registered for its tag.
+type LineParser interface {
+	ParseLine(line *SyslogLine) (interface{}, error)
+}
+
+type LineParserFunc func(line *SyslogLine) (interface{}, error)
+
+func (f LineParserFunc) ParseLine(line *SyslogLine) (interface{}, error) {
+	return f(line)
+}
+
+var (
+	NginxParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &NginxLine{SyslogLine: syslog}
+		line.parseMessage()
+		return line, nil
+	})
+
+	UnicornParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &UnicornLine{SyslogLine: *syslog}
+		line.parseMessage()
+		return line, nil
+	})
+
+	HAProxyParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &HAProxyLine{SyslogLine: *syslog}
+		line.parseMessage()
+		return line, nil
+	})
+
+	JSONParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &JSONLine{SyslogLine: syslog}
+		if e := line.Parse(syslog.Message()); e != nil {
+			return nil, e
+		}
+		return line, nil
+	})
+
+	LogfmtParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &LogfmtLine{SyslogLine: syslog}
+		if e := line.Parse(syslog.Message()); e != nil {
+			return nil, e
+		}
+		return line, nil
+	})
+
+	CombinedParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &CombinedLine{SyslogLine: syslog}
+		if e := line.Parse(syslog.Message()); e != nil {
+			return nil, e
+		}
+		return line, nil
+	})
+
+	DockerParser = LineParserFunc(func(syslog *SyslogLine) (interface{}, error) {
+		line := &DockerLine{SyslogLine: syslog}
+		if e := line.Parse(syslog.Message()); e != nil {
+			return nil, e
+		}
+		return line, nil
+	})
+)
+
+// Registry dispatches syslog lines to the parser registered for their tag. Lines with tags without parser
+// are returned as *SyslogLine unless Fallback is set.
+type Registry struct {
+	Fallback LineParser
+
+	parsers map[string]LineParser
+	lock    sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{parsers: map[string]LineParser{}}
+}
+
+// DefaultRegistry is used by the Indexer. It contains the parsers for nginx, ssl_endpoint, unicorn and
+// haproxy lines.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.Register("nginx", NginxParser)
+	DefaultRegistry.Register("ssl_endpoint", NginxParser)
+	DefaultRegistry.Register("unicorn", UnicornParser)
+	DefaultRegistry.Register("haproxy", HAProxyParser)
+}
+
+// Register registers the parser for lines with the tag (without severity and pid, e.g. "nginx"). A nil
+// parser removes the registered one.
+func (registry *Registry) Register(tag string, parser LineParser) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if registry.parsers == nil {
+		registry.parsers = map[string]LineParser{}
+	}
+	if parser == nil {
+		delete(registry.parsers, tag)
+		return
+	}
+	registry.parsers[tag] = parser
+}
+
+// Register registers the parser for the tag in the DefaultRegistry.
+func Register(tag string, parser LineParser) {
+	DefaultRegistry.Register(tag, parser)
+}
+
+// Parser returns the parser for the tag or Fallback when no parser is registered.
+func (registry *Registry) Parser(tag string) LineParser {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	if parser, ok := registry.parsers[tag]; ok {
+		return parser
+	}
+	return registry.Fallback
+}
+
+// Parse parses the syslog prefix of raw and passes the line to the parser registered for its tag.
+func (registry *Registry) Parse(raw string) (interface{}, error) {
+	line := &SyslogLine{}
+	if e := line.Parse(raw); e != nil {
+		return nil, e
+	}
+	return registry.parseLine(line)
+}
+
+// parseLine passes the already parsed line to the parser registered for its tag.
+func (registry *Registry) parseLine(line *SyslogLine) (interface{}, error) {
+	parser := registry.Parser(line.Tag)
+	if parser == nil {
+		return line, nil
+	}
+	return parser.ParseLine(line)
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Registry", t, func() {
+		Convey("dispatches lines by tag", func() {
+			for raw, expected := range map[string]string{
+				UNICORN_LINE:       "*logging.UnicornLine",
+				SSL_LINE:           "*logging.NginxLine",
+				HAPROXY_LINE:       "*logging.HAProxyLine",
+				LINE_WITH_SEVERITY: "*logging.SyslogLine",
+			} {
+				line, e := DefaultRegistry.Parse(raw)
+				So(e, ShouldBeNil)
+				So(fmt.Sprintf("%T", line), ShouldEqual, expected)
+			}
+			line, _ := DefaultRegistry.Parse(HAPROXY_LINE)
+			So(line.(*HAProxyLine).Backend, ShouldEqual, "ff")
+			So(line.(*HAProxyLine).Host, ShouldEqual, "192.168.0.6")
+			line, _ = DefaultRegistry.Parse(SSL_LINE)
+			So(line.(*NginxLine).UserAgentName, ShouldEqual, "Amazon Route 53 Health Check Service")
+		})
+
+		Convey("custom parsers and fallback", func() {
+			registry := NewRegistry()
+			registry.Register("api", JSONParser)
+			line, e := registry.Parse(`2013-11-09T12:00:00+00:00 web1 api[12]: {"status":200,"path":"/"}`)
+			So(e, ShouldBeNil)
+			So(line.(*JSONLine).Fields["status"], ShouldEqual, 200)
+			So(line.(*JSONLine).Pid, ShouldEqual, 12)
+
+			_, e = registry.Parse(`2013-11-09T12:00:00+00:00 web1 api[12]: not json`)
+			So(e, ShouldNotBeNil)
+
+			registry.Fallback = LogfmtParser
+			line, e = registry.Parse(`2013-11-09T12:00:00+00:00 web1 worker: level=info took=1.5`)
+			So(e, ShouldBeNil)
+			So(line.(*LogfmtLine).Fields, ShouldResemble, map[string]interface{}{"level": "info", "took": 1.5})
+
+			registry.Register("api", nil)
+			line, e = registry.Parse(`2013-11-09T12:00:00+00:00 web1 api[12]: status=200`)
+			So(e, ShouldBeNil)
+			So(line.(*LogfmtLine).Fields, ShouldResemble, map[string]interface{}{"status": int64(200)})
+		})
+
+		Convey("message", func() {
+			for raw, message := range map[string]string{
+				UNICORN_LINE: `[96baf015-c074-4d92-9b5a-d7b9a20e55ca] Started GET "/_status" for 127.0.0.1 at 2013-11-09 12:00:00 +0000`,
+				`2013-11-09T12:00:00+00:00 web1 api:   {"a":1}`: `{"a":1}`,
+				`2013-11-09T12:00:00+00:00 web1 api:`:           ``,
+			} {
+				line := &SyslogLine{}
+				So(line.Parse(raw), ShouldBeNil)
+				So(line.Message(), ShouldEqual, message)
+			}
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"io"
+
+	"github.com/dynport/gossh"
+)
+
+// sshRunner runs commands on a remote host using one SSH connection.
+type sshRunner struct {
+	client *gossh.Client
+}
+
+// dialSSH connects to the host. The keys of the ssh agent and the key in keyFile (when set) are used.
+func dialSSH(host string, port int, user, keyFile string) (*sshRunner, error) {
+	client := gossh.New(host, user)
+	if port > 0 {
+		client.Port = port
+	}
+	client.PrivateKey = keyFile
+	if e := client.Connect(); e != nil {
+		return nil, e
+	}
+	return &sshRunner{client: client}, nil
+}
+
+func (r *sshRunner) Start(cmd string) (process, error) {
+	session, e := r.client.Conn.NewSession()
+	if e != nil {
+		return nil, e
+	}
+	stdout, e := session.StdoutPipe()
+	if e != nil {
+		session.Close()
+		return nil, e
+	}
+	if e := session.Start(cmd); e != nil {
+		session.Close()
+		return nil, e
+	}
+	kill := func() error {
+		// when the server ignores the signal the command gets SIGPIPE with its next write after the close
+		session.Signal("KILL")
+		return session.Close()
+	}
+	return &sshProcess{stdout: stdout, wait: session.Wait, kill: kill}, nil
+}
+
+func (r *sshRunner) Close() error {
+	r.client.Close()
+	return nil
+}
+
+type sshProcess struct {
+	stdout io.Reader
+	wait   func() error
+	kill   func() error
+}
+
+func (p *sshProcess) Stdout() io.Reader {
+	return p.stdout
+}
+
+func (p *sshProcess) Wait() error {
+	return p.wait()
+}
+
+func (p *sshProcess) Kill() error {
+	return p.kill()
+}
@@ -0,0 +1,284 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMaxMessageSize = 64 * 1024
+
+// Server receives syslog messages over UDP, TCP and TLS, parses them with the Registry and passes them to the
+// Sink. TCP streams can use octet counting ("<length> <message>") and newline framing (RFC 6587), the framing
+// is detected per message.
+type Server struct {
+	Sink           Sink
+	Registry       *Registry   // DefaultRegistry when nil
+	TLSConfig      *tls.Config // required by ListenTLS
+	MaxMessageSize int         // defaults to DefaultMaxMessageSize
+	IdleTimeout    time.Duration
+	OnError        func(raw string, e error) // called for messages which can not be parsed or handled, logged when nil
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+	lock      sync.Mutex
+	closed    bool
+}
+
+func NewServer(sink Sink) *Server {
+	return &Server{Sink: sink}
+}
+
+func (server *Server) init() error {
+	if server.closed {
+		return fmt.Errorf("server is closed")
+	}
+	if server.ctx == nil {
+		server.ctx, server.cancel = context.WithCancel(context.Background())
+		server.conns = map[net.Conn]struct{}{}
+	}
+	return nil
+}
+
+// ListenUDP receives one message per datagram on addr and returns the address it listens on (e.g. for
+// "127.0.0.1:0").
+func (server *Server) ListenUDP(addr string) (net.Addr, error) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if e := server.init(); e != nil {
+		return nil, e
+	}
+	conn, e := net.ListenPacket("udp", addr)
+	if e != nil {
+		return nil, fmt.Errorf("Error listening on udp %s: %w", addr, e)
+	}
+	server.listeners = append(server.listeners, conn)
+	server.wg.Add(1)
+	go server.serveUDP(conn)
+	return conn.LocalAddr(), nil
+}
+
+// ListenTCP accepts syslog streams on addr and returns the address it listens on.
+func (server *Server) ListenTCP(addr string) (net.Addr, error) {
+	l, e := net.Listen("tcp", addr)
+	if e != nil {
+		return nil, fmt.Errorf("Error listening on tcp %s: %w", addr, e)
+	}
+	return server.serve(l)
+}
+
+// ListenTLS accepts syslog streams over TLS (RFC 5425) on addr and returns the address it listens on.
+func (server *Server) ListenTLS(addr string) (net.Addr, error) {
+	if server.TLSConfig == nil {
+		return nil, fmt.Errorf("TLSConfig must be set to listen on tls")
+	}
+	l, e := tls.Listen("tcp", addr, server.TLSConfig)
+	if e != nil {
+		return nil, fmt.Errorf("Error listening on tls %s: %w", addr, e)
+	}
+	return server.serve(l)
+}
+
+func (server *Server) serve(l net.Listener) (net.Addr, error) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if e := server.init(); e != nil {
+		l.Close()
+		return nil, e
+	}
+	server.listeners = append(server.listeners, l)
+	server.wg.Add(1)
+	go server.accept(l)
+	return l.Addr(), nil
+}
+
+// Close stops all listeners and connections, waits for running handlers and closes the sink when it
+// implements io.Closer.
+func (server *Server) Close() error {
+	server.lock.Lock()
+	if server.closed {
+		server.lock.Unlock()
+		return nil
+	}
+	server.closed = true
+	for _, l := range server.listeners {
+		l.Close()
+	}
+	for c := range server.conns {
+		c.Close()
+	}
+	server.lock.Unlock()
+	server.wg.Wait()
+	if server.cancel != nil {
+		server.cancel()
+	}
+	if closer, ok := server.Sink.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (server *Server) maxMessageSize() int {
+	if server.MaxMessageSize > 0 {
+		return server.MaxMessageSize
+	}
+	return DefaultMaxMessageSize
+}
+
+func (server *Server) serveUDP(conn net.PacketConn) {
+	defer server.wg.Done()
+	buf := make([]byte, server.maxMessageSize())
+	for {
+		n, remote, e := conn.ReadFrom(buf)
+		if e != nil {
+			if !server.isClosed() {
+				server.handleError("", fmt.Errorf("Error reading udp: %w", e))
+			}
+			return
+		}
+		server.handle(string(buf[:n]), remote)
+	}
+}
+
+func (server *Server) accept(l net.Listener) {
+	defer server.wg.Done()
+	for {
+		c, e := l.Accept()
+		if e != nil {
+			if !server.isClosed() {
+				server.handleError("", fmt.Errorf("Error accepting connection: %w", e))
+			}
+			return
+		}
+		server.lock.Lock()
+		if server.closed {
+			server.lock.Unlock()
+			c.Close()
+			return
+		}
+		server.conns[c] = struct{}{}
+		server.wg.Add(1)
+		server.lock.Unlock()
+		go server.serveConn(c)
+	}
+}
+
+func (server *Server) serveConn(c net.Conn) {
+	defer server.wg.Done()
+	defer func() {
+		server.lock.Lock()
+		delete(server.conns, c)
+		server.lock.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	for {
+		if server.IdleTimeout > 0 {
+			c.SetReadDeadline(time.Now().Add(server.IdleTimeout))
+		}
+		raw, e := readFrame(r, server.maxMessageSize())
+		if raw != "" {
+			server.handle(raw, c.RemoteAddr())
+		}
+		if e != nil {
+			if e != io.EOF && !server.isClosed() {
+				server.handleError("", fmt.Errorf("Error reading from %s: %w", c.RemoteAddr(), e))
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads an octet counted or newline terminated message. Messages starting with digits not followed
+// by a space (e.g. rsyslog timestamps) are newline terminated.
+func readFrame(r *bufio.Reader, max int) (string, error) {
+	digits := 0
+	for {
+		b, e := r.Peek(digits + 1)
+		if e != nil {
+			if len(b) == 0 {
+				return "", e
+			}
+			break
+		}
+		c := b[digits]
+		if c >= '0' && c <= '9' && digits < 10 {
+			digits++
+			continue
+		}
+		if c == ' ' && digits > 0 {
+			n, _ := strconv.Atoi(string(b[:digits]))
+			if n > max {
+				return "", fmt.Errorf("message of %d bytes exceeds maximum of %d", n, max)
+			}
+			r.Discard(digits + 1)
+			buf := make([]byte, n)
+			if _, e := io.ReadFull(r, buf); e != nil {
+				return "", e
+			}
+			return string(buf), nil
+		}
+		break
+	}
+	var line []byte
+	for {
+		chunk, e := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > max {
+			return "", fmt.Errorf("message exceeds maximum of %d bytes", max)
+		}
+		if e == bufio.ErrBufferFull {
+			continue
+		}
+		return string(line), e
+	}
+}
+
+func (server *Server) isClosed() bool {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.closed
+}
+
+func (server *Server) handle(raw string, remote net.Addr) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+	msg := &Message{Raw: raw, Syslog: &SyslogLine{}, Remote: remote, Received: time.Now()}
+	if e := msg.Syslog.Parse(raw); e != nil {
+		server.handleError(raw, e)
+		return
+	}
+	registry := server.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	line, e := registry.parseLine(msg.Syslog)
+	if e != nil {
+		server.handleError(raw, e)
+		return
+	}
+	msg.Line = line
+	if e := server.Sink.Handle(server.ctx, msg); e != nil {
+		server.handleError(raw, e)
+	}
+}
+
+func (server *Server) handleError(raw string, e error) {
+	if server.OnError != nil {
+		server.OnError(raw, e)
+		return
+	}
+	log("ERROR: %s", e)
+}
@@ -0,0 +1,220 @@
+package logging
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dynport/dgtk/es"
+	"github.com/dynport/dgtk/estest"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/streadway/amqp"
+)
+
+type collectingSink struct {
+	messages chan *Message
+}
+
+func (sink *collectingSink) Handle(ctx context.Context, msg *Message) error {
+	sink.messages <- msg
+	return nil
+}
+
+func (sink *collectingSink) next() *Message {
+	select {
+	case msg := <-sink.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		return nil
+	}
+}
+
+type recordingPublisher struct {
+	keys   []string
+	bodies []string
+	lock   sync.Mutex
+}
+
+func (p *recordingPublisher) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.keys = append(p.keys, exchange+":"+key)
+	p.bodies = append(p.bodies, string(msg.Body))
+	return nil
+}
+
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		return tls.Certificate{}, e
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, e := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if e != nil {
+		return tls.Certificate{}, e
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+func TestServer(t *testing.T) {
+	Convey("Server", t, func() {
+		sink := &collectingSink{messages: make(chan *Message, 10)}
+		errors := make(chan error, 10)
+		server := NewServer(sink)
+		server.OnError = func(raw string, e error) { errors <- e }
+		defer server.Close()
+
+		Convey("UDP", func() {
+			addr, e := server.ListenUDP("127.0.0.1:0")
+			So(e, ShouldBeNil)
+			c, e := net.Dial("udp", addr.String())
+			So(e, ShouldBeNil)
+			defer c.Close()
+			_, e = c.Write([]byte("<134>1 2026-10-16T10:00:00Z web1 nginx - - - method=GET status=200\n"))
+			So(e, ShouldBeNil)
+			msg := sink.next()
+			So(msg, ShouldNotBeNil)
+			So(msg.Raw, ShouldEqual, "<134>1 2026-10-16T10:00:00Z web1 nginx - - - method=GET status=200")
+			So(msg.Syslog.Host, ShouldEqual, "web1")
+			So(msg.Remote, ShouldNotBeNil)
+			line, ok := msg.Line.(*NginxLine)
+			So(ok, ShouldBeTrue)
+			So(line.Status, ShouldEqual, "200")
+
+			_, e = c.Write([]byte("<999>invalid"))
+			So(e, ShouldBeNil)
+			select {
+			case e := <-errors:
+				So(e.Error(), ShouldContainSubstring, "invalid priority")
+			case <-time.After(2 * time.Second):
+				t.Fatal("expected parse error")
+			}
+		})
+
+		Convey("TCP with octet counting and newline framing", func() {
+			addr, e := server.ListenTCP("127.0.0.1:0")
+			So(e, ShouldBeNil)
+			c, e := net.Dial("tcp", addr.String())
+			So(e, ShouldBeNil)
+			first := "<13>1 - web1 app - - - first\nline"
+			fmt.Fprintf(c, "%d %s", len(first), first)
+			fmt.Fprintf(c, "%s\n", HAPROXY_LINE)
+			fmt.Fprintf(c, "<13>Oct 16 10:00:00 web2 app: last")
+			c.Close()
+			msg := sink.next()
+			So(msg, ShouldNotBeNil)
+			So(msg.Raw, ShouldEqual, first)
+			So(msg.Syslog.Message(), ShouldEqual, "first\nline")
+			msg = sink.next()
+			So(msg, ShouldNotBeNil)
+			So(msg.Line.(*HAProxyLine).Backend, ShouldEqual, "ff")
+			msg = sink.next()
+			So(msg, ShouldNotBeNil)
+			So(msg.Syslog.Host, ShouldEqual, "web2")
+		})
+
+		Convey("TLS", func() {
+			cert, e := selfSignedCertificate()
+			So(e, ShouldBeNil)
+			server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+			addr, e := server.ListenTLS("127.0.0.1:0")
+			So(e, ShouldBeNil)
+			c, e := tls.Dial("tcp", addr.String(), &tls.Config{InsecureSkipVerify: true})
+			So(e, ShouldBeNil)
+			defer c.Close()
+			fmt.Fprintf(c, "%s\n", UNICORN_LINE)
+			msg := sink.next()
+			So(msg, ShouldNotBeNil)
+			So(msg.Line.(*UnicornLine).UUID, ShouldEqual, "96baf015-c074-4d92-9b5a-d7b9a20e55ca")
+		})
+
+		Convey("messages exceeding the maximum size", func() {
+			server.MaxMessageSize = 10
+			addr, e := server.ListenTCP("127.0.0.1:0")
+			So(e, ShouldBeNil)
+			c, e := net.Dial("tcp", addr.String())
+			So(e, ShouldBeNil)
+			defer c.Close()
+			fmt.Fprintf(c, "100 <13>1 - - - - - -")
+			select {
+			case e := <-errors:
+				So(e.Error(), ShouldContainSubstring, "exceeds maximum")
+			case <-time.After(2 * time.Second):
+				t.Fatal("expected error")
+			}
+		})
+
+		Convey("closed server", func() {
+			So(server.Close(), ShouldBeNil)
+			_, e := server.ListenTCP("127.0.0.1:0")
+			So(e, ShouldNotBeNil)
+		})
+	})
+
+	Convey("AMQPSink", t, func() {
+		publisher := &recordingPublisher{}
+		sink := NewAMQPSink(LogsExchange, publisher)
+		syslog := &SyslogLine{}
+		So(syslog.Parse(SSL_LINE), ShouldBeNil)
+		So(sink.Handle(context.Background(), &Message{Raw: SSL_LINE, Syslog: syslog}), ShouldBeNil)
+		So(publisher.keys, ShouldResemble, []string{"syslog:cnc-618c0f60.ssl_endpoint"})
+		So(publisher.bodies, ShouldResemble, []string{SSL_LINE})
+	})
+
+	Convey("IndexSink", t, func() {
+		s := estest.NewServer()
+		defer s.Close()
+		sink := NewIndexSink(&es.Index{Host: s.Host(), Port: s.Port(), Index: "logs", Type: "log", BatchSize: 10})
+		handled := make(chan struct{}, 3)
+		server := NewServer(SinkFunc(func(ctx context.Context, msg *Message) error {
+			defer func() { handled <- struct{}{} }()
+			return sink.Handle(ctx, msg)
+		}))
+		addr, e := server.ListenTCP("127.0.0.1:0")
+		So(e, ShouldBeNil)
+		c, e := net.Dial("tcp", addr.String())
+		So(e, ShouldBeNil)
+		fmt.Fprintf(c, "%s\n%s\n%s\n", SSL_LINE, UNICORN_LINE, SSL_LINE)
+		c.Close()
+		for i := 0; i < 3; i++ {
+			select {
+			case <-handled:
+			case <-time.After(2 * time.Second):
+				t.Fatal("expected message to be handled")
+			}
+		}
+		So(server.Close(), ShouldBeNil)
+		So(sink.Close(), ShouldBeNil)
+		So(s.Count("logs"), ShouldEqual, 2)
+	})
+	Convey("IndexSink flushes incomplete batches after IndexEvery", t, func() {
+		s := estest.NewServer()
+		defer s.Close()
+		sink := NewIndexSink(&es.Index{Host: s.Host(), Port: s.Port(), Index: "logs", Type: "log", BatchSize: 10})
+		sink.IndexEvery = 10 * time.Millisecond
+		defer sink.Close()
+		syslog := &SyslogLine{}
+		So(syslog.Parse(SSL_LINE), ShouldBeNil)
+		So(sink.Handle(context.Background(), &Message{Raw: SSL_LINE, Syslog: syslog, Line: syslog}), ShouldBeNil)
+		for i := 0; i < 100 && s.Count("logs") == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(s.Count("logs"), ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dynport/dgtk/es"
+	"github.com/dynport/dgtk/util"
+	"github.com/streadway/amqp"
+)
+
+// Message is a syslog message received by a Server.
+type Message struct {
+	Raw      string
+	Syslog   *SyslogLine
+	Line     interface{} // line returned by the parser registered for the tag, Syslog when there is none
+	Remote   net.Addr
+	Received time.Time
+}
+
+// Sink receives the messages of a Server. Handle is called concurrently for messages of different
+// connections. Sinks implementing io.Closer are closed when the Server is closed.
+type Sink interface {
+	Handle(ctx context.Context, msg *Message) error
+}
+
+type SinkFunc func(ctx context.Context, msg *Message) error
+
+func (f SinkFunc) Handle(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// Publisher is the part of *amqp.Channel used by the AMQPSink.
+type Publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// AMQPSink publishes the raw messages to an exchange with "<host>.<tag>" as routing key, like the
+// RabbitFeeder does. The Indexer can consume them from there.
+type AMQPSink struct {
+	Exchange  string
+	Publisher Publisher
+
+	lock sync.Mutex // channels must not be used concurrently
+}
+
+func NewAMQPSink(exchange string, publisher Publisher) *AMQPSink {
+	return &AMQPSink{Exchange: exchange, Publisher: publisher}
+}
+
+func (sink *AMQPSink) Handle(ctx context.Context, msg *Message) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	e := sink.Publisher.Publish(sink.Exchange, msg.Syslog.Host+"."+msg.Syslog.Tag, false, false, amqp.Publishing{
+		Body:      []byte(msg.Raw),
+		Timestamp: msg.Received,
+	})
+	if e != nil {
+		return fmt.Errorf("Error publishing message: %w", e)
+	}
+	return nil
+}
+
+// DefaultIndexSinkIndexEvery is much shorter than es.DefaultIndexerIndexEvery so that lines of low traffic
+// servers show up in Elasticsearch soon after they were received.
+const DefaultIndexSinkIndexEvery = 5 * time.Second
+
+// IndexSink indexes the parsed lines with an es.Indexer. The md5 of the raw message is used as id, so
+// messages received twice are only indexed once.
+type IndexSink struct {
+	Indexer    *es.Indexer
+	IndexEvery time.Duration // flushes incomplete batches, defaults to DefaultIndexSinkIndexEvery
+
+	docs chan *es.Doc
+	once sync.Once
+}
+
+// NewIndexSink creates a sink for the index. The indexer is started with the first message, remaining docs
+// are indexed when the sink is closed.
+func NewIndexSink(index *es.Index) *IndexSink {
+	return &IndexSink{Indexer: &es.Indexer{Index: index, BatchSize: index.BatchSize}}
+}
+
+func (sink *IndexSink) start() {
+	sink.once.Do(func() {
+		if sink.Indexer.IndexEvery == 0 {
+			sink.Indexer.IndexEvery = sink.IndexEvery
+			if sink.Indexer.IndexEvery == 0 {
+				sink.Indexer.IndexEvery = DefaultIndexSinkIndexEvery
+			}
+		}
+		sink.docs = sink.Indexer.Start()
+	})
+}
+
+func (sink *IndexSink) Handle(ctx context.Context, msg *Message) error {
+	sink.start()
+	select {
+	case sink.docs <- &es.Doc{Id: util.MD5String(msg.Raw), Source: msg.Line}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close indexes all remaining docs and returns the first error of all bulk requests.
+func (sink *IndexSink) Close() error {
+	sink.start()
+	return sink.Indexer.Finish()
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONLine is a line with a JSON object as payload (optionally prefixed with the "@cee:" cookie).
+type JSONLine struct {
+	*SyslogLine
+	Fields map[string]interface{}
+}
+
+// Parse parses the payload of the line (i.e. the syslog message, not the full syslog line).
+func (line *JSONLine) Parse(raw string) error {
+	raw = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "@cee:"))
+	if !strings.HasPrefix(raw, "{") {
+		return fmt.Errorf("payload is not a JSON object")
+	}
+	line.Fields = map[string]interface{}{}
+	return json.Unmarshal([]byte(raw), &line.Fields)
+}
+
+// LogfmtLine is a line with logfmt payload (e.g. `level=info msg="request done" took=0.12 cached`). Numbers
+// are converted to int64 or float64, keys without value are set to true.
+type LogfmtLine struct {
+	*SyslogLine
+	Fields map[string]interface{}
+}
+
+// Parse parses the payload of the line (i.e. the syslog message, not the full syslog line).
+func (line *LogfmtLine) Parse(raw string) (e error) {
+	line.Fields, e = parseLogfmt(raw)
+	return e
+}
+
+func parseLogfmt(raw string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	i := 0
+	for {
+		for i < len(raw) && raw[i] == ' ' {
+			i++
+		}
+		if i >= len(raw) {
+			break
+		}
+		start := i
+		for i < len(raw) && raw[i] != '=' && raw[i] != ' ' && raw[i] != '"' {
+			i++
+		}
+		key := raw[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("expected key at position %d", start)
+		}
+		if i >= len(raw) || raw[i] == ' ' {
+			fields[key] = true
+			continue
+		}
+		if raw[i] == '"' {
+			return nil, fmt.Errorf("unexpected quote in key at position %d", i)
+		}
+		i++ // =
+		if i < len(raw) && raw[i] == '"' {
+			value := &strings.Builder{}
+			i++
+			closed := false
+			for ; i < len(raw); i++ {
+				if raw[i] == '\\' && i+1 < len(raw) {
+					i++
+					switch raw[i] {
+					case 'n':
+						value.WriteByte('\n')
+					case 't':
+						value.WriteByte('\t')
+					default:
+						value.WriteByte(raw[i])
+					}
+					continue
+				}
+				if raw[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(raw[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted value of %s", key)
+			}
+			fields[key] = value.String()
+			continue
+		}
+		start = i
+		for i < len(raw) && raw[i] != ' ' {
+			i++
+		}
+		fields[key] = parseTagValue(raw[start:i])
+	}
+	return fields, nil
+}
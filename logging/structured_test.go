@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONLine(t *testing.T) {
+	Convey("Parse JSON payload", t, func() {
+		for raw, fields := range map[string]map[string]interface{}{
+			`{"level":"info","took":0.5}`:             {"level": "info", "took": 0.5},
+			`@cee: {"msg":"done","user":{"id":1}}`:    {"msg": "done", "user": map[string]interface{}{"id": 1.0}},
+			` {"tags":["a","b"],"ok":true,"x":null} `: {"tags": []interface{}{"a", "b"}, "ok": true, "x": nil},
+		} {
+			line := &JSONLine{}
+			So(line.Parse(raw), ShouldBeNil)
+			So(line.Fields, ShouldResemble, fields)
+		}
+		for _, raw := range []string{``, `[1,2]`, `{"level":`, `level=info`} {
+			So((&JSONLine{}).Parse(raw), ShouldNotBeNil)
+		}
+	})
+}
+
+func TestLogfmtLine(t *testing.T) {
+	Convey("Parse logfmt payload", t, func() {
+		for raw, fields := range map[string]map[string]interface{}{
+			`level=info msg="request done" took=0.12 status=200`: {"level": "info", "msg": "request done", "took": 0.12, "status": int64(200)},
+			`cached  path=/api/users`:                            {"cached": true, "path": "/api/users"},
+			`msg="say \"hi\"\n" empty= quoted=""`:                {"msg": "say \"hi\"\n", "empty": "", "quoted": ""},
+			`ua="Mozilla/5.0 (X11; Linux)" ip=10.0.0.1`:          {"ua": "Mozilla/5.0 (X11; Linux)", "ip": "10.0.0.1"},
+			``: {},
+		} {
+			line := &LogfmtLine{}
+			So(line.Parse(raw), ShouldBeNil)
+			So(line.Fields, ShouldResemble, fields)
+		}
+		for _, raw := range []string{`msg="unterminated`, `=value`, `"key"=value`} {
+			So((&LogfmtLine{}).Parse(raw), ShouldNotBeNil)
+		}
+	})
+}
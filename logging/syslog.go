@@ -0,0 +1,266 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPriority       = errors.New("invalid priority")
+	ErrInvalidVersion        = errors.New("invalid version")
+	ErrInvalidTimestamp      = errors.New("invalid timestamp")
+	ErrInvalidStructuredData = errors.New("invalid structured data")
+	ErrTruncated             = errors.New("line truncated")
+)
+
+// ParseError is returned by SyslogLine.Parse for lines which can not be parsed. Err is one of the ErrInvalid*
+// errors or ErrTruncated and can be checked with errors.Is.
+type ParseError struct {
+	Raw    string
+	Offset int // byte offset in Raw where parsing failed
+	Err    error
+	Detail string
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("Error parsing syslog line at offset %d: %s", e.Offset, e.Err)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// RFC3164Location is used for the timestamps of RFC 3164 lines, which do not contain a timezone.
+var RFC3164Location = time.UTC
+
+// timeNow is used to infer the year of RFC 3164 timestamps.
+var timeNow = time.Now
+
+const (
+	nilValue = "-"
+	bom      = "\xef\xbb\xbf"
+)
+
+func (line *SyslogLine) parseWithPriority(raw string) error {
+	end := strings.IndexByte(raw, '>')
+	if end < 0 {
+		return &ParseError{Raw: raw, Offset: 0, Err: ErrTruncated, Detail: "missing end of priority"}
+	}
+	pri, e := strconv.Atoi(raw[1:end])
+	if e != nil || end < 2 || end > 4 || raw[1] < '0' || raw[1] > '9' || pri < 0 || pri > 191 || (end > 2 && raw[1] == '0') {
+		return &ParseError{Raw: raw, Offset: 1, Err: ErrInvalidPriority, Detail: strconv.Quote(raw[1:end])}
+	}
+	line.Facility = facilities[pri/8]
+	line.Severity = severities[pri%8]
+	offset := end + 1
+	rest := raw[offset:]
+	switch {
+	case len(rest) >= 2 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ':
+		return line.parseRFC5424(raw, offset)
+	case startsWithMonth(rest):
+		return line.parseRFC3164(raw, offset)
+	default:
+		return line.parseRsyslog(raw, offset)
+	}
+}
+
+// nextField returns the field of raw starting at offset and the offset after the separating space.
+func nextField(raw string, offset int) (field string, next int, ok bool) {
+	if offset >= len(raw) {
+		return "", offset, false
+	}
+	end := strings.IndexByte(raw[offset:], ' ')
+	if end < 0 {
+		return raw[offset:], len(raw), true
+	}
+	return raw[offset : offset+end], offset + end + 1, true
+}
+
+func nilOr(field string) string {
+	if field == nilValue {
+		return ""
+	}
+	return field
+}
+
+// parseRFC5424 parses "VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP SD [SP MSG]"
+// starting at offset (after the priority).
+func (line *SyslogLine) parseRFC5424(raw string, offset int) (e error) {
+	start := offset
+	version, offset, _ := nextField(raw, offset)
+	if line.Version, e = strconv.Atoi(version); e != nil || line.Version != 1 {
+		return &ParseError{Raw: raw, Offset: start, Err: ErrInvalidVersion, Detail: strconv.Quote(version)}
+	}
+	header := make([]string, 5)
+	for i := range header {
+		start := offset
+		var ok bool
+		if header[i], offset, ok = nextField(raw, offset); !ok || header[i] == "" {
+			return &ParseError{Raw: raw, Offset: start, Err: ErrTruncated, Detail: "missing header field"}
+		}
+		if i == 0 && header[i] != nilValue {
+			if line.Time, e = time.Parse(time.RFC3339Nano, header[i]); e != nil {
+				return &ParseError{Raw: raw, Offset: start, Err: ErrInvalidTimestamp, Detail: e.Error()}
+			}
+		}
+	}
+	line.Host = nilOr(header[1])
+	line.Tag = nilOr(header[2])
+	line.ProcId = nilOr(header[3])
+	line.MsgId = nilOr(header[4])
+	if pid, e := strconv.Atoi(line.ProcId); e == nil {
+		line.Pid = pid
+	}
+	if offset >= len(raw) {
+		return &ParseError{Raw: raw, Offset: offset, Err: ErrTruncated, Detail: "missing structured data"}
+	}
+	if raw[offset] == '-' {
+		offset++
+	} else if offset, e = line.parseStructuredData(raw, offset); e != nil {
+		return e
+	}
+	if offset < len(raw) {
+		if raw[offset] != ' ' {
+			return &ParseError{Raw: raw, Offset: offset, Err: ErrInvalidStructuredData, Detail: "expected space after structured data"}
+		}
+		offset++
+	}
+	line.message = strings.TrimPrefix(raw[offset:], bom)
+	line.fields = append([]string{header[0], header[1], header[2]}, strings.Fields(line.message)...)
+	return nil
+}
+
+// parseStructuredData parses one or more `[SD-ID PARAM-NAME="PARAM-VALUE" ...]` elements and returns the
+// offset after the last element.
+func (line *SyslogLine) parseStructuredData(raw string, offset int) (int, error) {
+	invalid := func(offset int, detail string) (int, error) {
+		return offset, &ParseError{Raw: raw, Offset: offset, Err: ErrInvalidStructuredData, Detail: detail}
+	}
+	line.StructuredData = map[string]map[string]string{}
+	for offset < len(raw) && raw[offset] == '[' {
+		offset++
+		start := offset
+		for offset < len(raw) && raw[offset] != ' ' && raw[offset] != ']' {
+			offset++
+		}
+		if offset >= len(raw) {
+			return invalid(offset, "unterminated element")
+		}
+		id := raw[start:offset]
+		if id == "" {
+			return invalid(start, "missing SD-ID")
+		}
+		params := map[string]string{}
+		line.StructuredData[id] = params
+		for raw[offset] == ' ' {
+			offset++
+			start = offset
+			for offset < len(raw) && raw[offset] != '=' && raw[offset] != ' ' && raw[offset] != ']' {
+				offset++
+			}
+			if offset+1 >= len(raw) || raw[offset] != '=' || raw[offset+1] != '"' {
+				return invalid(offset, "expected PARAM-NAME=\"PARAM-VALUE\"")
+			}
+			name := raw[start:offset]
+			offset += 2
+			value := &strings.Builder{}
+			closed := false
+			for ; offset < len(raw); offset++ {
+				c := raw[offset]
+				if c == '\\' && offset+1 < len(raw) {
+					switch next := raw[offset+1]; next {
+					case '"', '\\', ']':
+						value.WriteByte(next)
+						offset++
+						continue
+					}
+				}
+				if c == '"' {
+					closed = true
+					offset++
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed || offset >= len(raw) {
+				return invalid(offset, "unterminated value of "+name)
+			}
+			params[name] = value.String()
+		}
+		if raw[offset] != ']' {
+			return invalid(offset, "expected ]")
+		}
+		offset++
+	}
+	return offset, nil
+}
+
+func startsWithMonth(raw string) bool {
+	if len(raw) < 4 || raw[3] != ' ' {
+		return false
+	}
+	for m := time.January; m <= time.December; m++ {
+		if raw[:3] == m.String()[:3] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG: MSG" starting at offset. The year is not part of the
+// timestamp and set to the current year (or the previous one when the timestamp would be in the future).
+func (line *SyslogLine) parseRFC3164(raw string, offset int) (e error) {
+	if len(raw) < offset+len(time.Stamp) {
+		return &ParseError{Raw: raw, Offset: offset, Err: ErrTruncated, Detail: "missing timestamp"}
+	}
+	ts := raw[offset : offset+len(time.Stamp)]
+	t, e := time.ParseInLocation(time.Stamp, ts, RFC3164Location)
+	if e != nil {
+		return &ParseError{Raw: raw, Offset: offset, Err: ErrInvalidTimestamp, Detail: e.Error()}
+	}
+	now := timeNow().In(RFC3164Location)
+	line.Time = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, RFC3164Location)
+	if line.Time.After(now.AddDate(0, 0, 1)) {
+		line.Time = time.Date(now.Year()-1, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, RFC3164Location)
+	}
+	offset += len(time.Stamp)
+	rest := strings.TrimLeft(raw[offset:], " ")
+	parts := strings.SplitN(rest, " ", 3)
+	if parts[0] == "" {
+		return &ParseError{Raw: raw, Offset: offset, Err: ErrTruncated, Detail: "missing host"}
+	}
+	line.Host = parts[0]
+	tag := ""
+	switch {
+	case len(parts) > 1 && (strings.HasSuffix(parts[1], ":") || strings.Contains(parts[1], "[")):
+		tag = parts[1]
+		line.Tag, _, line.Pid = parseTag(tag)
+		if line.Pid > 0 {
+			line.ProcId = strconv.Itoa(line.Pid)
+		}
+		if len(parts) > 2 {
+			line.message = parts[2]
+		}
+	case len(parts) > 2:
+		line.message = parts[1] + " " + parts[2]
+	case len(parts) > 1:
+		line.message = parts[1]
+	}
+	line.fields = append([]string{strings.Replace(ts, " ", "_", -1), line.Host, tag}, strings.Fields(line.message)...)
+	return nil
+}
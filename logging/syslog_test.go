@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSyslogFormats(t *testing.T) {
+	timeNow = func() time.Time { return time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC) }
+	defer func() { timeNow = time.Now }()
+
+	Convey("Parse RFC 5424", t, func() {
+		line := &SyslogLine{}
+		raw := `<165>1 2026-10-16T22:14:15.003+02:00 mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App \"lica\" [x\]"][examplePriority@32473 class="high"] ` + bom + `An application event`
+		So(line.Parse(raw), ShouldBeNil)
+		So(line.Facility, ShouldEqual, "local4")
+		So(line.Severity, ShouldEqual, "notice")
+		So(line.Version, ShouldEqual, 1)
+		So(line.Time.Equal(time.Date(2026, 10, 16, 20, 14, 15, 3000000, time.UTC)), ShouldBeTrue)
+		So(line.Host, ShouldEqual, "mymachine.example.com")
+		So(line.Tag, ShouldEqual, "evntslog")
+		So(line.ProcId, ShouldEqual, "1234")
+		So(line.Pid, ShouldEqual, 1234)
+		So(line.MsgId, ShouldEqual, "ID47")
+		So(line.StructuredData, ShouldResemble, map[string]map[string]string{
+			"exampleSDID@32473":     {"iut": "3", "eventSource": `App "lica" [x]`},
+			"examplePriority@32473": {"class": "high"},
+		})
+		So(line.Message(), ShouldEqual, "An application event")
+
+		line = &SyslogLine{}
+		So(line.Parse(`<34>1 - - - - - -`), ShouldBeNil)
+		So(line.Facility, ShouldEqual, "auth")
+		So(line.Severity, ShouldEqual, "crit")
+		So(line.Time.IsZero(), ShouldBeTrue)
+		So(line.Host, ShouldEqual, "")
+		So(line.Tag, ShouldEqual, "")
+		So(line.StructuredData, ShouldBeNil)
+		So(line.Message(), ShouldEqual, "")
+	})
+
+	Convey("Parse RFC 3164", t, func() {
+		for raw, expected := range map[string]*SyslogLine{
+			`<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`: {
+				Facility: "auth", Severity: "crit", Time: time.Date(2025, 10, 11, 22, 14, 15, 0, time.UTC),
+				Host: "mymachine", Tag: "su", message: "'su root' failed for lonvick on /dev/pts/8",
+			},
+			`<13>Jan  9 08:00:01 web1 CRON[4711]: (root) CMD (run-parts)`: {
+				Facility: "user", Severity: "notice", Time: time.Date(2026, 1, 9, 8, 0, 1, 0, time.UTC),
+				Host: "web1", Tag: "CRON", Pid: 4711, ProcId: "4711", message: "(root) CMD (run-parts)",
+			},
+			`Jan 10 12:00:00 web1 no tag here`: {
+				Time: time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC), Host: "web1", message: "no tag here",
+			},
+		} {
+			line := &SyslogLine{}
+			So(line.Parse(raw), ShouldBeNil)
+			So(line.Time.Equal(expected.Time), ShouldBeTrue)
+			So(line.Facility, ShouldEqual, expected.Facility)
+			So(line.Severity, ShouldEqual, expected.Severity)
+			So(line.Host, ShouldEqual, expected.Host)
+			So(line.Tag, ShouldEqual, expected.Tag)
+			So(line.Pid, ShouldEqual, expected.Pid)
+			So(line.ProcId, ShouldEqual, expected.ProcId)
+			So(line.Message(), ShouldEqual, expected.message)
+		}
+	})
+
+	Convey("Parse rsyslog lines with priority", t, func() {
+		line := &SyslogLine{}
+		So(line.Parse(`<14>`+LINE_WITH_SEVERITY), ShouldBeNil)
+		So(line.Facility, ShouldEqual, "user")
+		So(line.Severity, ShouldEqual, "notice")
+		So(line.Tag, ShouldEqual, "metrix")
+
+		line = &SyslogLine{}
+		So(line.Parse(`<14>2013-11-09T12:00:00+00:00 host app: message`), ShouldBeNil)
+		So(line.Severity, ShouldEqual, "info")
+		So(line.Message(), ShouldEqual, "message")
+	})
+
+	Convey("Parse HAProxy lines with RFC 3164 prefix", t, func() {
+		line := &HAProxyLine{}
+		So(line.Parse(`<134>Jan  9 11:59:59 192.168.0.6 haproxy[23201]: 192.168.0.37:54273 [09/Nov/2013:11:59:59.676] in-ff ff/cnc-a6ce4d77:ecb880d6f772:c4093e8b1754 1/2/3/392/395 200 74674 - - ---- 2/4/6/7/8 9/7 "GET /api HTTP/1.0"`), ShouldBeNil)
+		So(line.Backend, ShouldEqual, "ff")
+		So(line.Status, ShouldEqual, "200")
+		So(line.Uri, ShouldEqual, "/api")
+	})
+
+	Convey("Parse errors", t, func() {
+		for raw, expected := range map[string]error{
+			`<192>1 - - - - - -`:                            ErrInvalidPriority,
+			`<abc>1 - - - - - -`:                            ErrInvalidPriority,
+			`<013>1 - - - - - -`:                            ErrInvalidPriority,
+			`<-1>1 2026-10-16T10:00:00Z host app - - - msg`: ErrInvalidPriority,
+			`<+1>1 - - - - - -`:                             ErrInvalidPriority,
+			`<-8>Oct 11 22:14:15 host su: x`:                ErrInvalidPriority,
+			`<13`:                                           ErrTruncated,
+			`<13>2 - - - - - -`:                             ErrInvalidVersion,
+			`<13>1 2026-13-01T00:00:00Z - - - - -`:          ErrInvalidTimestamp,
+			`<13>1 - host app`:                              ErrTruncated,
+			`<13>1 - host app - - [id a="b"`:                ErrInvalidStructuredData,
+			`<13>1 - host app - - [id a=b]`:                 ErrInvalidStructuredData,
+			`<13>1 - host app - - [id a="b"]message`:        ErrInvalidStructuredData,
+			`Jan 32 10:00:00 host app: message`:             ErrInvalidTimestamp,
+			`2013-11-09 12:00:00 host app: message`:         ErrInvalidTimestamp,
+		} {
+			e := (&SyslogLine{}).Parse(raw)
+			So(errors.Is(e, expected), ShouldBeTrue)
+			var parseErr *ParseError
+			So(errors.As(e, &parseErr), ShouldBeTrue)
+			So(parseErr.Raw, ShouldEqual, raw)
+		}
+	})
+}
@@ -54,3 +54,12 @@ func (mv *MetricValue) Parse(line string) error {
 func (mv *MetricValue) String() string {
 	return fmt.Sprintf("%s %s %.01f %s", mv.Time.Format("2006-01-02T15:04:05"), mv.Key, mv.Value, mv.Tags)
 }
+
+// Put returns the value as put command of the telnet API (e.g. "put nginx.requests 1383516272 18 host=web1").
+func (mv *MetricValue) Put() string {
+	put := fmt.Sprintf("put %s %d %s", mv.Key, mv.Time.Unix(), strconv.FormatFloat(mv.Value, 'f', -1, 64))
+	if mv.Tags != "" {
+		put += " " + mv.Tags
+	}
+	return put
+}
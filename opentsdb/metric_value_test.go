@@ -3,6 +3,7 @@ package opentsdb
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 var values = []struct{ Line, Expected string }{
@@ -22,3 +23,10 @@ func TestExtractTag(t *testing.T) {
 		}
 	}
 }
+
+func TestPut(t *testing.T) {
+	m := &MetricValue{Key: "nginx.requests", Time: time.Unix(1383516272, 0), Value: 18, Tags: "host=web1 status=5xx"}
+	assert.Equal(t, "put nginx.requests 1383516272 18 host=web1 status=5xx", m.Put())
+	m = &MetricValue{Key: "nginx.latency.p95", Time: time.Unix(1383516272, 0), Value: 0.25}
+	assert.Equal(t, "put nginx.latency.p95 1383516272 0.25", m.Put())
+}
@@ -1,8 +1,8 @@
 package pubsub
 
 import (
-	"fmt"
 	"reflect"
+	"sync"
 )
 
 func New() *PubSub {
@@ -11,27 +11,50 @@ func New() *PubSub {
 
 type PubSub struct {
 	subscriptions []*Subscription
+	lock          sync.RWMutex
 	Stats
 }
 
 func (pubsub *PubSub) Publish(i interface{}) error {
+	return pubsub.PublishExcept(i)
+}
+
+// PublishExcept works like Publish but does not deliver the value to the given subscriptions, e.g. to not
+// send a value received from another system back to it.
+func (pubsub *PubSub) PublishExcept(i interface{}, except ...*Subscription) error {
 	pubsub.Stats.MessageReceived()
 	var e error
 	value := reflect.ValueOf(i)
-	for _, s := range pubsub.subscriptions {
-		if !s.closed && s.Matches(value) {
-			select {
-			case s.buffer <- value:
+	for _, s := range pubsub.Subscriptions() {
+		if s.Matches(value) && !contains(except, s) {
+			dispatched, err := s.deliver(value)
+			if dispatched {
 				pubsub.Stats.MessageDispatched()
-			default:
-				e = fmt.Errorf("unable to publish to %v", s)
+			}
+			if err != nil {
+				e = err
 			}
 		}
 	}
 	return e
 }
 
+func contains(list []*Subscription, s *Subscription) bool {
+	for _, c := range list {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (pubsub *PubSub) Subscribe(i interface{}) *Subscription {
+	return pubsub.SubscribeWithOptions(i, nil)
+}
+
+// SubscribeWithOptions works like Subscribe but allows to configure the buffer size and the policy used
+// when the buffer of the subscription is full. nil options result in the defaults used by Subscribe.
+func (pubsub *PubSub) SubscribeWithOptions(i interface{}, options *SubscriptionOptions) *Subscription {
 	value := reflect.ValueOf(i)
 	type_ := reflect.TypeOf(i)
 	if type_.Kind() != reflect.Func || type_.NumIn() != 1 {
@@ -41,14 +64,38 @@ func (pubsub *PubSub) Subscribe(i interface{}) *Subscription {
 	s := &Subscription{
 		callback: value,
 		type_:    type_.In(0),
+		options:  options,
+		stats:    &pubsub.Stats,
 	}
-	pubsub.subscriptions = append(pubsub.subscriptions, s)
 	s.start()
+	pubsub.lock.Lock()
+	pubsub.subscriptions = append(pubsub.subscriptions, s)
+	pubsub.lock.Unlock()
 	return s
 }
 
+func (pubsub *PubSub) Subscriptions() []*Subscription {
+	pubsub.lock.RLock()
+	defer pubsub.lock.RUnlock()
+	return pubsub.subscriptions
+}
+
 func (pubsub *PubSub) SubscribersCount() int {
-	return len(pubsub.subscriptions)
+	return len(pubsub.Subscriptions())
+}
+
+// Snapshot returns the current counters of the PubSub and all of its subscriptions.
+func (pubsub *PubSub) Snapshot() *StatsSnapshot {
+	snapshot := &StatsSnapshot{
+		Received:   pubsub.Stats.Received(),
+		Dispatched: pubsub.Stats.Dispatched(),
+		Dropped:    pubsub.Stats.Dropped(),
+		Panicked:   pubsub.Stats.Panicked(),
+	}
+	for _, s := range pubsub.Subscriptions() {
+		snapshot.Subscriptions = append(snapshot.Subscriptions, s.Snapshot())
+	}
+	return snapshot
 }
 
 //  could not be bother to fight with locking just now
@@ -20,9 +20,6 @@ func TestPubSub(t *testing.T) {
 		Convey("Publish", func() {
 			s := &PubSub{}
 			So(s.Publish("hello"), ShouldBeNil)
-			// sleep for now because the stats update is too slow
-			// FIX: use waitFor
-			time.Sleep(100 * time.Millisecond)
 			So(s.Stats.Received(), ShouldEqual, 1)
 			So(s.Stats.Dispatched(), ShouldEqual, 0)
 
@@ -45,7 +42,6 @@ func TestPubSub(t *testing.T) {
 			})
 			So(s.Publish("hello world"), ShouldBeNil)
 			So(s.Publish(&Message{key: "hello world"}), ShouldBeNil)
-			time.Sleep(10 * time.Millisecond)
 			So(s.Stats.Received(), ShouldEqual, 2)
 			So(s.Stats.Dispatched(), ShouldEqual, 1)
 			sub.Close()
@@ -77,6 +73,144 @@ func TestPubSub(t *testing.T) {
 			}
 			So(m.String(), ShouldEqual, "hans meyer")
 		})
+
+		Convey("PublishExcept", func() {
+			s := &PubSub{}
+			c := make(chan string, 2)
+			included := s.Subscribe(func(i string) { c <- "included " + i })
+			excluded := s.Subscribe(func(i string) { c <- "excluded " + i })
+			So(s.PublishExcept("hello", excluded), ShouldBeNil)
+			included.Close()
+			excluded.Close()
+			So(s.Stats.Dispatched(), ShouldEqual, 1)
+			So(len(c), ShouldEqual, 1)
+			So(<-c, ShouldEqual, "included hello")
+		})
+	})
+}
+
+func TestStats(t *testing.T) {
+	Convey("Stats", t, func() {
+		ps := &PubSub{}
+		ps.StartCollecting()
+		sub := ps.SubscribeWithOptions(func(i int) {
+			if i < 0 {
+				panic("negative")
+			}
+		}, &SubscriptionOptions{BufferSize: 10})
+		ps.Subscribe(func(string) {})
+		for _, i := range []int{1, 2, -1} {
+			So(ps.Publish(i), ShouldBeNil)
+		}
+		So(ps.Received(), ShouldEqual, 3)
+		So(ps.Dispatched(), ShouldEqual, 3)
+		So(sub.Close(), ShouldBeNil)
+		So(ps.Panicked(), ShouldEqual, 1)
+
+		snapshot := ps.Snapshot()
+		So(snapshot.Received, ShouldEqual, 3)
+		So(snapshot.Panicked, ShouldEqual, 1)
+		So(len(snapshot.Subscriptions), ShouldEqual, 2)
+		s := snapshot.Subscriptions[0]
+		So(s.Type, ShouldEqual, "int")
+		So(s.Dispatched, ShouldEqual, 3)
+		So(s.Panicked, ShouldEqual, 1)
+		So(s.QueueDepth, ShouldEqual, 0)
+		So(s.Latency.Count, ShouldEqual, 3)
+		So(len(s.Latency.Buckets), ShouldEqual, len(latencyBuckets)+1)
+		So(snapshot.Subscriptions[1].Dispatched, ShouldEqual, 0)
+	})
+}
+
+func TestSubscriptionOptions(t *testing.T) {
+	Convey("SubscriptionOptions", t, func() {
+		ps := &PubSub{}
+		block := make(chan interface{})
+		started := make(chan interface{}, 1)
+		received := []int{}
+		subscribe := func(options *SubscriptionOptions) *Subscription {
+			return ps.SubscribeWithOptions(func(i int) {
+				started <- nil
+				<-block
+				received = append(received, i)
+			}, options)
+		}
+		// the first message is taken out of the buffer and blocks the callback
+		fill := func(count int) {
+			ps.Publish(0)
+			<-started
+			for i := 1; i <= count; i++ {
+				ps.Publish(i)
+			}
+		}
+		drain := func(s *Subscription) {
+			go func() {
+				for range started {
+				}
+			}()
+			close(block)
+			So(s.Close(), ShouldBeNil)
+			close(started)
+		}
+
+		Convey("DropNewest", func() {
+			s := subscribe(&SubscriptionOptions{BufferSize: 2})
+			fill(2)
+			So(ps.Publish(3), ShouldNotBeNil)
+			So(s.Dropped(), ShouldEqual, 1)
+			drain(s)
+			So(received, ShouldResemble, []int{0, 1, 2})
+		})
+
+		Convey("DropOldest", func() {
+			s := subscribe(&SubscriptionOptions{BufferSize: 2, Policy: DropOldest})
+			fill(2)
+			So(ps.Publish(3), ShouldNotBeNil)
+			So(s.Dropped(), ShouldEqual, 1)
+			drain(s)
+			So(received, ShouldResemble, []int{0, 2, 3})
+		})
+
+		Convey("Block with timeout", func() {
+			s := subscribe(&SubscriptionOptions{BufferSize: 2, Policy: Block, BlockTimeout: 10 * time.Millisecond})
+			fill(2)
+			So(ps.Publish(3), ShouldNotBeNil)
+			So(s.Dropped(), ShouldEqual, 1)
+			drain(s)
+			So(received, ShouldResemble, []int{0, 1, 2})
+		})
+
+		Convey("Block without timeout is released by Close", func() {
+			s := subscribe(&SubscriptionOptions{BufferSize: 2, Policy: Block})
+			fill(2)
+			published := make(chan error)
+			go func() { published <- ps.Publish(3) }()
+			closed := make(chan error)
+			go func() { closed <- s.Close() }()
+			select {
+			case e := <-published:
+				So(e, ShouldBeNil)
+			case <-time.After(time.Second):
+				t.Fatal("Publish still blocked after Close")
+			}
+			go func() {
+				for range started {
+				}
+			}()
+			close(block)
+			So(<-closed, ShouldBeNil)
+			close(started)
+			So(received, ShouldResemble, []int{0, 1, 2})
+		})
+
+		Convey("Spill", func() {
+			s := subscribe(&SubscriptionOptions{BufferSize: 2, Policy: Spill})
+			fill(5)
+			So(s.Dropped(), ShouldEqual, 0)
+			So(s.Spilled(), ShouldEqual, 3)
+			drain(s)
+			So(received, ShouldResemble, []int{0, 1, 2, 3, 4, 5})
+		})
 	})
 }
 
@@ -1,47 +1,135 @@
 package pubsub
 
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds the counters of a PubSub. All methods are safe for concurrent use and the counters are
+// updated synchronously, so they can be read right after Publish returns.
 type Stats struct {
-	received       int64
-	receivedChan   chan interface{}
-	dispatchedChan chan interface{}
-	dispatched     int64
-	collecting     bool
+	received   int64
+	dispatched int64
+	dropped    int64
+	panicked   int64
+}
+
+func (stats *Stats) Received() int64 {
+	return atomic.LoadInt64(&stats.received)
 }
 
 func (stats *Stats) Dispatched() int64 {
-	return stats.dispatched
+	return atomic.LoadInt64(&stats.dispatched)
 }
 
-func (stats *Stats) Received() int64 {
-	return stats.received
+func (stats *Stats) Dropped() int64 {
+	return atomic.LoadInt64(&stats.dropped)
+}
+
+func (stats *Stats) Panicked() int64 {
+	return atomic.LoadInt64(&stats.panicked)
+}
+
+func (stats *Stats) MessageReceived() {
+	atomic.AddInt64(&stats.received, 1)
 }
 
 func (stats *Stats) MessageDispatched() {
-	stats.StartCollecting()
-	stats.dispatchedChan <- nil
+	atomic.AddInt64(&stats.dispatched, 1)
+}
+
+func (stats *Stats) MessageDropped() {
+	atomic.AddInt64(&stats.dropped, 1)
+}
+
+func (stats *Stats) MessagePanicked() {
+	atomic.AddInt64(&stats.panicked, 1)
 }
 
+// StartCollecting does nothing, the counters are always collected.
+//
+// Deprecated: counters no longer need a collecting goroutine.
 func (stats *Stats) StartCollecting() {
-	if stats.collecting {
-		return
+}
+
+// latencyBuckets are the upper bounds of the callback latency histogram. Latencies above the last bound
+// are counted in an additional overflow bucket. Snapshots contain the bounds of all buckets.
+var latencyBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	1 * time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	1 * time.Second,
+	10 * time.Second,
+}
+
+type latencyHistogram struct {
+	buckets [len(latencyBuckets) + 1]int64 // the last bucket counts overflows
+	count   int64
+	sum     int64
+}
+
+func (histogram *latencyHistogram) observe(d time.Duration) {
+	idx := len(latencyBuckets)
+	for i, bound := range latencyBuckets {
+		if d <= bound {
+			idx = i
+			break
+		}
 	}
-	stats.dispatchedChan = make(chan interface{}, 1000)
-	stats.receivedChan = make(chan interface{}, 1000)
-	go func() {
-		for {
-			select {
-			case <-stats.receivedChan:
-				stats.received++
-			case <-stats.dispatchedChan:
-				stats.dispatched++
-			}
+	atomic.AddInt64(&histogram.buckets[idx], 1)
+	atomic.AddInt64(&histogram.sum, int64(d))
+	atomic.AddInt64(&histogram.count, 1)
+}
+
+func (histogram *latencyHistogram) snapshot() *LatencySnapshot {
+	s := &LatencySnapshot{
+		Count:   atomic.LoadInt64(&histogram.count),
+		Sum:     time.Duration(atomic.LoadInt64(&histogram.sum)),
+		Buckets: make([]*LatencyBucket, 0, len(histogram.buckets)),
+	}
+	for i := range histogram.buckets {
+		b := &LatencyBucket{Count: atomic.LoadInt64(&histogram.buckets[i])}
+		if i < len(latencyBuckets) {
+			b.UpperBound = latencyBuckets[i]
 		}
-	}()
-	stats.collecting = true
+		s.Buckets = append(s.Buckets, b)
+	}
+	return s
 }
 
-func (stats *Stats) MessageReceived() {
-	stats.StartCollecting()
-	stats.receivedChan <- nil
+type LatencyBucket struct {
+	UpperBound time.Duration // 0 for the overflow bucket
+	Count      int64
 }
 
+type LatencySnapshot struct {
+	Count   int64
+	Sum     time.Duration
+	Buckets []*LatencyBucket
+}
+
+func (snapshot *LatencySnapshot) Avg() time.Duration {
+	if snapshot.Count == 0 {
+		return 0
+	}
+	return snapshot.Sum / time.Duration(snapshot.Count)
+}
+
+type SubscriptionSnapshot struct {
+	Type       string
+	Policy     OverflowPolicy
+	Dispatched int64
+	Dropped    int64
+	Panicked   int64
+	QueueDepth int
+	Latency    *LatencySnapshot
+}
+
+type StatsSnapshot struct {
+	Received      int64
+	Dispatched    int64
+	Dropped       int64
+	Panicked      int64
+	Subscriptions []*SubscriptionSnapshot
+}
@@ -4,23 +4,95 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Subscription struct {
 	buffer   chan reflect.Value
 	finished chan interface{}
+	closing  chan struct{} // closed by Close before it waits for publishers blocked by the Block policy
 	callback reflect.Value
 	type_    reflect.Type
 	closed   bool
+	options  *SubscriptionOptions
+	stats    *Stats // stats of the PubSub, might be nil
+
+	dispatched int64
+	dropped    int64
+	panicked   int64
+	latency    latencyHistogram
+
+	closeLock sync.RWMutex
+	closeOnce sync.Once
+	spillLock sync.Mutex
+	spill     []reflect.Value
 }
 
 const defaultBufferSize = 1000
 
+// OverflowPolicy defines what happens when a message is published to a subscription with a full buffer.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the message being published (default).
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest buffered message to make room for the new one.
+	DropOldest
+	// Block waits until there is room in the buffer or BlockTimeout is reached.
+	Block
+	// Spill appends messages to an unbounded queue which is drained into the buffer.
+	Spill
+)
+
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case DropNewest:
+		return "drop_newest"
+	case DropOldest:
+		return "drop_oldest"
+	case Block:
+		return "block"
+	case Spill:
+		return "spill"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(policy))
+}
+
+type SubscriptionOptions struct {
+	BufferSize   int            // defaults to defaultBufferSize
+	Policy       OverflowPolicy // defaults to DropNewest
+	BlockTimeout time.Duration  // only used by the Block policy, blocks forever when 0
+}
+
+func (options *SubscriptionOptions) bufferSize() int {
+	if options == nil || options.BufferSize <= 0 {
+		return defaultBufferSize
+	}
+	return options.BufferSize
+}
+
+func (options *SubscriptionOptions) policy() OverflowPolicy {
+	if options == nil {
+		return DropNewest
+	}
+	return options.Policy
+}
+
 func (subscription *Subscription) Close() error {
+	// publishers blocked on a full buffer hold the read lock, they return once closing is closed
+	subscription.closeOnce.Do(func() { close(subscription.closing) })
+	subscription.closeLock.Lock()
+	if subscription.closed {
+		subscription.closeLock.Unlock()
+		return nil
+	}
 	subscription.closed = true
 	close(subscription.buffer)
+	subscription.closeLock.Unlock()
 	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
 	select {
 	case <-timer.C:
 		return fmt.Errorf("timeout waiting for finish")
@@ -29,6 +101,58 @@ func (subscription *Subscription) Close() error {
 	}
 }
 
+func (subscription *Subscription) Dispatched() int64 {
+	return atomic.LoadInt64(&subscription.dispatched)
+}
+
+// Dropped returns the number of messages which were discarded because of a full buffer.
+func (subscription *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&subscription.dropped)
+}
+
+// Panicked returns the number of callback invocations which panicked.
+func (subscription *Subscription) Panicked() int64 {
+	return atomic.LoadInt64(&subscription.panicked)
+}
+
+// QueueDepth returns the number of messages waiting to be processed.
+func (subscription *Subscription) QueueDepth() int {
+	return len(subscription.buffer) + subscription.Spilled()
+}
+
+func (subscription *Subscription) Snapshot() *SubscriptionSnapshot {
+	return &SubscriptionSnapshot{
+		Type:       subscription.type_.String(),
+		Policy:     subscription.options.policy(),
+		Dispatched: subscription.Dispatched(),
+		Dropped:    subscription.Dropped(),
+		Panicked:   subscription.Panicked(),
+		QueueDepth: subscription.QueueDepth(),
+		Latency:    subscription.latency.snapshot(),
+	}
+}
+
+func (subscription *Subscription) messageDropped() {
+	atomic.AddInt64(&subscription.dropped, 1)
+	if subscription.stats != nil {
+		subscription.stats.MessageDropped()
+	}
+}
+
+func (subscription *Subscription) messagePanicked() {
+	atomic.AddInt64(&subscription.panicked, 1)
+	if subscription.stats != nil {
+		subscription.stats.MessagePanicked()
+	}
+}
+
+// Spilled returns the number of messages currently waiting in the spill queue.
+func (subscription *Subscription) Spilled() int {
+	subscription.spillLock.Lock()
+	defer subscription.spillLock.Unlock()
+	return len(subscription.spill)
+}
+
 func (subscription *Subscription) Matches(v reflect.Value) bool {
 	t := v.Type()
 	if subscription.type_ == t {
@@ -40,9 +164,129 @@ func (subscription *Subscription) Matches(v reflect.Value) bool {
 	return false
 }
 
+func (subscription *Subscription) String() string {
+	return fmt.Sprintf("subscription(%v, %v)", subscription.type_, subscription.options.policy())
+}
+
+// deliver hands the value to the subscription according to its overflow policy. It returns true when the
+// value was accepted. A non nil error indicates that a message (not necessarily v) was dropped.
+func (subscription *Subscription) deliver(v reflect.Value) (bool, error) {
+	dispatched, e := subscription.deliverWithPolicy(v)
+	if dispatched {
+		atomic.AddInt64(&subscription.dispatched, 1)
+	}
+	return dispatched, e
+}
+
+func (subscription *Subscription) deliverWithPolicy(v reflect.Value) (bool, error) {
+	subscription.closeLock.RLock()
+	defer subscription.closeLock.RUnlock()
+	if subscription.closed {
+		return false, nil
+	}
+	switch subscription.options.policy() {
+	case DropOldest:
+		return subscription.deliverDropOldest(v)
+	case Block:
+		return subscription.deliverBlock(v)
+	case Spill:
+		return subscription.deliverSpill(v), nil
+	default:
+		select {
+		case subscription.buffer <- v:
+			return true, nil
+		default:
+			subscription.messageDropped()
+			return false, fmt.Errorf("unable to publish to %v: buffer full", subscription)
+		}
+	}
+}
+
+func (subscription *Subscription) deliverDropOldest(v reflect.Value) (bool, error) {
+	select {
+	case subscription.buffer <- v:
+		return true, nil
+	default:
+	}
+	select {
+	case <-subscription.buffer:
+		subscription.messageDropped()
+	default:
+	}
+	select {
+	case subscription.buffer <- v:
+		return true, fmt.Errorf("dropped oldest message of %v: buffer full", subscription)
+	default:
+		subscription.messageDropped()
+		return false, fmt.Errorf("unable to publish to %v: buffer full", subscription)
+	}
+}
+
+func (subscription *Subscription) deliverBlock(v reflect.Value) (bool, error) {
+	timeout := subscription.options.BlockTimeout
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case subscription.buffer <- v:
+		return true, nil
+	case <-subscription.closing:
+		return false, nil
+	case <-expired:
+		subscription.messageDropped()
+		return false, fmt.Errorf("unable to publish to %v: timeout after %v", subscription, timeout)
+	}
+}
+
+// deliverSpill never drops. Once a message went to the spill queue all following messages are queued there
+// as well to keep the order intact.
+func (subscription *Subscription) deliverSpill(v reflect.Value) bool {
+	subscription.spillLock.Lock()
+	defer subscription.spillLock.Unlock()
+	if len(subscription.spill) == 0 {
+		select {
+		case subscription.buffer <- v:
+			return true
+		default:
+		}
+	}
+	subscription.spill = append(subscription.spill, v)
+	return true
+}
+
+// refill moves spilled messages into the buffer as long as there is room.
+func (subscription *Subscription) refill() {
+	subscription.spillLock.Lock()
+	defer subscription.spillLock.Unlock()
+	for len(subscription.spill) > 0 {
+		select {
+		case subscription.buffer <- subscription.spill[0]:
+			subscription.spill[0] = reflect.Value{}
+			subscription.spill = subscription.spill[1:]
+		default:
+			return
+		}
+	}
+}
+
+// takeSpill removes and returns all spilled messages.
+func (subscription *Subscription) takeSpill() []reflect.Value {
+	subscription.spillLock.Lock()
+	defer subscription.spillLock.Unlock()
+	spilled := subscription.spill
+	subscription.spill = nil
+	return spilled
+}
+
 func (subscription *Subscription) trigger(v reflect.Value) {
+	started := time.Now()
 	defer func() {
+		subscription.latency.observe(time.Since(started))
 		if r := recover(); r != nil {
+			subscription.messagePanicked()
 			log.Print("PANIC: ", r)
 		}
 	}()
@@ -50,12 +294,24 @@ func (subscription *Subscription) trigger(v reflect.Value) {
 }
 
 func (subscription *Subscription) start() {
-	subscription.buffer = make(chan reflect.Value, defaultBufferSize)
+	subscription.buffer = make(chan reflect.Value, subscription.options.bufferSize())
 	subscription.finished = make(chan interface{})
+	subscription.closing = make(chan struct{})
+	spill := subscription.options.policy() == Spill
 	go func() {
 		for value := range subscription.buffer {
 			subscription.trigger(value)
+			if spill {
+				subscription.closeLock.RLock()
+				if !subscription.closed {
+					subscription.refill()
+				}
+				subscription.closeLock.RUnlock()
+			}
+		}
+		for _, value := range subscription.takeSpill() {
+			subscription.trigger(value)
 		}
-		subscription.finished <- nil
+		close(subscription.finished)
 	}()
 }
@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// FloatStats works like Stats but on float64 values. All methods return 0 for an empty set.
+type FloatStats struct {
+	Values []float64
+	sorted bool
+}
+
+func (stats *FloatStats) Add(f float64) {
+	stats.Values = append(stats.Values, f)
+	stats.sorted = false
+}
+
+// AddDuration adds the duration in milliseconds.
+func (stats *FloatStats) AddDuration(d time.Duration) {
+	stats.Add(float64(d) / float64(time.Millisecond))
+}
+
+func (stats *FloatStats) Len() int {
+	return len(stats.Values)
+}
+
+func (stats *FloatStats) Sort() {
+	if !stats.sorted {
+		sort.Float64s(stats.Values)
+		stats.sorted = true
+	}
+}
+
+func (stats *FloatStats) Min() float64 {
+	stats.Sort()
+	if len(stats.Values) == 0 {
+		return 0
+	}
+	return stats.Values[0]
+}
+
+func (stats *FloatStats) Max() float64 {
+	stats.Sort()
+	if len(stats.Values) == 0 {
+		return 0
+	}
+	return stats.Values[len(stats.Values)-1]
+}
+
+func (stats *FloatStats) Sum() float64 {
+	sum := 0.0
+	for _, f := range stats.Values {
+		sum += f
+	}
+	return sum
+}
+
+func (stats *FloatStats) Avg() float64 {
+	if len(stats.Values) == 0 {
+		return 0
+	}
+	return stats.Sum() / float64(len(stats.Values))
+}
+
+// Variance returns the population variance of the values.
+func (stats *FloatStats) Variance() float64 {
+	if len(stats.Values) == 0 {
+		return 0
+	}
+	avg := stats.Avg()
+	sum := 0.0
+	for _, f := range stats.Values {
+		sum += (f - avg) * (f - avg)
+	}
+	return sum / float64(len(stats.Values))
+}
+
+// Stddev returns the population standard deviation of the values.
+func (stats *FloatStats) Stddev() float64 {
+	return math.Sqrt(stats.Variance())
+}
+
+func (stats *FloatStats) Median() float64 {
+	return stats.Perc(50)
+}
+
+// Perc returns the nearest-rank percentile. perc is clamped to 0..100.
+func (stats *FloatStats) Perc(perc float64) float64 {
+	stats.Sort()
+	return FloatPercentile(stats.Values, perc)
+}
+
+// FloatPercentile returns the percentile of the sorted values.
+func FloatPercentile(values []float64, perc float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[percentileIndex(len(values), perc)]
+}
+
+func percentileIndex(length int, perc float64) int {
+	idx := int(math.Floor(float64(length) * perc / 100.0))
+	if idx < 0 {
+		return 0
+	}
+	if idx >= length {
+		return length - 1
+	}
+	return idx
+}
+
+type Bucket struct {
+	UpperBound float64 // +Inf for the last bucket
+	Count      int
+}
+
+// Histogram counts the values per bucket. A value v is counted in the first bucket with v <= bound. Values
+// above the last bound are counted in an additional bucket with an upper bound of +Inf.
+func (stats *FloatStats) Histogram(bounds ...float64) []*Bucket {
+	sorted := append([]float64{}, bounds...)
+	sort.Float64s(sorted)
+	buckets := make([]*Bucket, 0, len(sorted)+1)
+	for _, b := range sorted {
+		buckets = append(buckets, &Bucket{UpperBound: b})
+	}
+	buckets = append(buckets, &Bucket{UpperBound: math.Inf(1)})
+	for _, f := range stats.Values {
+		idx := sort.SearchFloat64s(sorted, f)
+		buckets[idx].Count++
+	}
+	return buckets
+}
+
+func (stats *FloatStats) String() string {
+	return fmt.Sprintf("len: %d, avg: %.3f, stddev: %.3f, med: %.3f, perc_95: %.3f, perc_99: %.3f, max: %.3f, min: %.3f",
+		stats.Len(), stats.Avg(), stats.Stddev(), stats.Median(), stats.Perc(95), stats.Perc(99),
+		stats.Max(),
+		stats.Min(),
+	)
+}
+
+func (stats *FloatStats) Reset() {
+	stats.Values = stats.Values[:0]
+	stats.sorted = false
+}
@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultRelativeAccuracy is the relative accuracy of percentiles in streaming mode.
+const DefaultRelativeAccuracy = 0.01
+
+// sketch is a log-bucketed histogram (similar to DDSketch). Every value v is counted in the bucket
+// ceil(log_gamma(|v|)), so percentiles are accurate to the configured relative accuracy. The number of
+// buckets is bounded by the range of the values (~2200 for the whole int64 range with 1% accuracy).
+type sketch struct {
+	gamma    float64
+	logGamma float64
+	positive map[int]int64
+	negative map[int]int64
+	zero     int64
+}
+
+func newSketch(relativeAccuracy float64) *sketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = DefaultRelativeAccuracy
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		positive: map[int]int64{},
+		negative: map[int]int64{},
+	}
+}
+
+// empty returns a new sketch with the same accuracy.
+func (s *sketch) empty() *sketch {
+	return &sketch{
+		gamma:    s.gamma,
+		logGamma: s.logGamma,
+		positive: map[int]int64{},
+		negative: map[int]int64{},
+	}
+}
+
+func (s *sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+func (s *sketch) value(idx int) float64 {
+	return 2 * math.Pow(s.gamma, float64(idx)) / (s.gamma + 1)
+}
+
+func (s *sketch) add(v float64, cnt int64) {
+	switch {
+	case v > 0:
+		s.positive[s.index(v)] += cnt
+	case v < 0:
+		s.negative[s.index(-v)] += cnt
+	default:
+		s.zero += cnt
+	}
+}
+
+func (s *sketch) merge(other *sketch) {
+	if s.gamma != other.gamma {
+		// different accuracy, re-add the representative values of the other sketch
+		for idx, cnt := range other.positive {
+			s.add(other.value(idx), cnt)
+		}
+		for idx, cnt := range other.negative {
+			s.add(-other.value(idx), cnt)
+		}
+		s.zero += other.zero
+		return
+	}
+	for idx, cnt := range other.positive {
+		s.positive[idx] += cnt
+	}
+	for idx, cnt := range other.negative {
+		s.negative[idx] += cnt
+	}
+	s.zero += other.zero
+}
+
+// quantile returns the value of the element with the given rank (0 based) out of count elements.
+func (s *sketch) quantile(rank int64) float64 {
+	var seen int64
+	for _, idx := range sortedKeys(s.negative, true) {
+		seen += s.negative[idx]
+		if seen > rank {
+			return -s.value(idx)
+		}
+	}
+	seen += s.zero
+	if seen > rank {
+		return 0
+	}
+	var last float64
+	for _, idx := range sortedKeys(s.positive, false) {
+		seen += s.positive[idx]
+		last = s.value(idx)
+		if seen > rank {
+			return last
+		}
+	}
+	return last
+}
+
+func sortedKeys(m map[int]int64, desc bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	if desc {
+		sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	} else {
+		sort.Ints(keys)
+	}
+	return keys
+}
+
+func (s *sketch) buckets() int {
+	return len(s.positive) + len(s.negative)
+}
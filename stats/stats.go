@@ -6,17 +6,94 @@ import (
 	"sort"
 )
 
+// Stats collects int values. By default all values are kept in Values. Stats created with NewStreaming only
+// keep a histogram of the values, so memory stays bounded but percentiles are approximations.
 type Stats struct {
 	Values []int
 	sorted bool
-	sum    int
+
+	streaming *sketch
+	count     int
+	sum       int
+	min       int
+	max       int
+}
+
+// NewStreaming returns Stats which keep the values in a histogram instead of Values. Percentiles are
+// accurate to DefaultRelativeAccuracy, Min, Max, Sum and Avg are exact.
+func NewStreaming() *Stats {
+	return NewStreamingWithAccuracy(DefaultRelativeAccuracy)
+}
+
+func NewStreamingWithAccuracy(relativeAccuracy float64) *Stats {
+	return &Stats{streaming: newSketch(relativeAccuracy)}
+}
+
+func (stats *Stats) Streaming() bool {
+	return stats.streaming != nil
 }
 
 func (stats *Stats) Add(i int) {
+	if stats.streaming != nil {
+		stats.streaming.add(float64(i), 1)
+		if stats.count == 0 || i < stats.min {
+			stats.min = i
+		}
+		if stats.count == 0 || i > stats.max {
+			stats.max = i
+		}
+		stats.count++
+		stats.sum += i
+		return
+	}
 	stats.Values = append(stats.Values, i)
+	stats.sorted = false
+}
+
+// Merge adds all values of other. When other is streaming stats are converted to streaming as well.
+func (stats *Stats) Merge(other *Stats) {
+	if other.streaming != nil && stats.streaming == nil {
+		stats.convertToStreaming(other.streaming)
+	}
+	if stats.streaming == nil {
+		stats.Values = append(stats.Values, other.Values...)
+		stats.sorted = false
+		return
+	}
+	if other.streaming == nil {
+		for _, v := range other.Values {
+			stats.Add(v)
+		}
+		return
+	}
+	if other.count == 0 {
+		return
+	}
+	stats.streaming.merge(other.streaming)
+	if stats.count == 0 || other.min < stats.min {
+		stats.min = other.min
+	}
+	if stats.count == 0 || other.max > stats.max {
+		stats.max = other.max
+	}
+	stats.count += other.count
+	stats.sum += other.sum
+}
+
+func (stats *Stats) convertToStreaming(template *sketch) {
+	values := stats.Values
+	stats.Values = nil
+	stats.sorted = false
+	stats.streaming = template.empty()
+	for _, v := range values {
+		stats.Add(v)
+	}
 }
 
 func (stats *Stats) Max() int {
+	if stats.streaming != nil {
+		return stats.max
+	}
 	stats.Sort()
 	if len(stats.Values) == 0 {
 		return 0
@@ -25,6 +102,9 @@ func (stats *Stats) Max() int {
 }
 
 func (stats *Stats) Min() int {
+	if stats.streaming != nil {
+		return stats.min
+	}
 	stats.Sort()
 	if len(stats.Values) == 0 {
 		return 0
@@ -33,13 +113,14 @@ func (stats *Stats) Min() int {
 }
 
 func (stats *Stats) Sum() int {
-	if stats.sum > 0 {
+	if stats.streaming != nil {
 		return stats.sum
 	}
+	sum := 0
 	for _, i := range stats.Values {
-		stats.sum += i
+		sum += i
 	}
-	return stats.sum
+	return sum
 }
 
 func (stats *Stats) Sort() {
@@ -49,38 +130,69 @@ func (stats *Stats) Sort() {
 	}
 }
 
+// Avg returns the floored average of all values or 0 when there are no values.
 func (stats *Stats) Avg() int {
-	return int(math.Floor(float64(stats.Sum()) / float64(len(stats.Values))))
+	if stats.Len() == 0 {
+		return 0
+	}
+	return int(math.Floor(float64(stats.Sum()) / float64(stats.Len())))
 }
 
 func (stats *Stats) Median() int {
 	return stats.Perc(50)
 }
 
+// Percentile returns the percentile of the sorted values or 0 when there are no values.
 func Percentile(values []int, perc int) (o int) {
-	middle := float64(len(values)) * float64(perc) / 100.0
-	floor := int(math.Floor(middle))
-	return values[floor]
+	if len(values) == 0 {
+		return 0
+	}
+	return values[percentileIndex(len(values), float64(perc))]
 }
 
 func (stats *Stats) Perc(perc int) int {
+	if stats.streaming != nil {
+		return stats.streamingPerc(perc)
+	}
 	stats.Sort()
 	return Percentile(stats.Values, perc)
 }
 
+func (stats *Stats) streamingPerc(perc int) int {
+	if stats.count == 0 {
+		return 0
+	}
+	rank := percentileIndex(stats.count, float64(perc))
+	v := int(math.Round(stats.streaming.quantile(int64(rank))))
+	if v < stats.min {
+		return stats.min
+	}
+	if v > stats.max {
+		return stats.max
+	}
+	return v
+}
+
 func (stats *Stats) String() string {
 	return fmt.Sprintf("len: %d, avg: %d, med: %d, perc_95: %d, perc_99: %d, max: %d, min: %d",
-		len(stats.Values), stats.Avg(), stats.Median(), stats.Perc(95), stats.Perc(99),
+		stats.Len(), stats.Avg(), stats.Median(), stats.Perc(95), stats.Perc(99),
 		stats.Max(),
 		stats.Min(),
 	)
 }
 
 func (stats *Stats) Len() int {
+	if stats.streaming != nil {
+		return stats.count
+	}
 	return len(stats.Values)
 }
 
 func (stats *Stats) Reset() {
 	stats.Values = stats.Values[:0]
 	stats.sorted = false
+	if stats.streaming != nil {
+		stats.streaming = stats.streaming.empty()
+	}
+	stats.count, stats.sum, stats.min, stats.max = 0, 0, 0, 0
 }
@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStats(t *testing.T) {
+	Convey("Stats", t, func() {
+		s := &Stats{}
+		for _, i := range []int{5, 1, 3} {
+			s.Add(i)
+		}
+		So(s.Sum(), ShouldEqual, 9)
+		So(s.Max(), ShouldEqual, 5)
+		s.Add(11)
+		So(s.Sum(), ShouldEqual, 20)
+		So(s.Max(), ShouldEqual, 11)
+		So(s.Avg(), ShouldEqual, 5)
+
+		Convey("Merge", func() {
+			other := &Stats{}
+			other.Add(0)
+			s.Merge(other)
+			So(s.Len(), ShouldEqual, 5)
+			So(s.Min(), ShouldEqual, 0)
+			So(s.Streaming(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestStreaming(t *testing.T) {
+	Convey("Streaming", t, func() {
+		s := NewStreaming()
+		exact := &Stats{}
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100000; i++ {
+			v := r.Intn(10000)
+			s.Add(v)
+			exact.Add(v)
+		}
+		So(s.Len(), ShouldEqual, exact.Len())
+		So(s.Sum(), ShouldEqual, exact.Sum())
+		So(s.Avg(), ShouldEqual, exact.Avg())
+		So(s.Min(), ShouldEqual, exact.Min())
+		So(s.Max(), ShouldEqual, exact.Max())
+		So(s.Values, ShouldBeEmpty)
+		So(s.streaming.buckets(), ShouldBeLessThan, 1000)
+		for _, p := range []int{1, 50, 95, 99} {
+			expected := exact.Perc(p)
+			So(s.Perc(p), ShouldAlmostEqual, expected, float64(expected)*DefaultRelativeAccuracy+1)
+		}
+		So(s.Perc(100), ShouldEqual, s.Max())
+
+		Convey("negative values", func() {
+			s := NewStreaming()
+			for _, v := range []int{-100, -10, 0, 10, 100} {
+				s.Add(v)
+			}
+			So(s.Median(), ShouldEqual, 0)
+			So(s.Perc(0), ShouldEqual, -100)
+			So(s.Perc(20), ShouldAlmostEqual, -10, 1)
+		})
+
+		Convey("Merge", func() {
+			a, b := NewStreaming(), NewStreaming()
+			for i := 1; i <= 100; i++ {
+				a.Add(i)
+				b.Add(i + 100)
+			}
+			a.Merge(b)
+			So(a.Len(), ShouldEqual, 200)
+			So(a.Min(), ShouldEqual, 1)
+			So(a.Max(), ShouldEqual, 200)
+			So(a.Median(), ShouldAlmostEqual, 101, 2)
+
+			Convey("into exact stats", func() {
+				e := &Stats{Values: []int{0, 1000}}
+				e.Merge(a)
+				So(e.Streaming(), ShouldBeTrue)
+				So(e.Len(), ShouldEqual, 202)
+				So(e.Min(), ShouldEqual, 0)
+				So(e.Max(), ShouldEqual, 1000)
+			})
+		})
+	})
+}
+
+func TestEmpty(t *testing.T) {
+	Convey("empty stats", t, func() {
+		s := &Stats{}
+		So(s.Avg(), ShouldEqual, 0)
+		So(s.Median(), ShouldEqual, 0)
+		So(Percentile(nil, 99), ShouldEqual, 0)
+		So(NewStreaming().Avg(), ShouldEqual, 0)
+
+		f := &FloatStats{}
+		So(f.Avg(), ShouldEqual, 0)
+		So(f.Variance(), ShouldEqual, 0)
+		So(f.Perc(99), ShouldEqual, 0)
+		So(f.String(), ShouldNotBeEmpty)
+	})
+}
+
+func TestFloatStats(t *testing.T) {
+	Convey("FloatStats", t, func() {
+		s := &FloatStats{}
+		for _, f := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+			s.Add(f)
+		}
+		So(s.Avg(), ShouldEqual, 5)
+		So(s.Variance(), ShouldEqual, 4)
+		So(s.Stddev(), ShouldEqual, 2)
+		So(s.Min(), ShouldEqual, 2)
+		So(s.Max(), ShouldEqual, 9)
+		So(s.Median(), ShouldEqual, 5)
+		So(s.Perc(100), ShouldEqual, 9)
+
+		s.AddDuration(1500 * time.Microsecond)
+		So(s.Min(), ShouldEqual, 1.5)
+
+		buckets := s.Histogram(5, 2)
+		So(len(buckets), ShouldEqual, 3)
+		So(buckets[0].UpperBound, ShouldEqual, 2)
+		So(buckets[0].Count, ShouldEqual, 2)
+		So(buckets[1].Count, ShouldEqual, 5)
+		So(buckets[2].Count, ShouldEqual, 2)
+		So(math.IsInf(buckets[2].UpperBound, 1), ShouldBeTrue)
+	})
+}
+
+func TestWindow(t *testing.T) {
+	Convey("Window", t, func() {
+		Convey("by samples", func() {
+			w := NewSampleWindow(3)
+			for i := 1; i <= 5; i++ {
+				w.Add(float64(i))
+			}
+			So(w.Stats().Values, ShouldResemble, []float64{3, 4, 5})
+		})
+
+		Convey("by time", func() {
+			now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+			w := NewTimeWindow(10 * time.Second)
+			w.now = func() time.Time { return now }
+			w.AddAt(now.Add(-15*time.Second), 1)
+			w.AddAt(now.Add(-5*time.Second), 2)
+			w.AddAt(now, 3)
+			So(w.Len(), ShouldEqual, 2)
+			now = now.Add(6 * time.Second)
+			So(w.Stats().Values, ShouldResemble, []float64{3})
+			now = now.Add(time.Minute)
+			So(w.Stats().Avg(), ShouldEqual, 0)
+		})
+	})
+}
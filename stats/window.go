@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// Window keeps the values of the last N samples or of the last duration. It is safe for concurrent use, e.g.
+// to collect values in request handlers and read them from a dashboard.
+type Window struct {
+	duration time.Duration
+	size     int
+	samples  []sample
+	lock     sync.Mutex
+	now      func() time.Time
+}
+
+// NewTimeWindow returns a window with all values added within the last d.
+func NewTimeWindow(d time.Duration) *Window {
+	return &Window{duration: d, now: time.Now}
+}
+
+// NewSampleWindow returns a window with the last n values.
+func NewSampleWindow(n int) *Window {
+	return &Window{size: n, now: time.Now}
+}
+
+func (window *Window) Add(f float64) {
+	window.AddAt(window.now(), f)
+}
+
+// AddAt adds a value with the given timestamp. Timestamps are expected to be added in order.
+func (window *Window) AddAt(t time.Time, f float64) {
+	window.lock.Lock()
+	defer window.lock.Unlock()
+	window.samples = append(window.samples, sample{at: t, value: f})
+	window.prune(t)
+}
+
+func (window *Window) prune(now time.Time) {
+	drop := 0
+	if window.size > 0 && len(window.samples) > window.size {
+		drop = len(window.samples) - window.size
+	}
+	if window.duration > 0 {
+		oldest := now.Add(-window.duration)
+		for drop < len(window.samples) && !window.samples[drop].at.After(oldest) {
+			drop++
+		}
+	}
+	if drop > 0 {
+		window.samples = append(window.samples[:0], window.samples[drop:]...)
+	}
+}
+
+func (window *Window) Len() int {
+	window.lock.Lock()
+	defer window.lock.Unlock()
+	window.prune(window.now())
+	return len(window.samples)
+}
+
+// Stats returns the statistics of the values currently in the window.
+func (window *Window) Stats() *FloatStats {
+	window.lock.Lock()
+	defer window.lock.Unlock()
+	window.prune(window.now())
+	stats := &FloatStats{Values: make([]float64, 0, len(window.samples))}
+	for _, s := range window.samples {
+		stats.Values = append(stats.Values, s.value)
+	}
+	return stats
+}